@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/ontio/ontology/common"
+)
+
+const (
+	ADDRESS_FORMAT_HEX    = "hex"
+	ADDRESS_FORMAT_BASE58 = "base58"
+)
+
+//Addresses are saved in hex, but the hex form depends on the contract.
+//Base58 addresses of native and wasm notify are saved as common.Address.ToHexString, which is in reverse byte order,
+//while addresses of NeoVM notify are saved as the hex of address bytes.
+func isReverseHexAddressContract(contract string) bool {
+	return IsNativeContract(contract) || IsWasmContract(contract)
+}
+
+//IsHexAddress checks address is a hex address saved by holder
+func IsHexAddress(address string) bool {
+	return IsContractAddress(address)
+}
+
+//AddressToHex returns the hex address of contract holders
+func AddressToHex(address common.Address, contract string) string {
+	if isReverseHexAddressContract(contract) {
+		return address.ToHexString()
+	}
+	return hex.EncodeToString(address[:])
+}
+
+//HexToAddress parses hex address of contract holders
+func HexToAddress(hexAddress, contract string) (common.Address, error) {
+	if isReverseHexAddressContract(contract) {
+		return common.AddressFromHexString(hexAddress)
+	}
+	data, err := hex.DecodeString(hexAddress)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.AddressParseFromBytes(data)
+}
+
+//HexToBase58 returns the base58 address of hex address of contract holders, hex address is returned if it's invalid
+func HexToBase58(hexAddress, contract string) string {
+	address, err := HexToAddress(hexAddress, contract)
+	if err != nil {
+		return hexAddress
+	}
+	return address.ToBase58()
+}
+
+//ParseAddress parses a hex or base58 address of api, base58 address is nil for hex address
+func ParseAddress(address string) (string, *common.Address, error) {
+	if IsHexAddress(address) {
+		return address, nil, nil
+	}
+	base58Address, err := common.AddressFromBase58(address)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid address:%s", address)
+	}
+	return "", &base58Address, nil
+}
+
+//ResolveAddress returns the hex address of contract holders for a hex or base58 address
+func ResolveAddress(address, contract string) (string, error) {
+	hexAddress, base58Address, err := ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if base58Address == nil {
+		return hexAddress, nil
+	}
+	if contract == "" {
+		return "", fmt.Errorf("contract is required for base58 address:%s", address)
+	}
+	return AddressToHex(*base58Address, contract), nil
+}
+
+//ResolveAddressOfAllContracts returns the hex addresses that address may be saved as by holders of any contract.
+//A base58 address has two hex forms, holders should be filtered by IsHolderOfAddress.
+func ResolveAddressOfAllContracts(address string) ([]string, error) {
+	hexAddress, base58Address, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if base58Address == nil {
+		return []string{hexAddress}, nil
+	}
+	return []string{hex.EncodeToString(base58Address[:]), base58Address.ToHexString()}, nil
+}
+
+//IsHolderOfAddress checks holder is of the hex or base58 address of api
+func IsHolderOfAddress(holder *AssetHolder, address string) bool {
+	return IsContractAddressOf(holder.Address, holder.Contract, address)
+}
+
+//IsContractAddressOf checks hexAddress saved by contract is the hex or base58 address of api
+func IsContractAddressOf(hexAddress, contract, address string) bool {
+	parsedHexAddress, base58Address, err := ParseAddress(address)
+	if err != nil {
+		return false
+	}
+	if base58Address == nil {
+		return hexAddress == parsedHexAddress
+	}
+	return hexAddress == AddressToHex(*base58Address, contract)
+}
+
+//FormatAddress returns hex address of contract holders in addressFormat
+func FormatAddress(hexAddress, contract, addressFormat string) string {
+	if addressFormat == ADDRESS_FORMAT_BASE58 {
+		return HexToBase58(hexAddress, contract)
+	}
+	return hexAddress
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	TEST_ADDRESS_BASE58      = "AFsCjUGzicZmXQtWpwVt6fQTZyaVe7bfEk" //address of bytes 0x01 to 0x14
+	TEST_ADDRESS_HEX         = "0102030405060708090a0b0c0d0e0f1011121314"
+	TEST_ADDRESS_REVERSE_HEX = "14131211100f0e0d0c0b0a090807060504030201"
+)
+
+func TestAddressOfContractTypes(t *testing.T) {
+	contractDecoders := DefConfig.ContractDecoders
+	defer func() {
+		DefConfig.ContractDecoders = contractDecoders
+	}()
+	tests := []struct {
+		decoder  string
+		contract string
+		hex      string
+	}{
+		{DECODER_NATIVE, ONT_CONTRACT_ADDRESS, TEST_ADDRESS_REVERSE_HEX},
+		{DECODER_NATIVE, ONG_CONTRACT_ADDRESS, TEST_ADDRESS_REVERSE_HEX},
+		{DECODER_WASM_OEP4, "1000000000000000000000000000000000000001", TEST_ADDRESS_REVERSE_HEX},
+		{DECODER_OEP4, "1000000000000000000000000000000000000002", TEST_ADDRESS_HEX},
+		{DECODER_OEP4_MINTBURN, "1000000000000000000000000000000000000003", TEST_ADDRESS_HEX},
+		{DECODER_OEP5, "1000000000000000000000000000000000000004", TEST_ADDRESS_HEX},
+		{DECODER_OEP8, "1000000000000000000000000000000000000005", TEST_ADDRESS_HEX},
+	}
+	DefConfig.ContractDecoders = make(map[string]string)
+	for _, test := range tests {
+		if !IsNativeContract(test.contract) {
+			DefConfig.ContractDecoders[test.contract] = test.decoder
+		}
+	}
+	for _, test := range tests {
+		//base58 input is saved in the hex form of contract, hex input is kept
+		for _, address := range []string{TEST_ADDRESS_BASE58, test.hex} {
+			hexAddress, err := ResolveAddress(address, test.contract)
+			if err != nil {
+				t.Errorf("%s: ResolveAddress %s error:%s", test.decoder, address, err)
+				continue
+			}
+			if hexAddress != test.hex {
+				t.Errorf("%s: ResolveAddress %s is %s, expect %s", test.decoder, address, hexAddress, test.hex)
+			}
+		}
+		holder := &AssetHolder{Address: test.hex, Contract: test.contract}
+		if !IsHolderOfAddress(holder, TEST_ADDRESS_BASE58) || !IsHolderOfAddress(holder, test.hex) {
+			t.Errorf("%s: holder %s is not of its address", test.decoder, test.hex)
+		}
+
+		//address_format round trips the saved hex address
+		formats := []struct {
+			addressFormat string
+			address       string
+		}{
+			{"", test.hex},
+			{ADDRESS_FORMAT_HEX, test.hex},
+			{ADDRESS_FORMAT_BASE58, TEST_ADDRESS_BASE58},
+		}
+		for _, format := range formats {
+			address := FormatAddress(test.hex, test.contract, format.addressFormat)
+			if address != format.address {
+				t.Errorf("%s: FormatAddress %q is %s, expect %s", test.decoder, format.addressFormat, address, format.address)
+			}
+			hexAddress, err := ResolveAddress(address, test.contract)
+			if err != nil || hexAddress != test.hex {
+				t.Errorf("%s: ResolveAddress of format %q is %s error:%v, expect %s", test.decoder, format.addressFormat, hexAddress, err, test.hex)
+			}
+		}
+	}
+}
+
+func TestResolveAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		contract string
+		isErr    bool
+	}{
+		{"base58 without contract", TEST_ADDRESS_BASE58, "", true},
+		{"hex without contract", TEST_ADDRESS_HEX, "", false},
+		{"invalid base58", "AFsCjUGzicZmXQtWpwVt6fQTZyaVe7bfEx", ONT_CONTRACT_ADDRESS, true},
+		{"short hex", "0102", ONT_CONTRACT_ADDRESS, true},
+		{"empty", "", ONT_CONTRACT_ADDRESS, true},
+	}
+	for _, test := range tests {
+		_, err := ResolveAddress(test.address, test.contract)
+		if (err != nil) != test.isErr {
+			t.Errorf("%s: error:%v, expect error:%v", test.name, err, test.isErr)
+		}
+	}
+
+	addresses, err := ResolveAddressOfAllContracts(TEST_ADDRESS_BASE58)
+	if err != nil {
+		t.Fatalf("ResolveAddressOfAllContracts error:%s", err)
+	}
+	if !reflect.DeepEqual(addresses, []string{TEST_ADDRESS_HEX, TEST_ADDRESS_REVERSE_HEX}) {
+		t.Errorf("ResolveAddressOfAllContracts:%v", addresses)
+	}
+	if HexToBase58("0102", ONT_CONTRACT_ADDRESS) != "0102" {
+		t.Errorf("invalid hex address is not kept by HexToBase58")
+	}
+}
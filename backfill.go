@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	log4 "github.com/alecthomas/log4go"
+	"time"
+)
+
+const (
+	BACKFILL_BATCH_BLOCKS = 1000
+)
+
+//startBackfill starts backfill worker of contracts which haven't caught up with live sync.
+func (this *OntologyManager) startBackfill() {
+	if this.GetCurrentNodeId() != NodeId {
+		return
+	}
+	for _, info := range DefContractMgr.GetBackfillContracts() {
+		if !DefContractMgr.TryStartBackfill(info.Contract) {
+			continue
+		}
+		log4.Info("Start backfill contract:%s from height:%d", info.Contract, info.Height+1)
+		go this.backfill(info.Contract, info.Height)
+	}
+}
+
+//backfill syncs transfers of contract from height+1, until it catches up with live sync.
+func (this *OntologyManager) backfill(contract string, height uint32) {
+	defer DefContractMgr.StopBackfill(contract)
+	for {
+		select {
+		case <-this.exitCh:
+			return
+		default:
+		}
+		if this.GetCurrentNodeId() != NodeId || !DefContractMgr.IsBackfill(contract) {
+			log4.Info("Stop backfill contract:%s at height:%d", contract, height)
+			return
+		}
+		if DefContractMgr.TryGoLive(contract, height) {
+			log4.Info("Contract:%s backfilled to height:%d, switch to live sync", contract, height)
+			return
+		}
+		syncedHeight, err := this.backfillBlocks(contract, height, DefContractMgr.GetDecodedHeight())
+		if err != nil {
+			log4.Error("backfill contract:%s error:%s", contract, err)
+			select {
+			case <-this.exitCh:
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		height = syncedHeight
+		DefContractMgr.SetBackfillHeight(contract, height)
+	}
+}
+
+//backfillBlocks applies transfers of contract in blocks from height+1 to at most targetHeight,
+//and moves the sync checkpoint of contract with them. It returns the new checkpoint.
+func (this *OntologyManager) backfillBlocks(contract string, height, targetHeight uint32) (uint32, error) {
+	if targetHeight <= height {
+		return height, nil
+	}
+	contracts := map[string]bool{contract: true}
+	dbBatchSize := int(DefConfig.DBBatchSize)
+	txTransfers := make([]*TxTransfer, 0, dbBatchSize)
+	txApprovals := make([]*Allowance, 0)
+	endHeight := targetHeight
+	if endHeight-height > BACKFILL_BATCH_BLOCKS {
+		endHeight = height + BACKFILL_BATCH_BLOCKS
+	}
+	syncedHeight := height
+	err := this.fetchEvtNotify(height+1, endHeight, func(evtNotify *EventNotify) bool {
+		for _, evt := range evtNotify.EventNotifies {
+			transfers, approvals := this.getTxTransferFromNotify(evt, evtNotify.BlockHeight, evtNotify.BlockTime, contracts)
+			transfers, approvals = getAppliedTransfers(evt, transfers, approvals)
+			txTransfers = append(txTransfers, transfers...)
+			txApprovals = append(txApprovals, approvals...)
+		}
+		syncedHeight = evtNotify.BlockHeight
+		return len(txTransfers)+len(txApprovals) < dbBatchSize
+	})
+	if err != nil {
+		return height, err
+	}
+	if syncedHeight == height {
+		return height, nil
+	}
+	this.holderLock.Lock()
+	defer this.holderLock.Unlock()
+	assetHolders, err := this.applyTransfers(txTransfers)
+	if err != nil {
+		return height, err
+	}
+	err = this.store.OnTxEventNotify(nil, assetHolders, txTransfers, getTokenOwners(txTransfers), getAllowances(txApprovals), []*SyncState{{Contract: contract, Height: syncedHeight}})
+	if err != nil {
+		return height, fmt.Errorf("OnTxEventNotify error:%s", err)
+	}
+	log4.Info("Backfill contract:%s to height:%d", contract, syncedHeight)
+	return syncedHeight, nil
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const (
+	DEFAULT_COMMAND = "run"
+)
+
+//Command is a subcommand of ontology-holder, args are the arguments after the command name.
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands = []*Command{
+	{Name: "run", Usage: "start the holder node, it's the default command", Run: runNode},
+	{Name: "init-db", Usage: "create tables of store", Run: runInitDB},
+	{Name: "migrate", Usage: "create new tables and upgrade tables created by an older version", Run: runMigrate},
+	{Name: "status", Usage: "print sync status of contracts and ontology nodes", Run: runStatus},
+	{Name: "contract", Usage: "add, remove or list monitored contracts", Run: runContract},
+	{Name: "resync-contract", Usage: "delete indexed data of a contract and sync it again from start height", Run: runResyncContract},
+	{Name: "verify", Usage: "compare holders with balanceOf of contracts on chain", Run: runVerify},
+	{Name: "rollback", Usage: "revert indexed data to a block height", Run: runRollback},
+	{Name: "export", Usage: "export holders of a contract at a block height as csv", Run: runExport},
+}
+
+func getCommand(name string) *Command {
+	for _, command := range commands {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: ontology-holder [flags] [command] [command flags]\n\nCommands:\n")
+	for _, command := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s%s\n", command.Name, command.Usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+//openStore loads config, and opens the store of it
+func openStore() (HolderStore, error) {
+	err := GetJsonObject(CfgPath, DefConfig)
+	if err != nil {
+		return nil, fmt.Errorf("init config error:%s", err)
+	}
+	store, err := NewHolderStore(DefConfig, DBInstallFile)
+	if err != nil {
+		return nil, fmt.Errorf("NewHolderStore error:%s", err)
+	}
+	return store, nil
+}
+
+//runInitDB creates tables by DBInstallFile, tables exist are kept.
+func runInitDB(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	fmt.Printf("Init %s store success\n", DefConfig.GetStoreType())
+	return nil
+}
+
+//runMigrate upgrades tables created by an older version without starting the node, migrations applied before are skipped.
+func runMigrate(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	migrations, err := store.Migrate()
+	if err != nil {
+		return fmt.Errorf("Migrate error:%s", err)
+	}
+	for _, migration := range migrations {
+		fmt.Printf("Applied:%s\n", migration)
+	}
+	fmt.Printf("Migrate success, %d migrations applied\n", len(migrations))
+	return nil
+}
+
+//runStatus prints the master node, sync checkpoints of contracts, and heights of ontology nodes.
+func runStatus(args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	heartbeat, err := store.GetHeartbeat(HEARTBEAT_MODULE)
+	if err != nil {
+		return fmt.Errorf("GetHeartbeat error:%s", err)
+	}
+	if heartbeat != nil {
+		fmt.Printf("Master node:%d update time:%s\n", heartbeat.NodeId, heartbeat.UpdateTime)
+	}
+	notifyHeight, err := store.GetSyncedEventNotifyBlockHeight()
+	if err != nil {
+		return fmt.Errorf("GetSyncedEventNotifyBlockHeight error:%s", err)
+	}
+	fmt.Printf("Event notify height:%d\n", notifyHeight)
+
+	contracts, err := store.GetContracts()
+	if err != nil {
+		return fmt.Errorf("GetContracts error:%s", err)
+	}
+	syncStates, err := store.GetSyncStates()
+	if err != nil {
+		return fmt.Errorf("GetSyncStates error:%s", err)
+	}
+	syncStateMap := make(map[string]uint32, len(syncStates))
+	for _, syncState := range syncStates {
+		syncStateMap[syncState.Contract] = syncState.Height
+	}
+	fmt.Printf("Contracts:\n")
+	for _, contract := range contracts {
+		state := "active"
+		if contract.State != CONTRACT_STATE_ACTIVE {
+			state = "removed"
+		}
+		height, ok := syncStateMap[contract.Contract]
+		syncHeight := "-"
+		if ok {
+			syncHeight = fmt.Sprintf("%d", height)
+		}
+		fmt.Printf("  %s\t%s\tdecoder:%s\tstart_height:%d\tsync_height:%s\n", contract.Contract, state,
+			GetTransferDecoderKind(contract.Contract), contract.StartHeight, syncHeight)
+	}
+
+	rpcMgr, err := NewRpcManager(DefConfig)
+	if err != nil {
+		return err
+	}
+	rpcMgr.probe()
+	fmt.Printf("Ontology nodes:\n")
+	for _, endpoint := range rpcMgr.GetEndpoints() {
+		fmt.Printf("  %s\t%s\theight:%d\tlatency:%dms\thealthy:%v\tcurrent:%v\t%s\n", endpoint.Address, endpoint.Type,
+			endpoint.Height, endpoint.Latency, endpoint.IsHealthy, endpoint.IsCurrent, endpoint.Error)
+	}
+	return nil
+}
+
+//runResyncContract deletes holders, transfers, token owners and allowances of a contract, and resets its sync checkpoint,
+//so it's backfilled again from start height when nodes are started. All nodes must be stopped before resync.
+//Usage: ontology-holder resync-contract -contract <contract> [-start_height <height> | -deploy_tx <tx hash>]
+func runResyncContract(args []string) error {
+	flagSet := flag.NewFlagSet("resync-contract", flag.ContinueOnError)
+	contract := flagSet.String("contract", "", "contract hash of the asset")
+	startHeight := flagSet.Int64("start_height", -1, "block height to backfill from, default the start height registered")
+	deployTx := flagSet.String("deploy_tx", "", "deploy transaction hash of contract, start_height is the height of it")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	//Transfers of native contracts start from genesis block, which is synced only once
+	if IsNativeContract(*contract) {
+		return fmt.Errorf("native contract:%s can't be resynced, use rollback instead", *contract)
+	}
+
+	contracts, err := store.GetContracts()
+	if err != nil {
+		return fmt.Errorf("GetContracts error:%s", err)
+	}
+	var monitorContract *MonitorContract
+	for _, item := range contracts {
+		if item.Contract == *contract && item.State == CONTRACT_STATE_ACTIVE {
+			monitorContract = item
+		}
+	}
+	if monitorContract == nil {
+		return fmt.Errorf("contract:%s is not monitored", *contract)
+	}
+	if *deployTx != "" {
+		rpcMgr, err := NewRpcManager(DefConfig)
+		if err != nil {
+			return err
+		}
+		rpcMgr.probe()
+		deployHeight, err := getDeployHeight(rpcMgr.GetOntSdk(), *deployTx)
+		if err != nil {
+			return err
+		}
+		*startHeight = int64(deployHeight)
+	}
+	if *startHeight >= 0 {
+		monitorContract.StartHeight = uint32(*startHeight)
+		err = store.SaveContract(monitorContract)
+		if err != nil {
+			return fmt.Errorf("SaveContract error:%s", err)
+		}
+	}
+	err = store.ResetContract(monitorContract.Contract)
+	if err != nil {
+		return fmt.Errorf("ResetContract error:%s", err)
+	}
+	fmt.Printf("Contract:%s will be synced again from height:%d\n", monitorContract.Contract, monitorContract.StartHeight)
+	return nil
+}
+
+//runVerify compares holders with balanceOf of contracts on chain, and prints the mismatches.
+//Balances may differ while nodes are syncing, since balanceOf returns the balance at the latest block.
+//Usage: ontology-holder verify [-contract <contract>] [-count <count>]
+func runVerify(args []string) error {
+	flagSet := flag.NewFlagSet("verify", flag.ContinueOnError)
+	contract := flagSet.String("contract", "", "contract hash of the asset, default all contracts")
+	count := flagSet.Int("count", 0, "max count of holders to verify, default all holders")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	rpcMgr, err := NewRpcManager(DefConfig)
+	if err != nil {
+		return err
+	}
+	rpcMgr.probe()
+	ontologyMgr := NewOntologyManager(rpcMgr, store)
+
+	verified := 0
+	mismatches := 0
+	for from := 0; *count <= 0 || verified < *count; from += RECONCILE_PAGE_SIZE {
+		holders, err := store.GetAllAssetHolders(from, RECONCILE_PAGE_SIZE)
+		if err != nil {
+			return fmt.Errorf("GetAllAssetHolders error:%s", err)
+		}
+		for _, holder := range holders {
+			if (*contract != "" && holder.Contract != *contract) || (*count > 0 && verified >= *count) {
+				continue
+			}
+			verified++
+			balance, err := ontologyMgr.BalanceOf(holder.Contract, holder.TokenId, holder.Address)
+			if err != nil {
+				return fmt.Errorf("BalanceOf contract:%s address:%s error:%s", holder.Contract, holder.Address, err)
+			}
+			if balance.Cmp(holder.Balance) != 0 {
+				mismatches++
+				fmt.Printf("%s\t%s\t%s\tindexed:%s\tchain:%s\n", holder.Contract, holder.TokenId,
+					holder.Address, holder.Balance, balance)
+			}
+		}
+		if len(holders) < RECONCILE_PAGE_SIZE {
+			break
+		}
+	}
+	fmt.Printf("Verified %d holders, %d mismatches\n", verified, mismatches)
+	if mismatches > 0 {
+		return fmt.Errorf("%d mismatches found", mismatches)
+	}
+	return nil
+}
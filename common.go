@@ -19,22 +19,35 @@
 package main
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
 
 const (
+	ZERO_ADDRESS = "0000000000000000000000000000000000000000"
+
 	NOTIFY_TRANSFER = "transfer"
+	NOTIFY_APPROVAL = "approval"
 	INCREASE_PAX = "IncreasePAX"
 	DECREASE_PAX = "DecreasePAX"
 
+	REDACTED_VALUE = "***" //secret of config and params in log
+
+	ONT_CONTRACT_ADDRESS        = "0100000000000000000000000000000000000000"
+	ONG_CONTRACT_ADDRESS        = "0200000000000000000000000000000000000000"
+	GOVERNANCE_CONTRACT_ADDRESS = "0700000000000000000000000000000000000000"
+
+	//State of SmartContactEvent
+	TX_STATE_FAILED  = 0
+	TX_STATE_SUCCESS = 1
+
 	/*
-	ONT_CONTRACT_ADDRESS               = "0100000000000000000000000000000000000000"
 	ONT_CONTRACT_ADDRESS_BASE58        = "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV"
-	ONG_CONTRACT_ADDRESS               = "0200000000000000000000000000000000000000"
 	ONG_CONTRACT_ADDRESS_BASE58        = "AFmseVrdL9f9oyCzZefL9tG6UbvhfRZMHJ"
-	GOVERNANCE_CONTRACT_ADDRESS        = "0700000000000000000000000000000000000000"
 	GOVERNANCE_CONTRACT_ADDRESS_BASE58 = "AFmseVrdL9f9oyCzZefL9tG6UbviEH9ugK"
 	*/
 )
@@ -47,6 +60,20 @@ const (
 
 	DEFAULT_UPDATE_SYNCED_BLOCK_HEIGHT_INTERVAL =  5 //s
 	DEFAULT_UPDATE_ASSET_HOLDER_COUNT_INTERVAL  =  2 //s
+
+	DEFAULT_SYNC_WORKER_SIZE = 10
+
+	DEFAULT_RPC_PROBE_INTERVAL = 5 //s
+	DEFAULT_RPC_MAX_LAG_BLOCKS = 3
+
+	DEFAULT_MAX_BALANCE_BATCH_SIZE = 1000
+)
+
+const (
+	STORE_TYPE_MYSQL   = "mysql"
+	STORE_TYPE_LEVELDB = "leveldb"
+
+	DEFAULT_LEVELDB_PATH = "./data"
 )
 
 type Heartbeat struct {
@@ -56,12 +83,54 @@ type Heartbeat struct {
 }
 
 type TxTransfer struct {
+	TxHash    string
+	Index     int
+	Height    uint32
+	Name      string
+	Contract  string
+	TokenId   string //token id of NFT, empty for fungible token
+	From      string
+	To        string
+	Amount    *big.Int
+	Timestamp uint32
+	IsInvalid bool //indexed balance of sender was lower than Amount, so only the receiver is credited
+}
+
+//TokenOwner is the owner of an OEP-5 token, Owner is ZERO_ADDRESS if the token is burned.
+type TokenOwner struct {
+	Contract string
+	TokenId  string
+	Owner    string
+	Height   uint32
+}
+
+//Allowance is the amount Spender is approved to transfer from Owner, it's set by the last approval event.
+type Allowance struct {
+	Contract string
+	Owner    string
+	Spender  string
+	Amount   *big.Int
 	TxHash   string
-	Name     string
+	Height   uint32
+}
+
+const (
+	CONTRACT_STATE_REMOVED = 0
+	CONTRACT_STATE_ACTIVE  = 1
+)
+
+//MonitorContract is a contract registered in the contracts table.
+//StartHeight is the first block height to scan for the contract.
+type MonitorContract struct {
+	Contract    string
+	StartHeight uint32
+	State       int
+}
+
+//SyncState is the last block height of contract which has been fully committed to store
+type SyncState struct {
 	Contract string
-	From     string
-	To       string
-	Amount   uint64
+	Height   uint32
 }
 
 type TxEventNotify struct {
@@ -75,13 +144,107 @@ type TxEventNotify struct {
 type AssetHolder struct {
 	Address  string
 	Contract string
-	Balance  uint64
+	TokenId  string //token id of OEP-8, empty for other tokens
+	Balance  *big.Int
 	Transactions int
 }
 
+//Key returns the key of holder, a holder is unique by address, contract and token id
+func (this *AssetHolder) Key() string {
+	return this.Address + this.Contract + this.TokenId
+}
+
+//HolderCursor is the position of the last holder of a page, holders are ordered by balance and address.
+//Height is the block height of snapshot if IsSnapshot is true.
+type HolderCursor struct {
+	Balance    *big.Int
+	Address    string
+	IsSnapshot bool
+	Height     uint32
+}
+
+func NewHolderCursor(holder *AssetHolder, isSnapshot bool, height uint32) *HolderCursor {
+	return &HolderCursor{
+		Balance:    holder.Balance,
+		Address:    holder.Address,
+		IsSnapshot: isSnapshot,
+		Height:     height,
+	}
+}
+
+//IsAfter returns whether holder is after the cursor, addresses of the same balance are in the order of balance
+func (this *HolderCursor) IsAfter(holder *AssetHolder, isDesc bool) bool {
+	return IsHolderBefore(this.Balance, this.Address, holder, isDesc)
+}
+
+//IsHolderBefore returns whether the holder of balance and address is before holder,
+//holders are ordered by balance, then by address in the same direction
+func IsHolderBefore(balance *big.Int, address string, holder *AssetHolder, isDesc bool) bool {
+	cmp := balance.Cmp(holder.Balance)
+	if cmp == 0 {
+		cmp = strings.Compare(address, holder.Address)
+	}
+	if isDesc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+//IsTransferBefore returns whether transfer a is before b in transfer history,
+//which is ordered by height in descending order, then by tx hash and notify index
+func IsTransferBefore(a, b *TxTransfer) bool {
+	if a.Height != b.Height {
+		return a.Height > b.Height
+	}
+	if a.TxHash != b.TxHash {
+		return a.TxHash < b.TxHash
+	}
+	return a.Index < b.Index
+}
+
+//Encode returns the opaque cursor string of api
+func (this *HolderCursor) Encode() string {
+	text := this.Balance.String() + "," + this.Address
+	if this.IsSnapshot {
+		text += "," + strconv.FormatUint(uint64(this.Height), 10)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(text))
+}
+
+func DecodeHolderCursor(cursor string) (*HolderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("base64 decode error:%s", err)
+	}
+	fields := strings.Split(string(data), ",")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, fmt.Errorf("invalid cursor:%s", data)
+	}
+	balance, ok := new(big.Int).SetString(fields[0], 10)
+	if !ok || balance.Sign() < 0 || fields[1] == "" {
+		return nil, fmt.Errorf("invalid cursor:%s", data)
+	}
+	holderCursor := &HolderCursor{
+		Balance: balance,
+		Address: fields[1],
+	}
+	if len(fields) == 3 {
+		height, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor height:%s", err)
+		}
+		holderCursor.IsSnapshot = true
+		holderCursor.Height = uint32(height)
+	}
+	return holderCursor, nil
+}
+
 type HttpServerRequest struct {
-	Method string
-	Qid    string
+	Method     string
+	HttpMethod string
+	//Authorization is the Authorization header, admin token is read from it
+	Authorization string
+	Qid           string
 	Params map[string]string
 }
 
@@ -121,6 +284,8 @@ const (
 	ERR_SUCCESS        = 0
 	ERR_INVALID_PARAMS = 1001
 	ERR_INVALID_METHOD = 1002
+	ERR_FORBIDDEN      = 1003
+	ERR_NOT_FOUND      = 1004 //contract is not monitored, or token does not exist
 	ERR_INTERNAL       = 9999
 )
 
@@ -128,6 +293,8 @@ var HttpServerErrorDesc = map[uint32]string{
 	ERR_SUCCESS:        "",
 	ERR_INVALID_PARAMS: "invalid params",
 	ERR_INVALID_METHOD: "invalid method",
+	ERR_FORBIDDEN:      "forbidden",
+	ERR_NOT_FOUND:      "not found",
 	ERR_INTERNAL:       "internal error",
 }
 
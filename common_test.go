@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"math/big"
+	"testing"
+)
+
+func TestHolderCursorEncode(t *testing.T) {
+	balance, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []*HolderCursor{
+		{Balance: big.NewInt(0), Address: TEST_ADDRESS_A},
+		{Balance: balance, Address: TEST_ADDRESS_B},
+		{Balance: big.NewInt(100), Address: TEST_ADDRESS_A, IsSnapshot: true, Height: 0},
+		{Balance: big.NewInt(100), Address: TEST_ADDRESS_A, IsSnapshot: true, Height: 4294967295},
+	}
+	for _, test := range tests {
+		cursor, err := DecodeHolderCursor(test.Encode())
+		if err != nil {
+			t.Fatalf("DecodeHolderCursor %+v error:%s", test, err)
+		}
+		if cursor.Balance.Cmp(test.Balance) != 0 || cursor.Address != test.Address ||
+			cursor.IsSnapshot != test.IsSnapshot || cursor.Height != test.Height {
+			t.Errorf("decoded cursor %+v, expect %+v", cursor, test)
+		}
+	}
+}
+
+func TestDecodeInvalidHolderCursor(t *testing.T) {
+	tests := []string{
+		"",
+		"!!!",
+		encodeTestCursor("100"),
+		encodeTestCursor("abc," + TEST_ADDRESS_A),
+		encodeTestCursor("-1," + TEST_ADDRESS_A),
+		encodeTestCursor("100,"),
+		encodeTestCursor("100," + TEST_ADDRESS_A + ",4294967296"),
+		encodeTestCursor("100," + TEST_ADDRESS_A + ",1,2"),
+	}
+	for _, test := range tests {
+		_, err := DecodeHolderCursor(test)
+		if err == nil {
+			t.Errorf("DecodeHolderCursor %q should fail", test)
+		}
+	}
+}
+
+func encodeTestCursor(text string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(text))
+}
+
+func TestHolderCursorIsAfter(t *testing.T) {
+	cursor := &HolderCursor{Balance: big.NewInt(100), Address: TEST_ADDRESS_B}
+	tests := []struct {
+		balance int64
+		address string
+		isDesc  bool
+		isAfter bool
+	}{
+		{99, TEST_ADDRESS_C, true, true},
+		{101, TEST_ADDRESS_C, true, false},
+		//Addresses of the same balance are in the order of balance
+		{100, TEST_ADDRESS_A, true, true},
+		{100, TEST_ADDRESS_C, true, true},
+		{100, TEST_ADDRESS_B, true, false},
+		{100, "ffffffffffffffffffffffffffffffffffffffff", true, false},
+		{101, TEST_ADDRESS_A, false, true},
+		{99, TEST_ADDRESS_A, false, false},
+		{100, TEST_ADDRESS_A, false, false},
+		{100, "ffffffffffffffffffffffffffffffffffffffff", false, true},
+	}
+	for _, test := range tests {
+		holder := &AssetHolder{Address: test.address, Balance: big.NewInt(test.balance)}
+		if cursor.IsAfter(holder, test.isDesc) != test.isAfter {
+			t.Errorf("IsAfter balance:%d address:%s desc:%v, expect %v", test.balance, test.address, test.isDesc, test.isAfter)
+		}
+	}
+}
@@ -21,6 +21,8 @@ package main
 var DefConfig = &Config{}
 
 type Config struct {
+	StoreType                       string
+	LevelDBPath                     string
 	MySqlAddress                    string
 	MySqlUserName                   string
 	MySqlPassword                   string
@@ -34,12 +36,39 @@ type Config struct {
 	UpdateHolderCountInterval       uint32
 	UpdateSyncedBlockHeightInterval uint32
 	OntologyRpcAddress              string
+	OntologyRpcAddresses            []string
+	OntologyRestAddresses           []string
+	RpcProbeInterval                uint32
+	RpcMaxLagBlocks                 uint32
 	BlockHeight                     uint32
 	HttpServerPort                  uint32
 	DBBatchSize                     uint32
 	DBBatchTime                     uint32
+	SyncWorkerSize                  uint32
+	ExcludeFailedTx                 bool
+	NativeMode                      bool
+	ReconcileInterval               uint32
+	ReconcileSampleSize             uint32
+	ReconcileAutoCorrect            bool
 	MaxQueryPageSize                uint32
+	MaxBalanceBatchSize             uint32
+	AdminToken                      string
 	Contracts                       []string
+	ContractStartHeights            map[string]uint32
+	ContractDeployTxs               map[string]string
+	ContractDecoders                map[string]string
+}
+
+//redactConfig returns a copy of cfg for log, secrets are masked if they are set
+func redactConfig(cfg *Config) *Config {
+	redacted := *cfg
+	if redacted.MySqlPassword != "" {
+		redacted.MySqlPassword = REDACTED_VALUE
+	}
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = REDACTED_VALUE
+	}
+	return &redacted
 }
 
 func (this *Config) GetHeartbeatUpdateInterval() uint32 {
@@ -69,3 +98,59 @@ func (this *Config) GetSyncedBlockHeightInterval() uint32 {
 	}
 	return this.UpdateSyncedBlockHeightInterval
 }
+
+func (this *Config) GetStoreType() string {
+	if this.StoreType == "" {
+		return STORE_TYPE_MYSQL
+	}
+	return this.StoreType
+}
+
+func (this *Config) GetLevelDBPath() string {
+	if this.LevelDBPath == "" {
+		return DEFAULT_LEVELDB_PATH
+	}
+	return this.LevelDBPath
+}
+
+func (this *Config) GetSyncWorkerSize() uint32 {
+	if this.SyncWorkerSize == 0 {
+		return DEFAULT_SYNC_WORKER_SIZE
+	}
+	return this.SyncWorkerSize
+}
+
+//GetOntologyRpcAddresses returns OntologyRpcAddress and OntologyRpcAddresses without duplicates
+func (this *Config) GetOntologyRpcAddresses() []string {
+	addresses := make([]string, 0, len(this.OntologyRpcAddresses)+1)
+	if this.OntologyRpcAddress != "" {
+		addresses = append(addresses, this.OntologyRpcAddress)
+	}
+	for _, address := range this.OntologyRpcAddresses {
+		if address != this.OntologyRpcAddress {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func (this *Config) GetRpcProbeInterval() uint32 {
+	if this.RpcProbeInterval == 0 {
+		return DEFAULT_RPC_PROBE_INTERVAL
+	}
+	return this.RpcProbeInterval
+}
+
+func (this *Config) GetMaxBalanceBatchSize() uint32 {
+	if this.MaxBalanceBatchSize == 0 {
+		return DEFAULT_MAX_BALANCE_BATCH_SIZE
+	}
+	return this.MaxBalanceBatchSize
+}
+
+func (this *Config) GetRpcMaxLagBlocks() uint32 {
+	if this.RpcMaxLagBlocks == 0 {
+		return DEFAULT_RPC_MAX_LAG_BLOCKS
+	}
+	return this.RpcMaxLagBlocks
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	ontsdk "github.com/ontio/ontology-go-sdk"
+	"sort"
+	"sync"
+)
+
+var DefContractMgr = NewContractManager()
+
+const (
+	CONTRACT_SYNC_BACKFILL = "backfill"
+	CONTRACT_SYNC_LIVE     = "live"
+)
+
+//ContractInfo is the sync status of a monitored contract.
+//Height is the last backfilled height of a backfill contract.
+type ContractInfo struct {
+	Contract    string `json:"contract"`
+	StartHeight uint32 `json:"start_height"`
+	SyncMode    string `json:"sync_mode"`
+	Height      uint32 `json:"height"`
+}
+
+//ContractManager is the runtime set of monitored contracts.
+//A new contract is backfilled by its own worker until it catches up with the live sync,
+//then it's switched to live, and transfers of it are decoded by the live sync.
+type ContractManager struct {
+	contracts     map[string]*ContractInfo
+	backfills     map[string]bool //contracts with running backfill worker
+	decodedHeight uint32
+	lock          sync.RWMutex
+}
+
+func NewContractManager() *ContractManager {
+	return &ContractManager{
+		contracts: make(map[string]*ContractInfo),
+		backfills: make(map[string]bool),
+	}
+}
+
+func (this *ContractManager) IsMonitor(contract string) bool {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	_, ok := this.contracts[contract]
+	return ok
+}
+
+func (this *ContractManager) IsBackfill(contract string) bool {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	info, ok := this.contracts[contract]
+	return ok && info.SyncMode == CONTRACT_SYNC_BACKFILL
+}
+
+func (this *ContractManager) GetLiveContracts() []string {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	contracts := make([]string, 0, len(this.contracts))
+	for contract, info := range this.contracts {
+		if info.SyncMode == CONTRACT_SYNC_LIVE {
+			contracts = append(contracts, contract)
+		}
+	}
+	return contracts
+}
+
+func (this *ContractManager) GetContractInfo(contract string) *ContractInfo {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	info, ok := this.contracts[contract]
+	if !ok {
+		return nil
+	}
+	item := *info
+	return &item
+}
+
+func (this *ContractManager) GetContractInfos() []*ContractInfo {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	infos := make([]*ContractInfo, 0, len(this.contracts))
+	for _, info := range this.contracts {
+		item := *info
+		infos = append(infos, &item)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Contract < infos[j].Contract
+	})
+	return infos
+}
+
+//BeginDecode is called by live sync before decoding the block of height.
+//It returns the live contracts which should be decoded in the block.
+func (this *ContractManager) BeginDecode(height uint32) map[string]bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.decodedHeight = height
+	contracts := make(map[string]bool, len(this.contracts))
+	for contract, info := range this.contracts {
+		if info.SyncMode == CONTRACT_SYNC_LIVE {
+			contracts[contract] = true
+		}
+	}
+	return contracts
+}
+
+func (this *ContractManager) GetDecodedHeight() uint32 {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.decodedHeight
+}
+
+func (this *ContractManager) SetDecodedHeight(height uint32) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.decodedHeight = height
+}
+
+//TryGoLive switches contract to live if it has been backfilled to the last height decoded by live sync.
+func (this *ContractManager) TryGoLive(contract string, backfilledHeight uint32) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	info, ok := this.contracts[contract]
+	if !ok || backfilledHeight < this.decodedHeight {
+		return false
+	}
+	info.SyncMode = CONTRACT_SYNC_LIVE
+	info.Height = backfilledHeight
+	return true
+}
+
+func (this *ContractManager) SetBackfillHeight(contract string, height uint32) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	info, ok := this.contracts[contract]
+	if ok {
+		info.Height = height
+	}
+}
+
+//TryStartBackfill marks the backfill worker of contract running, returns false if it's already running.
+//The mark is kept even if contract is removed, so a re-added contract never has two workers.
+func (this *ContractManager) TryStartBackfill(contract string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	info, ok := this.contracts[contract]
+	if !ok || this.backfills[contract] || info.SyncMode != CONTRACT_SYNC_BACKFILL {
+		return false
+	}
+	this.backfills[contract] = true
+	return true
+}
+
+func (this *ContractManager) StopBackfill(contract string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	delete(this.backfills, contract)
+}
+
+func (this *ContractManager) GetBackfillContracts() []*ContractInfo {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	infos := make([]*ContractInfo, 0)
+	for _, info := range this.contracts {
+		if info.SyncMode == CONTRACT_SYNC_BACKFILL {
+			item := *info
+			infos = append(infos, &item)
+		}
+	}
+	return infos
+}
+
+//Update reloads contracts from store. syncStates is the sync checkpoint of contracts, liveHeight is the checkpoint of live sync.
+//If isRefresh is true, sync mode of existing contracts are refreshed from store as well, it's used by standby nodes.
+func (this *ContractManager) Update(contracts []*MonitorContract, syncStates map[string]uint32, liveHeight uint32, isRefresh bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	activeContracts := make(map[string]bool, len(contracts))
+	for _, contract := range contracts {
+		if contract.State != CONTRACT_STATE_ACTIVE {
+			continue
+		}
+		activeContracts[contract.Contract] = true
+		_, ok := this.contracts[contract.Contract]
+		if ok && !isRefresh {
+			continue
+		}
+		height, ok := syncStates[contract.Contract]
+		if !ok {
+			height = 0
+			if contract.StartHeight > 0 {
+				height = contract.StartHeight - 1
+			}
+		}
+		syncMode := CONTRACT_SYNC_BACKFILL
+		if height >= liveHeight && height >= this.decodedHeight {
+			syncMode = CONTRACT_SYNC_LIVE
+		}
+		this.contracts[contract.Contract] = &ContractInfo{
+			Contract:    contract.Contract,
+			StartHeight: contract.StartHeight,
+			SyncMode:    syncMode,
+			Height:      height,
+		}
+	}
+	for contract := range this.contracts {
+		if !activeContracts[contract] {
+			delete(this.contracts, contract)
+		}
+	}
+}
+
+//getDeployHeight returns the block height of deployTx, the deploy transaction of contract, where transfers of contract start.
+func getDeployHeight(ontSdk *ontsdk.OntologySdk, deployTx string) (uint32, error) {
+	height, err := ontSdk.GetBlockHeightByTxHash(deployTx)
+	if err != nil {
+		return 0, fmt.Errorf("GetBlockHeightByTxHash:%s error:%s", deployTx, err)
+	}
+	return height, nil
+}
+
+//addMonitorContract registers contract to store, transfers of it are backfilled from startHeight.
+//A contract removed before is resumed from its sync checkpoint.
+func addMonitorContract(store HolderStore, contract string, startHeight uint32) error {
+	if !IsContractAddress(contract) {
+		return fmt.Errorf("invalid contract:%s", contract)
+	}
+	err := store.SaveContract(&MonitorContract{
+		Contract:    contract,
+		StartHeight: startHeight,
+		State:       CONTRACT_STATE_ACTIVE,
+	})
+	if err != nil {
+		return fmt.Errorf("SaveContract error:%s", err)
+	}
+	return nil
+}
+
+//removeMonitorContract stops monitoring contract, holders and transfers of it are kept.
+func removeMonitorContract(store HolderStore, contract string) error {
+	contracts, err := store.GetContracts()
+	if err != nil {
+		return fmt.Errorf("GetContracts error:%s", err)
+	}
+	for _, item := range contracts {
+		if item.Contract != contract || item.State != CONTRACT_STATE_ACTIVE {
+			continue
+		}
+		item.State = CONTRACT_STATE_REMOVED
+		err = store.SaveContract(item)
+		if err != nil {
+			return fmt.Errorf("SaveContract error:%s", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("contract:%s is not monitored", contract)
+}
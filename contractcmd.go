@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+//runContract manages monitored contracts in store, running nodes reload them in UpdateSyncedBlockHeightInterval.
+//Usage: ontology-holder contract add -contract <contract> [-start_height <height> | -deploy_tx <tx hash>]
+//       ontology-holder contract remove -contract <contract>
+//       ontology-holder contract list
+func runContract(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: contract add|remove|list")
+	}
+	action := args[0]
+	flagSet := flag.NewFlagSet("contract "+action, flag.ContinueOnError)
+	contract := flagSet.String("contract", "", "contract hash of the asset")
+	startHeight := flagSet.Uint("start_height", 0, "block height to backfill from, such as the deploy height of contract")
+	deployTx := flagSet.String("deploy_tx", "", "deploy transaction hash of contract, start_height is the height of it")
+	err := flagSet.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	switch action {
+	case "add":
+		if *deployTx != "" {
+			rpcMgr, err := NewRpcManager(DefConfig)
+			if err != nil {
+				return err
+			}
+			rpcMgr.probe()
+			deployHeight, err := getDeployHeight(rpcMgr.GetOntSdk(), *deployTx)
+			if err != nil {
+				return err
+			}
+			*startHeight = uint(deployHeight)
+		}
+		return addMonitorContract(store, *contract, uint32(*startHeight))
+	case "remove":
+		return removeMonitorContract(store, *contract)
+	case "list":
+		contracts, err := store.GetContracts()
+		if err != nil {
+			return fmt.Errorf("GetContracts error:%s", err)
+		}
+		for _, item := range contracts {
+			state := "active"
+			if item.State != CONTRACT_STATE_ACTIVE {
+				state = "removed"
+			}
+			fmt.Printf("%s\t%d\t%s\n", item.Contract, item.StartHeight, state)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown contract action:%s", action)
+	}
+}
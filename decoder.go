@@ -0,0 +1,402 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	sdkcom "github.com/ontio/ontology-go-sdk/common"
+	"github.com/ontio/ontology/common"
+	"math/big"
+)
+
+const (
+	DECODER_NATIVE        = "native"
+	DECODER_OEP4          = "oep4"
+	DECODER_OEP4_MINTBURN = "oep4_mintburn"
+	DECODER_OEP5          = "oep5"
+	DECODER_OEP8          = "oep8"
+	DECODER_WASM_OEP4     = "wasm_oep4"
+)
+
+//TransferDecoder decodes a transfer from notify of contract.
+//Name, From, To and Amount of the returned transfer are set, the others are set by caller.
+type TransferDecoder interface {
+	Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool)
+}
+
+//ApprovalDecoder decodes an approval from notify of contract, it's optional for TransferDecoder.
+//Contract, Owner, Spender and Amount of the returned allowance are set, the others are set by caller.
+type ApprovalDecoder interface {
+	DecodeApproval(notify *sdkcom.NotifyEventInfo) (*Allowance, bool)
+}
+
+var transferDecoders = make(map[string]TransferDecoder)
+
+//defaultContractDecoders is the decoder kind of contracts which is not standard OEP-4, if it's not set in config.
+var defaultContractDecoders = map[string]string{
+	"0100000000000000000000000000000000000000": DECODER_NATIVE,
+	"0200000000000000000000000000000000000000": DECODER_NATIVE,
+	"6bbc07bae862db0d7867e4e5b1a13c663e2b4bc8": DECODER_OEP4_MINTBURN,
+}
+
+func init() {
+	RegTransferDecoder(DECODER_NATIVE, &NativeDecoder{})
+	RegTransferDecoder(DECODER_OEP4, &Oep4Decoder{})
+	RegTransferDecoder(DECODER_OEP4_MINTBURN, &Oep4MintBurnDecoder{MintName: INCREASE_PAX, BurnName: DECREASE_PAX})
+	RegTransferDecoder(DECODER_OEP5, &Oep5Decoder{})
+	RegTransferDecoder(DECODER_OEP8, &Oep8Decoder{})
+	RegTransferDecoder(DECODER_WASM_OEP4, &WasmOep4Decoder{})
+}
+
+func RegTransferDecoder(kind string, decoder TransferDecoder) {
+	transferDecoders[kind] = decoder
+}
+
+//GetTransferDecoderKind returns the decoder kind of contract, which is set by ContractDecoders in config.
+//Default is OEP-4.
+func GetTransferDecoderKind(contract string) string {
+	kind, ok := DefConfig.ContractDecoders[contract]
+	if ok {
+		return kind
+	}
+	kind, ok = defaultContractDecoders[contract]
+	if ok {
+		return kind
+	}
+	return DECODER_OEP4
+}
+
+func GetTransferDecoder(contract string) TransferDecoder {
+	return transferDecoders[GetTransferDecoderKind(contract)]
+}
+
+//IsNFTContract checks contract is an OEP-5 contract, whose owner of every token is indexed
+func IsNFTContract(contract string) bool {
+	return GetTransferDecoderKind(contract) == DECODER_OEP5
+}
+
+//IsMultiTokenContract checks contract is an OEP-8 contract, whose holders are indexed by token id
+func IsMultiTokenContract(contract string) bool {
+	return GetTransferDecoderKind(contract) == DECODER_OEP8
+}
+
+//IsNativeContract checks contract is a native contract, ONT or ONG
+func IsNativeContract(contract string) bool {
+	return GetTransferDecoderKind(contract) == DECODER_NATIVE
+}
+
+//IsWasmContract checks contract is a wasm OEP-4 contract, which is invoked by wasm vm
+func IsWasmContract(contract string) bool {
+	return GetTransferDecoderKind(contract) == DECODER_WASM_OEP4
+}
+
+//holderTokenId returns the token id of holders changed by transfer, it's empty except for OEP-8
+func holderTokenId(transfer *TxTransfer) string {
+	if IsMultiTokenContract(transfer.Contract) {
+		return transfer.TokenId
+	}
+	return ""
+}
+
+//CheckContractDecoders checks the decoder kinds in config are registered
+func CheckContractDecoders() error {
+	for contract, kind := range DefConfig.ContractDecoders {
+		_, ok := transferDecoders[kind]
+		if !ok {
+			return fmt.Errorf("unknown decoder:%s of contract:%s", kind, contract)
+		}
+	}
+	return nil
+}
+
+//NativeDecoder decodes transfer of native contracts ONT and ONG, states is ["transfer", from, to, amount],
+//from and to are base58 addresses.
+type NativeDecoder struct{}
+
+func (this *NativeDecoder) Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool) {
+	states, ok := notify.States.([]interface{})
+	if !ok || len(states) != 4 || states[0] != NOTIFY_TRANSFER {
+		return nil, false
+	}
+	from, ok := notifyBase58Address(states[1])
+	if !ok {
+		return nil, false
+	}
+	to, ok := notifyBase58Address(states[2])
+	if !ok {
+		return nil, false
+	}
+	amount, ok := BigIntFromNotifyValue(states[3])
+	if !ok {
+		return nil, false
+	}
+	return &TxTransfer{Name: NOTIFY_TRANSFER, From: from, To: to, Amount: amount}, true
+}
+
+//Oep4Decoder decodes transfer of OEP-4, states is [hex("transfer"), from, to, amount], amount is neo bytes.
+type Oep4Decoder struct{}
+
+func (this *Oep4Decoder) Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool) {
+	states, ok := notify.States.([]interface{})
+	if !ok || len(states) != 4 || notifyName(states[0]) != NOTIFY_TRANSFER {
+		return nil, false
+	}
+	return decodeOep4Transfer(states)
+}
+
+//DecodeApproval decodes approval of OEP-4, states is [hex("approval"), owner, spender, amount], amount is neo bytes.
+func (this *Oep4Decoder) DecodeApproval(notify *sdkcom.NotifyEventInfo) (*Allowance, bool) {
+	return decodeOep4Approval(notify)
+}
+
+//Oep4MintBurnDecoder decodes transfer of OEP-4, and the mint and burn events of tokens like PAX.
+//Mint states is [hex(MintName), to, amount, ...], burn states is [hex(BurnName), from, ..., amount].
+type Oep4MintBurnDecoder struct {
+	MintName string
+	BurnName string
+}
+
+func (this *Oep4MintBurnDecoder) Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool) {
+	states, ok := notify.States.([]interface{})
+	if !ok || len(states) != 4 {
+		return nil, false
+	}
+	name := notifyName(states[0])
+	switch name {
+	case NOTIFY_TRANSFER:
+		return decodeOep4Transfer(states)
+	case this.MintName:
+		to, ok := states[1].(string)
+		if !ok {
+			return nil, false
+		}
+		amount, ok := notifyNeoBytesInt(states[2])
+		if !ok {
+			return nil, false
+		}
+		return &TxTransfer{Name: name, From: ZERO_ADDRESS, To: to, Amount: amount}, true
+	case this.BurnName:
+		from, ok := states[1].(string)
+		if !ok {
+			return nil, false
+		}
+		amount, ok := notifyNeoBytesInt(states[3])
+		if !ok {
+			return nil, false
+		}
+		return &TxTransfer{Name: name, From: from, To: ZERO_ADDRESS, Amount: amount}, true
+	}
+	return nil, false
+}
+
+func (this *Oep4MintBurnDecoder) DecodeApproval(notify *sdkcom.NotifyEventInfo) (*Allowance, bool) {
+	return decodeOep4Approval(notify)
+}
+
+//Oep5Decoder decodes transfer of OEP-5, states is [hex("transfer"), from, to, tokenId].
+//Amount of the transfer is 1, so balance of holder is the count of tokens it owns.
+type Oep5Decoder struct{}
+
+func (this *Oep5Decoder) Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool) {
+	states, ok := notify.States.([]interface{})
+	if !ok || len(states) != 4 || notifyName(states[0]) != NOTIFY_TRANSFER {
+		return nil, false
+	}
+	from, ok := states[1].(string)
+	if !ok {
+		return nil, false
+	}
+	to, ok := states[2].(string)
+	if !ok {
+		return nil, false
+	}
+	tokenId, ok := states[3].(string)
+	if !ok || tokenId == "" {
+		return nil, false
+	}
+	//Mint and burn may be notified with empty address
+	if from == "" {
+		from = ZERO_ADDRESS
+	}
+	if to == "" {
+		to = ZERO_ADDRESS
+	}
+	return &TxTransfer{Name: NOTIFY_TRANSFER, TokenId: tokenId, From: from, To: to, Amount: big.NewInt(1)}, true
+}
+
+//Oep8Decoder decodes transfer of OEP-8, states is [hex("transfer"), from, to, tokenId, amount], amount is neo bytes.
+type Oep8Decoder struct{}
+
+func (this *Oep8Decoder) Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool) {
+	states, ok := notify.States.([]interface{})
+	if !ok || len(states) != 5 || notifyName(states[0]) != NOTIFY_TRANSFER {
+		return nil, false
+	}
+	from, ok := states[1].(string)
+	if !ok {
+		return nil, false
+	}
+	to, ok := states[2].(string)
+	if !ok {
+		return nil, false
+	}
+	tokenId, ok := states[3].(string)
+	if !ok || tokenId == "" {
+		return nil, false
+	}
+	amount, ok := notifyNeoBytesInt(states[4])
+	if !ok {
+		return nil, false
+	}
+	//Mint and burn may be notified with empty address
+	if from == "" {
+		from = ZERO_ADDRESS
+	}
+	if to == "" {
+		to = ZERO_ADDRESS
+	}
+	return &TxTransfer{Name: NOTIFY_TRANSFER, TokenId: tokenId, From: from, To: to, Amount: amount}, true
+}
+
+//WasmOep4Decoder decodes transfer of wasm OEP-4, states is ["transfer", from, to, amount],
+//from and to are base58 addresses, amount is decimal string.
+//States may also be the hex of notify serialized by EventBuilder, see wasmNotifyStates.
+type WasmOep4Decoder struct{}
+
+func (this *WasmOep4Decoder) Decode(notify *sdkcom.NotifyEventInfo) (*TxTransfer, bool) {
+	states, ok := wasmNotifyStates(notify.States)
+	if !ok || len(states) != 4 || states[0] != NOTIFY_TRANSFER {
+		return nil, false
+	}
+	from, ok := notifyBase58Address(states[1])
+	if !ok {
+		return nil, false
+	}
+	to, ok := notifyBase58Address(states[2])
+	if !ok {
+		return nil, false
+	}
+	amount, ok := BigIntFromNotifyValue(states[3])
+	if !ok {
+		return nil, false
+	}
+	return &TxTransfer{Name: NOTIFY_TRANSFER, From: from, To: to, Amount: amount}, true
+}
+
+//DecodeApproval decodes approval of wasm OEP-4, states is ["approval", owner, spender, amount]
+func (this *WasmOep4Decoder) DecodeApproval(notify *sdkcom.NotifyEventInfo) (*Allowance, bool) {
+	states, ok := wasmNotifyStates(notify.States)
+	if !ok || len(states) != 4 || states[0] != NOTIFY_APPROVAL {
+		return nil, false
+	}
+	owner, ok := notifyBase58Address(states[1])
+	if !ok {
+		return nil, false
+	}
+	spender, ok := notifyBase58Address(states[2])
+	if !ok {
+		return nil, false
+	}
+	amount, ok := BigIntFromNotifyValue(states[3])
+	if !ok {
+		return nil, false
+	}
+	return &Allowance{Contract: notify.ContractAddress, Owner: owner, Spender: spender, Amount: amount}, true
+}
+
+func decodeOep4Approval(notify *sdkcom.NotifyEventInfo) (*Allowance, bool) {
+	states, ok := notify.States.([]interface{})
+	if !ok || len(states) != 4 || notifyName(states[0]) != NOTIFY_APPROVAL {
+		return nil, false
+	}
+	owner, ok := states[1].(string)
+	if !ok {
+		return nil, false
+	}
+	spender, ok := states[2].(string)
+	if !ok {
+		return nil, false
+	}
+	amount, ok := notifyNeoBytesInt(states[3])
+	if !ok {
+		return nil, false
+	}
+	return &Allowance{Contract: notify.ContractAddress, Owner: owner, Spender: spender, Amount: amount}, true
+}
+
+func decodeOep4Transfer(states []interface{}) (*TxTransfer, bool) {
+	from, ok := states[1].(string)
+	if !ok {
+		return nil, false
+	}
+	to, ok := states[2].(string)
+	if !ok {
+		return nil, false
+	}
+	amount, ok := notifyNeoBytesInt(states[3])
+	if !ok {
+		return nil, false
+	}
+	return &TxTransfer{Name: NOTIFY_TRANSFER, From: from, To: to, Amount: amount}, true
+}
+
+//notifyName decodes the hex event name of neovm notify
+func notifyName(state interface{}) string {
+	hexName, ok := state.(string)
+	if !ok {
+		return ""
+	}
+	name, err := hex.DecodeString(hexName)
+	if err != nil {
+		return ""
+	}
+	return string(name)
+}
+
+//notifyNeoBytesInt decodes the amount of neovm notify, a negative amount is invalid,
+//otherwise a contract could credit the sender of a transfer
+func notifyNeoBytesInt(state interface{}) (*big.Int, bool) {
+	hexValue, ok := state.(string)
+	if !ok {
+		return nil, false
+	}
+	value, err := hex.DecodeString(hexValue)
+	if err != nil {
+		return nil, false
+	}
+	amount := common.BigIntFromNeoBytes(value)
+	if amount.Sign() < 0 {
+		return nil, false
+	}
+	return amount, true
+}
+
+//notifyBase58Address converts base58 address of notify to hex address
+func notifyBase58Address(state interface{}) (string, bool) {
+	base58Address, ok := state.(string)
+	if !ok {
+		return "", false
+	}
+	address, err := common.AddressFromBase58(base58Address)
+	if err != nil {
+		return "", false
+	}
+	return address.ToHexString(), true
+}
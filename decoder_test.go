@@ -0,0 +1,311 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	sdkcom "github.com/ontio/ontology-go-sdk/common"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+const (
+	TEST_WASM_CONTRACT = "c6ff2b8f4cc5b6a9eb3ae8ab7b0e3e8a2b5d1e47"
+	//TEST_WASM_TRANSFER_NOTIFY is transfer(from, to, 10^21) serialized by EventBuilder of wasm contract:
+	//"evt\0", list of 4, string "transfer", address from, address to, u128 amount
+	TEST_WASM_TRANSFER_NOTIFY = "657674001004000000" +
+		"01080000007472616e73666572" +
+		"020102030405060708090a0b0c0d0e0f1011121314" +
+		"022122232425262728292a2b2c2d2e2f3031323334" +
+		"040000a0dec5adc9353600000000000000"
+	TEST_WASM_FROM_BASE58 = "AFsCjUGzicZmXQtWpwVt6fQTZyaVe7bfEk"
+	TEST_WASM_TO_BASE58   = "AJo4qjAkSQEPvejXEKRwKyNoPzyXD8EYhA"
+	TEST_WASM_FROM        = "14131211100f0e0d0c0b0a090807060504030201"
+	TEST_WASM_TO          = "34333231302f2e2d2c2b2a292827262524232221"
+)
+
+func TestWasmOep4DecoderDecode(t *testing.T) {
+	amount, _ := new(big.Int).SetString("1000000000000000000000", 10)
+	cases := []struct {
+		name   string
+		states interface{}
+		ok     bool
+	}{
+		{"serialized", TEST_WASM_TRANSFER_NOTIFY, true},
+		{"decoded by node", []interface{}{"transfer", TEST_WASM_FROM_BASE58, TEST_WASM_TO_BASE58, "1000000000000000000000"}, true},
+		{"serialized without prefix", TEST_WASM_TRANSFER_NOTIFY[8:], true},
+		{"truncated", TEST_WASM_TRANSFER_NOTIFY[:len(TEST_WASM_TRANSFER_NOTIFY)-2], false},
+		{"trailing bytes", TEST_WASM_TRANSFER_NOTIFY + "00", false},
+		{"not hex", "evt", false},
+		{"other event", "657674001001000000" + "0108000000" + "617070726f76616c", false},
+		{"negative amount", []interface{}{"transfer", TEST_WASM_FROM_BASE58, TEST_WASM_TO_BASE58, "-1"}, false},
+	}
+	decoder := &WasmOep4Decoder{}
+	for _, c := range cases {
+		transfer, ok := decoder.Decode(&sdkcom.NotifyEventInfo{ContractAddress: TEST_WASM_CONTRACT, States: c.states})
+		if ok != c.ok {
+			t.Fatalf("%s: ok:%v, expected:%v", c.name, ok, c.ok)
+		}
+		if !ok {
+			continue
+		}
+		if transfer.Name != NOTIFY_TRANSFER || transfer.From != TEST_WASM_FROM || transfer.To != TEST_WASM_TO {
+			t.Errorf("%s: transfer:%s from:%s to:%s", c.name, transfer.Name, transfer.From, transfer.To)
+		}
+		if transfer.Amount.Cmp(amount) != 0 {
+			t.Errorf("%s: amount:%s, expected:%s", c.name, transfer.Amount, amount)
+		}
+	}
+}
+
+func TestParseWasmNotify(t *testing.T) {
+	//list of bytearray 0xabcd, bool true, i128 -2, h256 and nested list of string "a"
+	data := "1005000000" +
+		"0002000000abcd" +
+		"0301" +
+		"04feffffffffffffffffffffffffffffff" +
+		"05" + "0000000000000000000000000000000000000000000000000000000000000001" +
+		"1001000000" + "010100000061"
+	states, ok := wasmNotifyStates(data)
+	if !ok {
+		t.Fatalf("wasmNotifyStates failed")
+	}
+	expected := []interface{}{"abcd", true, "-2", "0000000000000000000000000000000000000000000000000000000000000001", []interface{}{"a"}}
+	if len(states) != len(expected) {
+		t.Fatalf("states:%v, expected:%v", states, expected)
+	}
+	for i := 0; i < 4; i++ {
+		if states[i] != expected[i] {
+			t.Errorf("state %d:%v, expected:%v", i, states[i], expected[i])
+		}
+	}
+	list, ok := states[4].([]interface{})
+	if !ok || len(list) != 1 || list[0] != "a" {
+		t.Errorf("state 4:%v, expected:%v", states[4], expected[4])
+	}
+	_, ok = wasmNotifyStates("0301")
+	if ok {
+		t.Errorf("notify which is not list is decoded")
+	}
+	_, ok = wasmNotifyStates("0302")
+	if ok {
+		t.Errorf("invalid bool is decoded")
+	}
+}
+
+const (
+	TEST_NOTIFY_TRANSFER = "7472616e73666572" //hex of "transfer"
+	TEST_NOTIFY_APPROVAL = "617070726f76616c" //hex of "approval"
+	TEST_PAX_CONTRACT    = "6bbc07bae862db0d7867e4e5b1a13c663e2b4bc8"
+)
+
+type decodeTransferTest struct {
+	name    string
+	decoder TransferDecoder
+	states  []interface{}
+	ok      bool
+	tokenId string
+	from    string
+	to      string
+	amount  int64
+}
+
+func checkDecodeTransfers(t *testing.T, tests []decodeTransferTest) {
+	for _, test := range tests {
+		transfer, ok := test.decoder.Decode(&sdkcom.NotifyEventInfo{ContractAddress: TEST_CONTRACT, States: test.states})
+		if ok != test.ok {
+			t.Errorf("%s: ok:%v, expect %v", test.name, ok, test.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if transfer.TokenId != test.tokenId || transfer.From != test.from || transfer.To != test.to || transfer.Amount.Int64() != test.amount {
+			t.Errorf("%s: transfer token_id:%s from:%s to:%s amount:%s", test.name, transfer.TokenId, transfer.From, transfer.To, transfer.Amount)
+		}
+	}
+}
+
+func TestTransferDecoderRegistry(t *testing.T) {
+	contractDecoders := DefConfig.ContractDecoders
+	defer func() {
+		DefConfig.ContractDecoders = contractDecoders
+	}()
+	DefConfig.ContractDecoders = map[string]string{
+		TEST_CONTRACT:      DECODER_OEP8,
+		TEST_WASM_CONTRACT: DECODER_WASM_OEP4,
+		//Config takes precedence over the default decoder
+		TEST_PAX_CONTRACT: DECODER_OEP4,
+	}
+	tests := []struct {
+		contract string
+		kind     string
+		decoder  TransferDecoder
+	}{
+		{ONT_CONTRACT_ADDRESS, DECODER_NATIVE, &NativeDecoder{}},
+		{ONG_CONTRACT_ADDRESS, DECODER_NATIVE, &NativeDecoder{}},
+		{TEST_CONTRACT, DECODER_OEP8, &Oep8Decoder{}},
+		{TEST_WASM_CONTRACT, DECODER_WASM_OEP4, &WasmOep4Decoder{}},
+		{TEST_PAX_CONTRACT, DECODER_OEP4, &Oep4Decoder{}},
+		{TEST_ADDRESS_A, DECODER_OEP4, &Oep4Decoder{}},
+	}
+	for _, test := range tests {
+		kind := GetTransferDecoderKind(test.contract)
+		if kind != test.kind {
+			t.Errorf("contract %s: decoder kind %s, expect %s", test.contract, kind, test.kind)
+		}
+		if !reflect.DeepEqual(GetTransferDecoder(test.contract), test.decoder) {
+			t.Errorf("contract %s: decoder %T, expect %T", test.contract, GetTransferDecoder(test.contract), test.decoder)
+		}
+		if IsNativeContract(test.contract) != (test.kind == DECODER_NATIVE) || IsNFTContract(test.contract) != (test.kind == DECODER_OEP5) ||
+			IsMultiTokenContract(test.contract) != (test.kind == DECODER_OEP8) || IsWasmContract(test.contract) != (test.kind == DECODER_WASM_OEP4) {
+			t.Errorf("contract %s: type of contract is not %s", test.contract, test.kind)
+		}
+	}
+	delete(DefConfig.ContractDecoders, TEST_PAX_CONTRACT)
+	if GetTransferDecoderKind(TEST_PAX_CONTRACT) != DECODER_OEP4_MINTBURN {
+		t.Errorf("default decoder of PAX is not %s", DECODER_OEP4_MINTBURN)
+	}
+
+	err := CheckContractDecoders()
+	if err != nil {
+		t.Errorf("CheckContractDecoders error:%s", err)
+	}
+	DefConfig.ContractDecoders[TEST_ADDRESS_A] = "oep9"
+	err = CheckContractDecoders()
+	if err == nil {
+		t.Errorf("unknown decoder is not checked")
+	}
+}
+
+func TestDecodeTransfer(t *testing.T) {
+	paxDecoder := transferDecoders[DECODER_OEP4_MINTBURN]
+	checkDecodeTransfers(t, []decodeTransferTest{
+		{
+			name: "oep4 transfer", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "64"},
+			ok: true, from: TEST_ADDRESS_A, to: TEST_ADDRESS_B, amount: 100,
+		},
+		{name: "oep4 approval", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "64"}},
+		{name: "oep4 without amount", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B}},
+		{name: "oep4 negative amount", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "ff"}},
+		{
+			name: "oep4 amount with sign byte", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "ff00"},
+			ok: true, from: TEST_ADDRESS_A, to: TEST_ADDRESS_B, amount: 255,
+		},
+		{
+			name: "pax mint", decoder: paxDecoder, states: []interface{}{"496e637265617365504158", TEST_ADDRESS_A, "64", TEST_ADDRESS_B},
+			ok: true, from: ZERO_ADDRESS, to: TEST_ADDRESS_A, amount: 100,
+		},
+		{
+			name: "pax burn", decoder: paxDecoder, states: []interface{}{"4465637265617365504158", TEST_ADDRESS_A, TEST_ADDRESS_B, "64"},
+			ok: true, from: TEST_ADDRESS_A, to: ZERO_ADDRESS, amount: 100,
+		},
+		{name: "pax negative mint", decoder: paxDecoder, states: []interface{}{"496e637265617365504158", TEST_ADDRESS_A, "9c", TEST_ADDRESS_B}},
+		{name: "pax negative burn", decoder: paxDecoder, states: []interface{}{"4465637265617365504158", TEST_ADDRESS_A, TEST_ADDRESS_B, "9c"}},
+		{
+			name: "pax transfer", decoder: paxDecoder, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "64"},
+			ok: true, from: TEST_ADDRESS_A, to: TEST_ADDRESS_B, amount: 100,
+		},
+	})
+}
+
+func TestOep5Decoder(t *testing.T) {
+	checkDecodeTransfers(t, []decodeTransferTest{
+		{
+			name: "transfer", decoder: &Oep5Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "01"},
+			ok: true, tokenId: "01", from: TEST_ADDRESS_A, to: TEST_ADDRESS_B, amount: 1,
+		},
+		{
+			name: "mint by empty address", decoder: &Oep5Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, "", TEST_ADDRESS_B, "02"},
+			ok: true, tokenId: "02", from: ZERO_ADDRESS, to: TEST_ADDRESS_B, amount: 1,
+		},
+		{name: "empty token id", decoder: &Oep5Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, ""}},
+		{name: "approval", decoder: &Oep5Decoder{}, states: []interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "01"}},
+	})
+}
+
+func TestOep8Decoder(t *testing.T) {
+	checkDecodeTransfers(t, []decodeTransferTest{
+		{
+			name: "transfer", decoder: &Oep8Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "01", "0a"},
+			ok: true, tokenId: "01", from: TEST_ADDRESS_A, to: TEST_ADDRESS_B, amount: 10,
+		},
+		{
+			name: "burn by empty address", decoder: &Oep8Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, "", "03", "e803"},
+			ok: true, tokenId: "03", from: TEST_ADDRESS_A, to: ZERO_ADDRESS, amount: 1000,
+		},
+		{name: "without token id", decoder: &Oep8Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "0a"}},
+		{name: "invalid amount", decoder: &Oep8Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "01", "xx"}},
+		{name: "negative amount", decoder: &Oep8Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "01", "f6"}},
+	})
+
+	//Holders are indexed by token id only for OEP-8
+	contractDecoders := DefConfig.ContractDecoders
+	defer func() {
+		DefConfig.ContractDecoders = contractDecoders
+	}()
+	DefConfig.ContractDecoders = map[string]string{TEST_CONTRACT: DECODER_OEP8, TEST_ADDRESS_A: DECODER_OEP5}
+	for contract, tokenId := range map[string]string{TEST_CONTRACT: "01", TEST_ADDRESS_A: ""} {
+		if holderTokenId(&TxTransfer{Contract: contract, TokenId: "01"}) != tokenId {
+			t.Errorf("contract %s: holder token id is not %q", contract, tokenId)
+		}
+	}
+}
+
+func TestDecodeApproval(t *testing.T) {
+	tests := []struct {
+		name    string
+		decoder ApprovalDecoder
+		states  interface{}
+		ok      bool
+		owner   string
+		spender string
+		amount  int64
+	}{
+		{
+			name: "oep4", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "64"},
+			ok: true, owner: TEST_ADDRESS_A, spender: TEST_ADDRESS_B, amount: 100,
+		},
+		{
+			name: "oep4 mint burn", decoder: &Oep4MintBurnDecoder{}, states: []interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, ""},
+			ok: true, owner: TEST_ADDRESS_A, spender: TEST_ADDRESS_B, amount: 0,
+		},
+		{name: "oep4 transfer", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "64"}},
+		{name: "oep4 negative amount", decoder: &Oep4Decoder{}, states: []interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "9c"}},
+		{name: "oep4 mint burn negative amount", decoder: &Oep4MintBurnDecoder{}, states: []interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "ff"}},
+		{
+			name: "wasm", decoder: &WasmOep4Decoder{}, states: []interface{}{NOTIFY_APPROVAL, TEST_WASM_FROM_BASE58, TEST_WASM_TO_BASE58, "100"},
+			ok: true, owner: TEST_WASM_FROM, spender: TEST_WASM_TO, amount: 100,
+		},
+		{name: "wasm invalid owner", decoder: &WasmOep4Decoder{}, states: []interface{}{NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_WASM_TO_BASE58, "100"}},
+	}
+	for _, test := range tests {
+		allowance, ok := test.decoder.DecodeApproval(&sdkcom.NotifyEventInfo{ContractAddress: TEST_CONTRACT, States: test.states})
+		if ok != test.ok {
+			t.Errorf("%s: ok:%v, expect %v", test.name, ok, test.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if allowance.Contract != TEST_CONTRACT || allowance.Owner != test.owner || allowance.Spender != test.spender || allowance.Amount.Int64() != test.amount {
+			t.Errorf("%s: allowance %s owner:%s spender:%s amount:%s", test.name, allowance.Contract, allowance.Owner, allowance.Spender, allowance.Amount)
+		}
+	}
+}
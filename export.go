@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+//runExport writes the holders of a contract at a block height as csv.
+//Usage: ontology-holder export -contract <contract> -height <height> [-token_id <token id>] [-out <file>]
+func runExport(args []string) error {
+	flagSet := flag.NewFlagSet("export", flag.ContinueOnError)
+	contract := flagSet.String("contract", "", "contract hash of the asset")
+	height := flagSet.Int64("height", -1, "block height of the snapshot")
+	tokenId := flagSet.String("token_id", "", "token id of OEP-8 asset")
+	out := flagSet.String("out", "", "output csv file, default stdout")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *contract == "" {
+		return fmt.Errorf("contract is required")
+	}
+	if *height < 0 || *height > int64(^uint32(0)) {
+		return fmt.Errorf("invalid height:%d", *height)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	err = buildHolderSnapshot(store, *contract, *tokenId, uint32(*height), nil)
+	if err != nil {
+		return err
+	}
+	holders, err := store.GetAssetHolderAtHeight(0, 0, nil, *contract, *tokenId, uint32(*height))
+	if err != nil {
+		return fmt.Errorf("GetAssetHolderAtHeight error:%s", err)
+	}
+
+	var writer io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+	csvWriter := csv.NewWriter(writer)
+	err = csvWriter.Write([]string{"address", "balance", "transactions"})
+	if err != nil {
+		return err
+	}
+	for _, holder := range holders {
+		err = csvWriter.Write([]string{holder.Address, holder.Balance.String(), strconv.Itoa(holder.Transactions)})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -19,9 +19,12 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	log4 "github.com/alecthomas/log4go"
+	"math"
+	"math/big"
 	"net/http"
 	"strings"
 )
@@ -32,7 +35,18 @@ func init() {
 	DefHttpSvr.RegHandler("getAssetInfo", DefHttpSvr.GetAssetInfo)
 	DefHttpSvr.RegHandler("getAssetHolderCount", DefHttpSvr.GetAssetHolderCount)
 	DefHttpSvr.RegHandler("getAssetHolder", DefHttpSvr.GetAssetHolder)
+	DefHttpSvr.RegHandler("getAssetHolderAtHeight", DefHttpSvr.GetAssetHolderAtHeight)
 	DefHttpSvr.RegHandler("getBalance", DefHttpSvr.GetBalance)
+	DefHttpSvr.RegHandler("getBalances", DefHttpSvr.GetBalances)
+	DefHttpSvr.RegHandler("getTransferHistory", DefHttpSvr.GetTransferHistory)
+	DefHttpSvr.RegHandler("getOwnerOf", DefHttpSvr.GetOwnerOf)
+	DefHttpSvr.RegHandler("getTokensOfOwner", DefHttpSvr.GetTokensOfOwner)
+	DefHttpSvr.RegHandler("getAllowance", DefHttpSvr.GetAllowance)
+	DefHttpSvr.RegHandler("getAllowancesByOwner", DefHttpSvr.GetAllowancesByOwner)
+	DefHttpSvr.RegHandler("getBalanceMismatches", DefHttpSvr.GetBalanceMismatches)
+	DefHttpSvr.RegHandler("getContracts", DefHttpSvr.GetContracts)
+	DefHttpSvr.RegAdminHandler("addContract", DefHttpSvr.AddContract)
+	DefHttpSvr.RegAdminHandler("removeContract", DefHttpSvr.RemoveContract)
 }
 
 type HttpServer struct {
@@ -40,11 +54,14 @@ type HttpServer struct {
 	httpSvr    *http.Server
 	httpSvtMux *http.ServeMux
 	handlers   map[string]func(req *HttpServerRequest, resp *HttpServerResponse)
+	//adminMethods change state, they require admin token and POST or DELETE
+	adminMethods map[string]bool
 }
 
 func NewHttpServer() *HttpServer {
 	return &HttpServer{
-		handlers: make(map[string]func(req *HttpServerRequest, resp *HttpServerResponse)),
+		handlers:     make(map[string]func(req *HttpServerRequest, resp *HttpServerResponse)),
+		adminMethods: make(map[string]bool),
 	}
 }
 
@@ -55,6 +72,8 @@ func (this *HttpServer) Start(port uint) {
 		Addr:    fmt.Sprintf("0.0.0.0:%d", port),
 		Handler: this.httpSvtMux,
 	}
+	this.httpSvtMux.HandleFunc("/jsonrpc", this.JsonRpcHandler)
+	this.httpSvtMux.HandleFunc(REST_PATH_PREFIX, this.RestHandler)
 	this.httpSvtMux.HandleFunc("/", this.Handler)
 	go func() {
 		err := this.httpSvr.ListenAndServe()
@@ -68,9 +87,14 @@ func (this *HttpServer) RegHandler(method string, handler func(request *HttpServ
 	this.handlers[strings.ToLower(method)] = handler
 }
 
+func (this *HttpServer) RegAdminHandler(method string, handler func(request *HttpServerRequest, response *HttpServerResponse)) {
+	this.RegHandler(method, handler)
+	this.adminMethods[strings.ToLower(method)] = true
+}
+
 type AssetHolderPer struct {
 	Address string  `json:"address"`
-	Balance uint64  `json:"balance"`
+	Balance string  `json:"balance"`
 	Percent float64 `json:"percent"`
 	Transactions uint64 `json:"transactions"`
 }
@@ -104,10 +128,15 @@ func (this *HttpServer) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	querys := r.URL.Query()
+	//Params of POST body are read as well
+	err := r.ParseForm()
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
 	params := make(map[string]string)
 	qid := ""
-	for k, vs := range querys {
+	for k, vs := range r.Form {
 		if k == "qid" {
 			qid = vs[0]
 			continue
@@ -119,24 +148,55 @@ func (this *HttpServer) Handler(w http.ResponseWriter, r *http.Request) {
 	resp.ErrorCode = ERR_SUCCESS
 
 	req := &HttpServerRequest{
-		Method: method,
-		Qid:    qid,
-		Params: params,
+		Method:        method,
+		HttpMethod:    r.Method,
+		Authorization: r.Header.Get("Authorization"),
+		Qid:           qid,
+		Params:        params,
 	}
 
-	handler, ok := this.handlers[strings.ToLower(method)]
+	this.callHandler(req, resp)
+}
+
+//callHandler calls the handler registered for method of req, admin methods are checked before
+func (this *HttpServer) callHandler(req *HttpServerRequest, resp *HttpServerResponse) {
+	method := strings.ToLower(req.Method)
+	handler, ok := this.handlers[method]
 	if !ok {
 		resp.ErrorCode = ERR_INVALID_METHOD
 		return
 	}
 
-	log4.Info("[HttpServerRequest]:%+v", req)
+	log4.Info("[HttpServerRequest]:Method:%s HttpMethod:%s Qid:%s Params:%v", req.Method, req.HttpMethod, req.Qid, redactParams(req.Params))
+	if this.adminMethods[method] {
+		if req.HttpMethod != http.MethodPost && req.HttpMethod != http.MethodDelete {
+			resp.ErrorCode = ERR_FORBIDDEN
+			resp.ErrorInfo = "POST or DELETE is required"
+			return
+		}
+		if !this.checkAdminToken(req) {
+			resp.ErrorCode = ERR_FORBIDDEN
+			return
+		}
+	}
 	handler(req, resp)
 }
 
+//redactParams returns params for log, secrets are masked
+func redactParams(params map[string]string) map[string]string {
+	redacted := make(map[string]string, len(params))
+	for k, v := range params {
+		if k == "token" {
+			v = REDACTED_VALUE
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
 type AssetInfo struct {
 	Symbol      string `json:"symbol"`
-	TotalSupply uint64 `json:"total_supply"`
+	TotalSupply string `json:"total_supply"`
 	Precision   byte   `json:"precision"`
 }
 
@@ -148,30 +208,62 @@ func (this *HttpServer) GetAssetInfo(req *HttpServerRequest, resp *HttpServerRes
 		return
 	}
 
-	if !IsMonitorContract(contract) {
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	tokenId, ok := this.getParamTokenId(req, contract, true)
+	if !ok {
 		resp.ErrorCode = ERR_INVALID_PARAMS
 		return
 	}
 
-	assetInfo, err := this.getAssetInfo(contract)
+	assetInfo, err := this.getAssetInfo(contract, tokenId)
 	if err != nil {
 		log4.Info("GetAssetInfo error:%s", err)
 		resp.ErrorCode = ERR_INTERNAL
 		return
 	}
+	if IsMultiTokenContract(contract) && assetInfo.TotalSupply == "0" {
+		resp.ErrorCode = ERR_NOT_FOUND
+		resp.ErrorInfo = "token does not exist"
+		return
+	}
 	resp.Result = assetInfo
 }
 
-func (this *HttpServer) getAssetInfo(contract string) (*AssetInfo, error) {
-	var err error
+//checkMonitorContract sets ERR_NOT_FOUND to resp if contract is not monitored
+func (this *HttpServer) checkMonitorContract(contract string, resp *HttpServerResponse) bool {
+	if IsMonitorContract(contract) {
+		return true
+	}
+	resp.ErrorCode = ERR_NOT_FOUND
+	resp.ErrorInfo = fmt.Sprintf("contract:%s is not monitored", contract)
+	return false
+}
+
+//getParamTokenId returns token_id param of OEP-8 contract, it's ignored for other contracts.
+//It returns false if token_id is required but not given.
+func (this *HttpServer) getParamTokenId(req *HttpServerRequest, contract string, isRequired bool) (string, bool) {
+	if !IsMultiTokenContract(contract) {
+		return "", true
+	}
+	tokenId, err := req.GetParamString("token_id")
+	if err != nil {
+		return "", !isRequired
+	}
+	return tokenId, true
+}
+
+func (this *HttpServer) getAssetInfo(contract, tokenId string) (*AssetInfo, error) {
 	tokenType := TypeOfContract(contract)
 	assetInfo := &AssetInfo{}
+	totalSupply, err := this.getTotalSupply(contract, tokenId)
+	if err != nil {
+		return nil, err
+	}
+	assetInfo.TotalSupply = totalSupply.String()
 	switch tokenType {
 	case ONT_ADDRESS:
-		assetInfo.TotalSupply, err = DefOntologyMgr.GetOntSdk().Native.Ont.TotalSupply()
-		if err != nil {
-			return nil, err
-		}
 		assetInfo.Symbol, err = DefOntologyMgr.GetOntSdk().Native.Ont.Symbol()
 		if err != nil {
 			return nil, err
@@ -181,10 +273,6 @@ func (this *HttpServer) getAssetInfo(contract string) (*AssetInfo, error) {
 			return nil, err
 		}
 	case ONG_ADDRESS:
-		assetInfo.TotalSupply, err = DefOntologyMgr.GetOntSdk().Native.Ong.TotalSupply()
-		if err != nil {
-			return nil, err
-		}
 		assetInfo.Symbol, err = DefOntologyMgr.GetOntSdk().Native.Ong.Symbol()
 		if err != nil {
 			return nil, err
@@ -194,15 +282,22 @@ func (this *HttpServer) getAssetInfo(contract string) (*AssetInfo, error) {
 			return nil, err
 		}
 	case OEP4_ADDRESS:
-		assetInfo.TotalSupply, err = DefOntologyMgr.OEP4Supply(contract)
+		assetInfo.Symbol, err = DefOntologyMgr.OEP4Symbol(contract)
+		if err != nil {
+			return nil, err
+		}
+		assetInfo.Precision, err = DefOntologyMgr.OEP4Decimals(contract)
 		if err != nil {
 			return nil, err
 		}
+	case OEP5_ADDRESS:
+		//OEP-5 has the same symbol method as OEP-4, and token is indivisible
 		assetInfo.Symbol, err = DefOntologyMgr.OEP4Symbol(contract)
 		if err != nil {
 			return nil, err
 		}
-		assetInfo.Precision, err = DefOntologyMgr.OEP4Decimals(contract)
+	case OEP8_ADDRESS:
+		assetInfo.Symbol, err = DefOntologyMgr.OEP8Symbol(contract, tokenId)
 		if err != nil {
 			return nil, err
 		}
@@ -212,6 +307,28 @@ func (this *HttpServer) getAssetInfo(contract string) (*AssetInfo, error) {
 	return assetInfo, nil
 }
 
+func (this *HttpServer) getTotalSupply(contract, tokenId string) (*big.Int, error) {
+	switch TypeOfContract(contract) {
+	case ONT_ADDRESS:
+		totalSupply, err := DefOntologyMgr.GetOntSdk().Native.Ont.TotalSupply()
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetUint64(totalSupply), nil
+	case ONG_ADDRESS:
+		totalSupply, err := DefOntologyMgr.GetOntSdk().Native.Ong.TotalSupply()
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetUint64(totalSupply), nil
+	case OEP4_ADDRESS, OEP5_ADDRESS:
+		return DefOntologyMgr.OEP4Supply(contract)
+	case OEP8_ADDRESS:
+		return DefOntologyMgr.OEP8Supply(contract, tokenId)
+	}
+	return nil, fmt.Errorf("unknown contract")
+}
+
 func (this *HttpServer) GetAssetHolderCount(req *HttpServerRequest, resp *HttpServerResponse) {
 	contract, err := req.GetParamString("contract")
 	if err != nil {
@@ -219,19 +336,40 @@ func (this *HttpServer) GetAssetHolderCount(req *HttpServerRequest, resp *HttpSe
 		log4.Info("GetAssetHolder GetParamString contract error:%s", err)
 		return
 	}
-	if !IsMonitorContract(contract) {
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	tokenId, ok := this.getParamTokenId(req, contract, true)
+	if !ok {
 		resp.ErrorCode = ERR_INVALID_PARAMS
 		return
 	}
-	resp.Result = DefOntologyMgr.GetAssetHolderCount(contract)
+	resp.Result = DefOntologyMgr.GetAssetHolderCount(contract, tokenId)
+}
+
+type AssetHolderPage struct {
+	Holders    []*AssetHolderPer `json:"holders"`
+	NextCursor string            `json:"next_cursor"`
+	Height     uint32            `json:"height,omitempty"`
 }
 
 func (this *HttpServer) GetAssetHolder(req *HttpServerRequest, resp *HttpServerResponse) {
-	from, err := req.GetParamInt("from")
-	if err != nil {
-		resp.ErrorCode = ERR_INVALID_PARAMS
-		log4.Info("GetAssetHolder GetParamInt from error:%s", err)
-		return
+	//Holders are paged by cursor if cursor or snapshot is given, from is ignored
+	cursorParam, err := req.GetParamString("cursor")
+	isCursorPage := err == nil
+	snapshot, _ := req.GetParamString("snapshot")
+	isSnapshot := snapshot == "true"
+	if isSnapshot {
+		isCursorPage = true
+	}
+	from := 0
+	if !isCursorPage {
+		from, err = req.GetParamInt("from")
+		if err != nil {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			log4.Info("GetAssetHolder GetParamInt from error:%s", err)
+			return
+		}
 	}
 	count, err := req.GetParamInt("count")
 	if err != nil {
@@ -246,34 +384,70 @@ func (this *HttpServer) GetAssetHolder(req *HttpServerRequest, resp *HttpServerR
 		return
 	}
 
-	if from < 0 || count < 0 || (!IsMonitorContract(contract)) {
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	tokenId, ok := this.getParamTokenId(req, contract, true)
+	if !ok || from < 0 || count < 0 {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	addressFormat, ok := this.getParamAddressFormat(req)
+	if !ok {
 		resp.ErrorCode = ERR_INVALID_PARAMS
 		return
 	}
 
-	if count > int(DefConfig.MaxQueryPageSize) {
+	if count > int(DefConfig.MaxQueryPageSize) || (isCursorPage && count == 0) {
 		resp.ErrorCode = ERR_INVALID_PARAMS
 		resp.ErrorInfo = fmt.Sprintf("count out of range[1, %d]", DefConfig.MaxQueryPageSize)
 		return
 	}
 
-	totalSupply := uint64(1)
-	tokenType := TypeOfContract(contract)
-	switch tokenType {
-	case ONT_ADDRESS:
-		totalSupply, _ = DefOntologyMgr.GetOntSdk().Native.Ont.TotalSupply()
-	case ONG_ADDRESS:
-		totalSupply,_ = DefOntologyMgr.GetOntSdk().Native.Ong.TotalSupply()
-	case OEP4_ADDRESS:
-		totalSupply, _ = DefOntologyMgr.OEP4Supply(contract)
+	var cursor *HolderCursor
+	height := uint32(0)
+	if cursorParam != "" {
+		cursor, err = DecodeHolderCursor(cursorParam)
+		if err != nil {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			resp.ErrorInfo = "invalid cursor"
+			log4.Info("GetAssetHolder DecodeHolderCursor cursor:%s error:%s", cursorParam, err)
+			return
+		}
+		//Following pages of a snapshot are at the height of its first page
+		isSnapshot = cursor.IsSnapshot
+		height = cursor.Height
+	} else if isSnapshot {
+		height, err = DefOntologyMgr.GetSnapshotPageHeight(contract, tokenId, this.getSyncedHeight(contract))
+		if err != nil {
+			resp.ErrorCode = ERR_INTERNAL
+			log4.Info("GetAssetHolder GetSnapshotPageHeight error:%s", err)
+			return
+		}
+	}
+
+	if isSnapshot && !this.checkSnapshotHeight(contract, height, resp) {
+		return
 	}
 
-	if totalSupply == 0 {
+	totalSupply, err := this.getTotalSupply(contract, tokenId)
+	if err == nil && totalSupply.Sign() == 0 && IsMultiTokenContract(contract) {
+		resp.ErrorCode = ERR_NOT_FOUND
+		resp.ErrorInfo = "token does not exist"
+		return
+	}
+	if err != nil || totalSupply.Sign() == 0 {
 		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetAssetHolder getTotalSupply contract:%s error:%v", contract, err)
 		return
 	}
 
-	assetHolders, err := DefOntologyMgr.GetAssetHolder(from, count, "", contract)
+	var assetHolders []*AssetHolder
+	if isSnapshot {
+		assetHolders, err = DefOntologyMgr.GetAssetHolderAtHeight(from, count, cursor, contract, tokenId, height)
+	} else {
+		assetHolders, err = DefOntologyMgr.GetAssetHolder(from, count, cursor, "", contract, tokenId)
+	}
 	if err != nil {
 		resp.ErrorCode = ERR_INTERNAL
 		log4.Info("GetAssetHolder GetAssetHolder error:%s", err)
@@ -283,20 +457,143 @@ func (this *HttpServer) GetAssetHolder(req *HttpServerRequest, resp *HttpServerR
 	assetHolderPers := make([]*AssetHolderPer, 0, len(assetHolders))
 	for _, assetHolder := range assetHolders {
 		assetHolderPer := &AssetHolderPer{
-			Address: assetHolder.Address,
-			Balance: assetHolder.Balance,
-			Percent: float64(assetHolder.Balance) / float64(totalSupply),
+			Address: FormatAddress(assetHolder.Address, contract, addressFormat),
+			Balance: assetHolder.Balance.String(),
+			Percent: BigIntRatio(assetHolder.Balance, totalSupply),
 			Transactions: uint64(assetHolder.Transactions),
 		}
 		assetHolderPers = append(assetHolderPers, assetHolderPer)
 	}
 
-	resp.Result = assetHolderPers
+	if !isCursorPage {
+		resp.Result = assetHolderPers
+		return
+	}
+	assetHolderPage := &AssetHolderPage{
+		Holders: assetHolderPers,
+	}
+	if isSnapshot {
+		assetHolderPage.Height = height
+	}
+	//The last page is shorter than count, or is empty if the number of holders is a multiple of count
+	if len(assetHolders) == count {
+		assetHolderPage.NextCursor = NewHolderCursor(assetHolders[len(assetHolders)-1], isSnapshot, height).Encode()
+	}
+	resp.Result = assetHolderPage
+}
+
+//getSyncedHeight returns the height that holders of contract are synced to
+func (this *HttpServer) getSyncedHeight(contract string) uint32 {
+	syncedHeight := DefOntologyMgr.GetCommittedBlockHeight()
+	contractInfo := DefContractMgr.GetContractInfo(contract)
+	if contractInfo != nil && contractInfo.SyncMode == CONTRACT_SYNC_BACKFILL && contractInfo.Height < syncedHeight {
+		syncedHeight = contractInfo.Height
+	}
+	return syncedHeight
+}
+
+//checkSnapshotHeight sets the error of resp if holders of contract at height can't be rebuilt from the saved transfers
+func (this *HttpServer) checkSnapshotHeight(contract string, height uint32, resp *HttpServerResponse) bool {
+	invalidInfo, err := DefOntologyMgr.CheckSnapshotHeight(contract, height)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("CheckSnapshotHeight contract:%s error:%s", contract, err)
+		return false
+	}
+	if invalidInfo != "" {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = invalidInfo
+		return false
+	}
+	return true
+}
+
+type AssetHolderBalance struct {
+	Address      string `json:"address"`
+	Balance      string `json:"balance"`
+	Transactions uint64 `json:"transactions"`
+}
+
+func (this *HttpServer) GetAssetHolderAtHeight(req *HttpServerRequest, resp *HttpServerResponse) {
+	from, err := req.GetParamInt("from")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAssetHolderAtHeight GetParamInt from error:%s", err)
+		return
+	}
+	count, err := req.GetParamInt("count")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAssetHolderAtHeight GetParamInt count error:%s", err)
+		return
+	}
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAssetHolderAtHeight GetParamString contract error:%s", err)
+		return
+	}
+	height, err := req.GetParamInt("height")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAssetHolderAtHeight GetParamInt height error:%s", err)
+		return
+	}
+
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	tokenId, ok := this.getParamTokenId(req, contract, true)
+	if !ok || from < 0 || height < 0 {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	addressFormat, ok := this.getParamAddressFormat(req)
+	if !ok {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+
+	if count <= 0 || count > int(DefConfig.MaxQueryPageSize) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("count out of range[1, %d]", DefConfig.MaxQueryPageSize)
+		return
+	}
+
+	syncedHeight := this.getSyncedHeight(contract)
+	if uint32(height) > syncedHeight {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("height out of range[0, %d]", syncedHeight)
+		return
+	}
+	if !this.checkSnapshotHeight(contract, uint32(height), resp) {
+		return
+	}
+
+	assetHolders, err := DefOntologyMgr.GetAssetHolderAtHeight(from, count, nil, contract, tokenId, uint32(height))
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetAssetHolderAtHeight contract:%s height:%d error:%s", contract, height, err)
+		return
+	}
+
+	assetHolderBalances := make([]*AssetHolderBalance, 0, len(assetHolders))
+	for _, assetHolder := range assetHolders {
+		assetHolderBalances = append(assetHolderBalances, &AssetHolderBalance{
+			Address:      FormatAddress(assetHolder.Address, contract, addressFormat),
+			Balance:      assetHolder.Balance.String(),
+			Transactions: uint64(assetHolder.Transactions),
+		})
+	}
+
+	resp.Result = assetHolderBalances
 }
 
 type AssetBalance struct {
+	Address  string `json:"address,omitempty"`
 	Contract string `json:"contract"`
-	Balance  uint64 `json:"balance"`
+	TokenId  string `json:"token_id,omitempty"`
+	Balance  string `json:"balance"`
 }
 
 func (this *HttpServer) GetBalance(req *HttpServerRequest, resp *HttpServerResponse) {
@@ -314,11 +611,29 @@ func (this *HttpServer) GetBalance(req *HttpServerRequest, resp *HttpServerRespo
 			return
 		}
 	}
-	if !IsMonitorContract(contract) {
+	//Balances of all token ids are returned if token_id of OEP-8 is not given
+	tokenId, _ := this.getParamTokenId(req, contract, false)
+	if contract != "" && !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	addressFormat, ok := this.getParamAddressFormat(req)
+	if !ok {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	hexAddresses, err := ResolveAddressOfAllContracts(address)
+	if err != nil {
 		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = err.Error()
 		return
 	}
-	assetHolders, err := DefOntologyMgr.GetAssetHolder(0, 0, address, contract)
+	var assetHolders []*AssetHolder
+	if contract != "" {
+		hexAddress, _ := ResolveAddress(address, contract)
+		assetHolders, err = DefOntologyMgr.GetAssetHolder(0, 0, nil, hexAddress, contract, tokenId)
+	} else {
+		assetHolders, err = DefOntologyMgr.GetBalances(hexAddresses, nil)
+	}
 	if err != nil {
 		resp.ErrorCode = ERR_INTERNAL
 		log4.Info("GetBalance GetAssetHolder address:%s contract:%s error:%s", address, contract, err)
@@ -327,12 +642,640 @@ func (this *HttpServer) GetBalance(req *HttpServerRequest, resp *HttpServerRespo
 
 	assetBalances := make([]*AssetBalance, 0, len(assetHolders))
 	for _, assetHolder := range assetHolders {
-		assetBalances = append(assetBalances,
-			&AssetBalance{
-				Contract: assetHolder.Contract,
-				Balance:  assetHolder.Balance,
-			})
+		if !IsHolderOfAddress(assetHolder, address) {
+			continue
+		}
+		assetBalances = append(assetBalances, newAssetBalance(assetHolder, addressFormat))
 	}
 
 	resp.Result = assetBalances
 }
+
+//newAssetBalance returns the balance of holder, address is returned only if address_format is given,
+//since the hex address of the same account differs between contracts
+func newAssetBalance(holder *AssetHolder, addressFormat string) *AssetBalance {
+	assetBalance := &AssetBalance{
+		Contract: holder.Contract,
+		TokenId:  holder.TokenId,
+		Balance:  holder.Balance.String(),
+	}
+	if addressFormat != "" {
+		assetBalance.Address = FormatAddress(holder.Address, holder.Contract, addressFormat)
+	}
+	return assetBalance
+}
+
+//getParamAddressFormat returns address_format param, which is hex or base58, empty if it's not given
+func (this *HttpServer) getParamAddressFormat(req *HttpServerRequest) (string, bool) {
+	addressFormat, err := req.GetParamString("address_format")
+	if err != nil {
+		return "", true
+	}
+	addressFormat = strings.ToLower(addressFormat)
+	if addressFormat != ADDRESS_FORMAT_HEX && addressFormat != ADDRESS_FORMAT_BASE58 {
+		return "", false
+	}
+	return addressFormat, true
+}
+
+type AddressBalances struct {
+	Address  string          `json:"address"`
+	Balances []*AssetBalance `json:"balances"`
+}
+
+//getParamList returns the comma separated values of param without duplicates
+func (this *HttpServer) getParamList(req *HttpServerRequest, param string) ([]string, error) {
+	value, err := req.GetParamString(param)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0)
+	valueMap := make(map[string]bool)
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" || valueMap[v] {
+			continue
+		}
+		valueMap[v] = true
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (this *HttpServer) GetBalances(req *HttpServerRequest, resp *HttpServerResponse) {
+	addresses, err := this.getParamList(req, "addresses")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetBalances GetParamString addresses error:%s", err)
+		return
+	}
+	if len(addresses) == 0 || len(addresses) > int(DefConfig.GetMaxBalanceBatchSize()) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("count of addresses out of range[1, %d]", DefConfig.GetMaxBalanceBatchSize())
+		return
+	}
+	contracts, err := this.getParamList(req, "contracts")
+	if err != nil && err != ERR_PARAM_NOT_EXIST {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetBalances GetParamString contracts error:%s", err)
+		return
+	}
+	for _, contract := range contracts {
+		if !this.checkMonitorContract(contract, resp) {
+			return
+		}
+	}
+	addressFormat, ok := this.getParamAddressFormat(req)
+	if !ok {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+
+	//Every address is returned in the order of addresses param, balances is empty if it holds nothing.
+	//A base58 address is looked up by both of its hex forms.
+	addressBalances := make([]*AddressBalances, 0, len(addresses))
+	addressBalanceMap := make(map[string][]*AddressBalances, len(addresses))
+	hexAddresses := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		resolved, err := ResolveAddressOfAllContracts(address)
+		if err != nil {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			resp.ErrorInfo = err.Error()
+			return
+		}
+		addressBalance := &AddressBalances{
+			Address:  address,
+			Balances: make([]*AssetBalance, 0),
+		}
+		addressBalances = append(addressBalances, addressBalance)
+		for _, hexAddress := range resolved {
+			if _, ok := addressBalanceMap[hexAddress]; !ok {
+				hexAddresses = append(hexAddresses, hexAddress)
+			}
+			addressBalanceMap[hexAddress] = append(addressBalanceMap[hexAddress], addressBalance)
+		}
+	}
+
+	assetHolders, err := DefOntologyMgr.GetBalances(hexAddresses, contracts)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetBalances GetBalances error:%s", err)
+		return
+	}
+	for _, assetHolder := range assetHolders {
+		for _, addressBalance := range addressBalanceMap[assetHolder.Address] {
+			if !IsHolderOfAddress(assetHolder, addressBalance.Address) {
+				continue
+			}
+			addressBalance.Balances = append(addressBalance.Balances, newAssetBalance(assetHolder, addressFormat))
+		}
+	}
+	resp.Result = addressBalances
+}
+
+type TransferHistory struct {
+	TxHash    string `json:"tx_hash"`
+	Index     int    `json:"index"`
+	Height    uint32 `json:"height"`
+	Name      string `json:"name"`
+	Contract  string `json:"contract"`
+	TokenId   string `json:"token_id,omitempty"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Amount    string `json:"amount"`
+	Timestamp uint32 `json:"timestamp"`
+}
+
+func (this *HttpServer) GetTransferHistory(req *HttpServerRequest, resp *HttpServerResponse) {
+	from, err := req.GetParamInt("from")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTransferHistory GetParamInt from error:%s", err)
+		return
+	}
+	count, err := req.GetParamInt("count")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTransferHistory GetParamInt count error:%s", err)
+		return
+	}
+	if from < 0 || count <= 0 || count > int(DefConfig.MaxQueryPageSize) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("count out of range[1, %d]", DefConfig.MaxQueryPageSize)
+		return
+	}
+	address, err := req.GetParamString("address")
+	if err != nil && err != ERR_PARAM_NOT_EXIST {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	contract, err := req.GetParamString("contract")
+	if err != nil && err != ERR_PARAM_NOT_EXIST {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	if address == "" && contract == "" {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = "address or contract is required"
+		return
+	}
+	if contract != "" && !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	//A base58 address without contract is resolved to the hex addresses of all contracts
+	if address != "" {
+		_, err = ResolveAddressOfAllContracts(address)
+		if err != nil {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			resp.ErrorInfo = err.Error()
+			return
+		}
+	}
+	addressFormat, ok := this.getParamAddressFormat(req)
+	if !ok {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	startHeight, err := req.GetParamInt("start_height")
+	if err != nil && err != ERR_PARAM_NOT_EXIST {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTransferHistory GetParamInt start_height error:%s", err)
+		return
+	}
+	endHeight, err := req.GetParamInt("end_height")
+	if err == ERR_PARAM_NOT_EXIST {
+		endHeight = math.MaxUint32
+	} else if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTransferHistory GetParamInt end_height error:%s", err)
+		return
+	}
+	if startHeight < 0 || endHeight < startHeight || endHeight > math.MaxUint32 {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+
+	var transfers []*TxTransfer
+	if address != "" && contract == "" {
+		transfers, err = DefOntologyMgr.GetTransferHistoryOfAllContracts(from, count, address, uint32(startHeight), uint32(endHeight))
+	} else {
+		if address != "" {
+			address, _ = ResolveAddress(address, contract)
+		}
+		transfers, err = DefOntologyMgr.GetTransferHistory(from, count, address, contract, uint32(startHeight), uint32(endHeight))
+	}
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetTransferHistory address:%s contract:%s error:%s", address, contract, err)
+		return
+	}
+
+	transferHistory := make([]*TransferHistory, 0, len(transfers))
+	for _, transfer := range transfers {
+		transferHistory = append(transferHistory, &TransferHistory{
+			TxHash:    transfer.TxHash,
+			Index:     transfer.Index,
+			Height:    transfer.Height,
+			Name:      transfer.Name,
+			Contract:  transfer.Contract,
+			TokenId:   transfer.TokenId,
+			From:      FormatAddress(transfer.From, transfer.Contract, addressFormat),
+			To:        FormatAddress(transfer.To, transfer.Contract, addressFormat),
+			Amount:    transfer.Amount.String(),
+			Timestamp: transfer.Timestamp,
+		})
+	}
+	resp.Result = transferHistory
+}
+
+type TokenOwnerInfo struct {
+	TokenId string `json:"token_id"`
+	Owner   string `json:"owner"`
+	Height  uint32 `json:"height"`
+}
+
+func (this *HttpServer) GetOwnerOf(req *HttpServerRequest, resp *HttpServerResponse) {
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetOwnerOf GetParamString contract error:%s", err)
+		return
+	}
+	tokenId, err := req.GetParamString("token_id")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetOwnerOf GetParamString token_id error:%s", err)
+		return
+	}
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	if !IsNFTContract(contract) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	addressFormat, ok := this.getParamAddressFormat(req)
+	if !ok {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+
+	tokenOwner, err := DefOntologyMgr.GetTokenOwner(contract, tokenId)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetOwnerOf contract:%s token_id:%s error:%s", contract, tokenId, err)
+		return
+	}
+	if tokenOwner == nil || tokenOwner.Owner == ZERO_ADDRESS {
+		resp.ErrorCode = ERR_NOT_FOUND
+		resp.ErrorInfo = "token does not exist"
+		return
+	}
+	resp.Result = &TokenOwnerInfo{
+		TokenId: tokenOwner.TokenId,
+		Owner:   FormatAddress(tokenOwner.Owner, contract, addressFormat),
+		Height:  tokenOwner.Height,
+	}
+}
+
+func (this *HttpServer) GetTokensOfOwner(req *HttpServerRequest, resp *HttpServerResponse) {
+	from, err := req.GetParamInt("from")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTokensOfOwner GetParamInt from error:%s", err)
+		return
+	}
+	count, err := req.GetParamInt("count")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTokensOfOwner GetParamInt count error:%s", err)
+		return
+	}
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTokensOfOwner GetParamString contract error:%s", err)
+		return
+	}
+	address, err := req.GetParamString("address")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetTokensOfOwner GetParamString address error:%s", err)
+		return
+	}
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	if from < 0 || !IsNFTContract(contract) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	address, err = ResolveAddress(address, contract)
+	if err != nil || address == ZERO_ADDRESS {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	if count <= 0 || count > int(DefConfig.MaxQueryPageSize) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("count out of range[1, %d]", DefConfig.MaxQueryPageSize)
+		return
+	}
+
+	tokenOwners, err := DefOntologyMgr.GetTokensOfOwner(from, count, contract, address)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetTokensOfOwner contract:%s address:%s error:%s", contract, address, err)
+		return
+	}
+	tokenOwnerInfos := make([]*TokenOwnerInfo, 0, len(tokenOwners))
+	for _, tokenOwner := range tokenOwners {
+		tokenOwnerInfos = append(tokenOwnerInfos, &TokenOwnerInfo{
+			TokenId: tokenOwner.TokenId,
+			Owner:   tokenOwner.Owner,
+			Height:  tokenOwner.Height,
+		})
+	}
+	resp.Result = tokenOwnerInfos
+}
+
+type AllowanceInfo struct {
+	Contract string `json:"contract"`
+	Owner    string `json:"owner"`
+	Spender  string `json:"spender"`
+	Amount   string `json:"amount"`
+	TxHash   string `json:"tx_hash"`
+	Height   uint32 `json:"height"`
+}
+
+func newAllowanceInfo(allowance *Allowance) *AllowanceInfo {
+	return &AllowanceInfo{
+		Contract: allowance.Contract,
+		Owner:    allowance.Owner,
+		Spender:  allowance.Spender,
+		Amount:   allowance.Amount.String(),
+		TxHash:   allowance.TxHash,
+		Height:   allowance.Height,
+	}
+}
+
+func (this *HttpServer) GetAllowance(req *HttpServerRequest, resp *HttpServerResponse) {
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAllowance GetParamString contract error:%s", err)
+		return
+	}
+	owner, err := req.GetParamString("owner")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAllowance GetParamString owner error:%s", err)
+		return
+	}
+	spender, err := req.GetParamString("spender")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAllowance GetParamString spender error:%s", err)
+		return
+	}
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	owner, err = ResolveAddress(owner, contract)
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	spender, err = ResolveAddress(spender, contract)
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+
+	allowance, err := DefOntologyMgr.GetAllowance(contract, owner, spender)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetAllowance contract:%s owner:%s spender:%s error:%s", contract, owner, spender, err)
+		return
+	}
+	if allowance == nil {
+		//Never approved
+		allowance = &Allowance{
+			Contract: contract,
+			Owner:    owner,
+			Spender:  spender,
+			Amount:   new(big.Int),
+		}
+	}
+	resp.Result = newAllowanceInfo(allowance)
+}
+
+func (this *HttpServer) GetAllowancesByOwner(req *HttpServerRequest, resp *HttpServerResponse) {
+	from, err := req.GetParamInt("from")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAllowancesByOwner GetParamInt from error:%s", err)
+		return
+	}
+	count, err := req.GetParamInt("count")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAllowancesByOwner GetParamInt count error:%s", err)
+		return
+	}
+	owner, err := req.GetParamString("owner")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetAllowancesByOwner GetParamString owner error:%s", err)
+		return
+	}
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		if err != ERR_PARAM_NOT_EXIST {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			log4.Info("GetAllowancesByOwner GetParamString contract error:%s", err)
+			return
+		}
+	}
+	if contract != "" && !this.checkMonitorContract(contract, resp) {
+		return
+	}
+	if from < 0 {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	//A base58 owner without contract is resolved to the hex addresses of all contracts
+	_, err = ResolveAddressOfAllContracts(owner)
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = err.Error()
+		return
+	}
+	if count <= 0 || count > int(DefConfig.MaxQueryPageSize) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("count out of range[1, %d]", DefConfig.MaxQueryPageSize)
+		return
+	}
+
+	var allowances []*Allowance
+	if contract == "" {
+		allowances, err = DefOntologyMgr.GetAllowancesByOwnerOfAllContracts(from, count, owner)
+	} else {
+		owner, _ = ResolveAddress(owner, contract)
+		allowances, err = DefOntologyMgr.GetAllowancesByOwner(from, count, owner, contract)
+	}
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetAllowancesByOwner owner:%s contract:%s error:%s", owner, contract, err)
+		return
+	}
+	allowanceInfos := make([]*AllowanceInfo, 0, len(allowances))
+	for _, allowance := range allowances {
+		allowanceInfos = append(allowanceInfos, newAllowanceInfo(allowance))
+	}
+	resp.Result = allowanceInfos
+}
+
+type BalanceMismatchInfo struct {
+	Address        string `json:"address"`
+	Contract       string `json:"contract"`
+	TokenId        string `json:"token_id,omitempty"`
+	IndexedBalance string `json:"indexed_balance"`
+	ChainBalance   string `json:"chain_balance"`
+	Height         uint32 `json:"height"`
+	IsCorrected    bool   `json:"is_corrected"`
+	Timestamp      uint32 `json:"timestamp"`
+}
+
+func (this *HttpServer) GetBalanceMismatches(req *HttpServerRequest, resp *HttpServerResponse) {
+	from, err := req.GetParamInt("from")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetBalanceMismatches GetParamInt from error:%s", err)
+		return
+	}
+	count, err := req.GetParamInt("count")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("GetBalanceMismatches GetParamInt count error:%s", err)
+		return
+	}
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		if err != ERR_PARAM_NOT_EXIST {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			log4.Info("GetBalanceMismatches GetParamString contract error:%s", err)
+			return
+		}
+	}
+	if from < 0 {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	if count <= 0 || count > int(DefConfig.MaxQueryPageSize) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("count out of range[1, %d]", DefConfig.MaxQueryPageSize)
+		return
+	}
+
+	mismatches, err := DefOntologyMgr.GetBalanceMismatches(from, count, contract)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("GetBalanceMismatches contract:%s error:%s", contract, err)
+		return
+	}
+	mismatchInfos := make([]*BalanceMismatchInfo, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		mismatchInfos = append(mismatchInfos, &BalanceMismatchInfo{
+			Address:        mismatch.Address,
+			Contract:       mismatch.Contract,
+			TokenId:        mismatch.TokenId,
+			IndexedBalance: mismatch.IndexedBalance.String(),
+			ChainBalance:   mismatch.ChainBalance.String(),
+			Height:         mismatch.Height,
+			IsCorrected:    mismatch.IsCorrected,
+			Timestamp:      mismatch.Timestamp,
+		})
+	}
+	resp.Result = mismatchInfos
+}
+
+func (this *HttpServer) GetContracts(req *HttpServerRequest, resp *HttpServerResponse) {
+	resp.Result = DefContractMgr.GetContractInfos()
+}
+
+//checkAdminToken checks the "Authorization: Bearer <token>" header of admin request, admin methods are disabled if AdminToken is empty.
+//Token isn't read from params, so that it doesn't appear in urls and logs.
+func (this *HttpServer) checkAdminToken(req *HttpServerRequest) bool {
+	if DefConfig.AdminToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(req.Authorization, "Bearer ")
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(DefConfig.AdminToken)) == 1
+}
+
+func (this *HttpServer) AddContract(req *HttpServerRequest, resp *HttpServerResponse) {
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("AddContract GetParamString contract error:%s", err)
+		return
+	}
+	startHeight := 0
+	if _, ok := req.Params["start_height"]; ok {
+		startHeight, err = req.GetParamInt("start_height")
+		if err != nil {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			log4.Info("AddContract GetParamInt start_height error:%s", err)
+			return
+		}
+	} else if deployTx, ok := req.Params["deploy_tx"]; ok {
+		deployHeight, err := getDeployHeight(DefOntologyMgr.GetOntSdk(), deployTx)
+		if err != nil {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			resp.ErrorInfo = "invalid deploy_tx"
+			log4.Info("AddContract deploy_tx:%s error:%s", deployTx, err)
+			return
+		}
+		startHeight = int(deployHeight)
+	}
+	if startHeight < 0 || !IsContractAddress(contract) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		return
+	}
+	if IsMonitorContract(contract) {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		resp.ErrorInfo = fmt.Sprintf("contract:%s is already monitored", contract)
+		return
+	}
+
+	err = DefOntologyMgr.AddContract(contract, uint32(startHeight))
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("AddContract contract:%s error:%s", contract, err)
+		return
+	}
+	log4.Info("AddContract contract:%s start height:%d", contract, startHeight)
+}
+
+func (this *HttpServer) RemoveContract(req *HttpServerRequest, resp *HttpServerResponse) {
+	contract, err := req.GetParamString("contract")
+	if err != nil {
+		resp.ErrorCode = ERR_INVALID_PARAMS
+		log4.Info("RemoveContract GetParamString contract error:%s", err)
+		return
+	}
+	if !this.checkMonitorContract(contract, resp) {
+		return
+	}
+
+	err = DefOntologyMgr.RemoveContract(contract)
+	if err != nil {
+		resp.ErrorCode = ERR_INTERNAL
+		log4.Info("RemoveContract contract:%s error:%s", contract, err)
+		return
+	}
+	log4.Info("RemoveContract contract:%s", contract)
+}
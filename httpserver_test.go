@@ -0,0 +1,107 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCallAdminHandler(t *testing.T) {
+	DefConfig.AdminToken = "admin_token"
+	defer func() {
+		DefConfig.AdminToken = ""
+	}()
+	httpSvr := NewHttpServer()
+	called := false
+	httpSvr.RegAdminHandler("addContract", func(req *HttpServerRequest, resp *HttpServerResponse) {
+		called = true
+	})
+
+	tests := []struct {
+		name          string
+		httpMethod    string
+		authorization string
+		params        map[string]string
+		errorCode     uint32
+	}{
+		{"post with token", http.MethodPost, "Bearer admin_token", nil, ERR_SUCCESS},
+		{"delete with token", http.MethodDelete, "Bearer admin_token", nil, ERR_SUCCESS},
+		{"get with token", http.MethodGet, "Bearer admin_token", nil, ERR_FORBIDDEN},
+		{"post without token", http.MethodPost, "", nil, ERR_FORBIDDEN},
+		{"post with wrong token", http.MethodPost, "Bearer wrong", nil, ERR_FORBIDDEN},
+		{"token param is ignored", http.MethodPost, "", map[string]string{"token": "admin_token"}, ERR_FORBIDDEN},
+	}
+	for _, test := range tests {
+		called = false
+		req := &HttpServerRequest{
+			Method:        "addcontract",
+			HttpMethod:    test.httpMethod,
+			Authorization: test.authorization,
+			Params:        test.params,
+		}
+		resp := &HttpServerResponse{}
+		httpSvr.callHandler(req, resp)
+		if resp.ErrorCode != test.errorCode {
+			t.Errorf("%s: error code %d, expect %d", test.name, resp.ErrorCode, test.errorCode)
+		}
+		if called != (test.errorCode == ERR_SUCCESS) {
+			t.Errorf("%s: handler called:%v", test.name, called)
+		}
+	}
+}
+
+func TestRedactParams(t *testing.T) {
+	params := map[string]string{"token": "admin_token", "contract": TEST_CONTRACT}
+	redacted := redactParams(params)
+	if redacted["token"] == "admin_token" || redacted["contract"] != TEST_CONTRACT {
+		t.Errorf("redacted params:%v", redacted)
+	}
+	if params["token"] != "admin_token" {
+		t.Errorf("params are changed:%v", params)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *Config
+		password string
+		token    string
+	}{
+		{"secrets", &Config{MySqlPassword: "mysql_secret", AdminToken: "admin_token", MySqlUserName: "root"}, REDACTED_VALUE, REDACTED_VALUE},
+		{"no secret", &Config{MySqlUserName: "root"}, "", ""},
+	}
+	for _, test := range tests {
+		cfg := *test.cfg
+		redacted := redactConfig(test.cfg)
+		if redacted.MySqlPassword != test.password || redacted.AdminToken != test.token || redacted.MySqlUserName != "root" {
+			t.Errorf("%s: redacted config:%+v", test.name, redacted)
+		}
+		if text := fmt.Sprintf("%+v", redacted); strings.Contains(text, "mysql_secret") || strings.Contains(text, "admin_token") {
+			t.Errorf("%s: secret is logged:%s", test.name, text)
+		}
+		if !reflect.DeepEqual(*test.cfg, cfg) {
+			t.Errorf("%s: config is changed:%+v", test.name, test.cfg)
+		}
+	}
+}
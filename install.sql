@@ -1,12 +1,14 @@
-CREATE TABLE `holder` (
+CREATE TABLE IF NOT EXISTS `holder` (
   `address` varchar(48) NOT NULL,
   `contract` varchar(48) NOT NULL,
-  `balance` bigint(19) NOT NULL,
+  `token_id` varchar(128) NOT NULL DEFAULT '',
+  `balance` decimal(65,0) NOT NULL,
   `transactions` int(10) unsigned NOT NULL,
-  PRIMARY KEY (`address`,`contract`)
+  PRIMARY KEY (`address`,`contract`,`token_id`),
+  KEY `contract_balance` (`contract`,`token_id`,`balance`,`address`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `eventnotify` (
+CREATE TABLE IF NOT EXISTS `eventnotify` (
   `tx_hash` varchar(64) NOT NULL,
   `height` int(10) unsigned NOT NULL,
   `state` tinyint(3) unsigned zerofill NOT NULL,
@@ -16,10 +18,105 @@ CREATE TABLE `eventnotify` (
   UNIQUE KEY `tx_hash_UNIQUE` (`tx_hash`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8;
 
-CREATE TABLE `heartbeat` (
+CREATE TABLE IF NOT EXISTS `heartbeat` (
   `module` varchar(64) NOT NULL,
   `node_id` int(11) NOT NULL,
   `update_time` datetime NOT NULL,
   PRIMARY KEY (`module`),
   UNIQUE KEY ` node_id_UNIQUE` (`module`)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `transfers` (
+  `tx_hash` varchar(64) NOT NULL,
+  `notify_index` int(10) unsigned NOT NULL,
+  `height` int(10) unsigned NOT NULL,
+  `name` varchar(32) NOT NULL,
+  `contract` varchar(48) NOT NULL,
+  `token_id` varchar(128) NOT NULL DEFAULT '',
+  `from_address` varchar(48) NOT NULL,
+  `to_address` varchar(48) NOT NULL,
+  `amount` decimal(65,0) NOT NULL,
+  `timestamp` int(10) unsigned NOT NULL,
+  `is_invalid` tinyint(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`tx_hash`,`notify_index`),
+  KEY `contract_height` (`contract`,`height`),
+  KEY `from_address_height` (`from_address`,`height`),
+  KEY `to_address_height` (`to_address`,`height`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `sync_state` (
+  `contract` varchar(48) NOT NULL,
+  `height` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`contract`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `token_owner` (
+  `contract` varchar(48) NOT NULL,
+  `token_id` varchar(128) NOT NULL,
+  `owner` varchar(48) NOT NULL,
+  `height` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`contract`,`token_id`),
+  KEY `contract_owner` (`contract`,`owner`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `contracts` (
+  `contract` varchar(48) NOT NULL,
+  `start_height` int(10) unsigned NOT NULL,
+  `state` tinyint(3) unsigned NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`contract`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `allowance` (
+  `owner` varchar(48) NOT NULL,
+  `contract` varchar(48) NOT NULL,
+  `spender` varchar(48) NOT NULL,
+  `amount` decimal(65,0) NOT NULL,
+  `tx_hash` varchar(64) NOT NULL,
+  `height` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`owner`,`contract`,`spender`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `approval` (
+  `owner` varchar(48) NOT NULL,
+  `contract` varchar(48) NOT NULL,
+  `spender` varchar(48) NOT NULL,
+  `height` int(10) unsigned NOT NULL,
+  `amount` decimal(65,0) NOT NULL,
+  `tx_hash` varchar(64) NOT NULL,
+  PRIMARY KEY (`owner`,`contract`,`spender`,`height`),
+  KEY `height` (`height`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `balance_mismatch` (
+  `address` varchar(48) NOT NULL,
+  `contract` varchar(48) NOT NULL,
+  `token_id` varchar(128) NOT NULL DEFAULT '',
+  `indexed_balance` decimal(65,0) NOT NULL,
+  `chain_balance` decimal(65,0) NOT NULL,
+  `height` int(10) unsigned NOT NULL,
+  `is_corrected` tinyint(1) NOT NULL,
+  `timestamp` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`address`,`contract`,`token_id`),
+  KEY `contract` (`contract`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `holder_snapshot` (
+  `contract` varchar(48) NOT NULL,
+  `token_id` varchar(128) NOT NULL DEFAULT '',
+  `height` int(10) unsigned NOT NULL,
+  `holders` int(10) unsigned NOT NULL,
+  `create_time` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`contract`,`token_id`,`height`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `snapshot_holder` (
+  `contract` varchar(48) NOT NULL,
+  `token_id` varchar(128) NOT NULL DEFAULT '',
+  `height` int(10) unsigned NOT NULL,
+  `address` varchar(48) NOT NULL,
+  `balance` decimal(65,0) NOT NULL,
+  `transactions` int(10) unsigned NOT NULL,
+  PRIMARY KEY (`contract`,`token_id`,`height`,`address`),
+  KEY `snapshot_balance` (`contract`,`token_id`,`height`,`balance`,`address`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,272 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log4 "github.com/alecthomas/log4go"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	JSONRPC_VERSION        = "2.0"
+	JSONRPC_MAX_BATCH_SIZE = 100
+	JSONRPC_MAX_BODY_SIZE  = 1024 * 1024
+
+	JSONRPC_ERR_PARSE            = -32700
+	JSONRPC_ERR_INVALID_REQUEST  = -32600
+	JSONRPC_ERR_METHOD_NOT_FOUND = -32601
+	JSONRPC_ERR_INVALID_PARAMS   = -32602
+	JSONRPC_ERR_INTERNAL         = -32603
+)
+
+//JsonRpcRequest is a JSON-RPC 2.0 request, it's a notification if it has no "id" member, while "id":null is a request.
+//Params must be an object, which is passed to the handler of method as named params, arrays of strings are joined by comma.
+type JsonRpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"`
+}
+
+type JsonRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+//JsonRpcResponse has either Result or Error, result is kept even if it's null
+type JsonRpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+//MarshalJSON omits result of error response, which must not exist by JSON-RPC 2.0
+func (this *JsonRpcResponse) MarshalJSON() ([]byte, error) {
+	if this.Error == nil {
+		type jsonRpcResponse JsonRpcResponse
+		return json.Marshal((*jsonRpcResponse)(this))
+	}
+	return json.Marshal(&struct {
+		JsonRpc string          `json:"jsonrpc"`
+		Error   *JsonRpcError   `json:"error"`
+		Id      json.RawMessage `json:"id"`
+	}{
+		JsonRpc: this.JsonRpc,
+		Error:   this.Error,
+		Id:      this.Id,
+	})
+}
+
+//JsonRpcHandler serves POST /jsonrpc, methods are the handlers registered by RegHandler.
+//A batch request is an array of requests, and its response is the array of responses except notifications.
+func (this *HttpServer) JsonRpcHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("content-type", "application/json;charset=utf-8")
+
+	var result interface{}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, JSONRPC_MAX_BODY_SIZE))
+	body = bytes.TrimSpace(body)
+	switch {
+	case err != nil || !json.Valid(body):
+		result = newJsonRpcErrorResponse(nil, JSONRPC_ERR_PARSE, "parse error")
+	case body[0] == '[':
+		rawRequests := make([]json.RawMessage, 0)
+		err = json.Unmarshal(body, &rawRequests)
+		if err != nil || len(rawRequests) == 0 {
+			result = newJsonRpcErrorResponse(nil, JSONRPC_ERR_INVALID_REQUEST, "invalid request")
+			break
+		}
+		if len(rawRequests) > JSONRPC_MAX_BATCH_SIZE {
+			result = newJsonRpcErrorResponse(nil, JSONRPC_ERR_INVALID_REQUEST, fmt.Sprintf("batch size exceed %d", JSONRPC_MAX_BATCH_SIZE))
+			break
+		}
+		responses := make([]*JsonRpcResponse, 0, len(rawRequests))
+		for _, rawRequest := range rawRequests {
+			resp := this.handleJsonRpc(rawRequest, r)
+			if resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) > 0 {
+			result = responses
+		}
+	default:
+		resp := this.handleJsonRpc(body, r)
+		if resp != nil {
+			result = resp
+		}
+	}
+	//Nothing is returned if all of requests are notifications
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		log4.Error("JsonRpcHandler json.Marshal response error:%s", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(data)
+	if err != nil {
+		log4.Error("JsonRpcHandler Write error:%s", err)
+	}
+}
+
+//handleJsonRpc calls the handler of a single request, and returns nil for notification
+func (this *HttpServer) handleJsonRpc(rawRequest json.RawMessage, r *http.Request) *JsonRpcResponse {
+	request := &JsonRpcRequest{}
+	err := json.Unmarshal(rawRequest, request)
+	if err != nil || request.JsonRpc != JSONRPC_VERSION || request.Method == "" {
+		return newJsonRpcErrorResponse(nil, JSONRPC_ERR_INVALID_REQUEST, "invalid request")
+	}
+	isNotification := isJsonRpcNotification(rawRequest)
+	id := request.Id
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	params, err := parseJsonRpcParams(request.Params)
+	if err != nil {
+		if isNotification {
+			return nil
+		}
+		return newJsonRpcErrorResponse(id, JSONRPC_ERR_INVALID_PARAMS, err.Error())
+	}
+
+	req := &HttpServerRequest{
+		Method:        request.Method,
+		HttpMethod:    r.Method,
+		Authorization: r.Header.Get("Authorization"),
+		Params:        params,
+	}
+	resp := &HttpServerResponse{
+		Method:    request.Method,
+		ErrorCode: ERR_SUCCESS,
+	}
+	this.callHandler(req, resp)
+	if isNotification {
+		return nil
+	}
+	if resp.ErrorCode != ERR_SUCCESS {
+		message := resp.ErrorInfo
+		if message == "" {
+			message = GetHttpServerErrorDesc(resp.ErrorCode)
+		}
+		return newJsonRpcErrorResponse(id, getJsonRpcErrorCode(resp.ErrorCode), message)
+	}
+	return &JsonRpcResponse{
+		JsonRpc: JSONRPC_VERSION,
+		Result:  resp.Result,
+		Id:      id,
+	}
+}
+
+//isJsonRpcNotification returns true if request has no "id" member. The member is looked up by key,
+//since a null id can't be told from a missing one once it's unmarshaled.
+func isJsonRpcNotification(rawRequest json.RawMessage) bool {
+	members := make(map[string]json.RawMessage)
+	err := json.Unmarshal(rawRequest, &members)
+	if err != nil {
+		return false
+	}
+	_, ok := members["id"]
+	return !ok
+}
+
+//parseJsonRpcParams converts params object to the string params of handler, values must be string, number, bool
+//or array of strings, which is joined by comma like the list params of query string.
+func parseJsonRpcParams(rawParams json.RawMessage) (map[string]string, error) {
+	params := make(map[string]string)
+	rawParams = bytes.TrimSpace(rawParams)
+	if len(rawParams) == 0 || bytes.Equal(rawParams, []byte("null")) {
+		return params, nil
+	}
+	values := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(rawParams))
+	decoder.UseNumber()
+	err := decoder.Decode(&values)
+	if err != nil {
+		return nil, fmt.Errorf("params must be an object")
+	}
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			params[strings.ToLower(key)] = v
+		case json.Number:
+			params[strings.ToLower(key)] = v.String()
+		case bool:
+			params[strings.ToLower(key)] = strconv.FormatBool(v)
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok || strings.Contains(str, ",") {
+					return nil, fmt.Errorf("invalid param:%s", key)
+				}
+				items = append(items, str)
+			}
+			params[strings.ToLower(key)] = strings.Join(items, ",")
+		case nil:
+		default:
+			return nil, fmt.Errorf("invalid param:%s", key)
+		}
+	}
+	return params, nil
+}
+
+//getJsonRpcErrorCode returns the JSON-RPC error code of handler error code, the codes without equivalent are kept
+func getJsonRpcErrorCode(errCode uint32) int {
+	switch errCode {
+	case ERR_INVALID_METHOD:
+		return JSONRPC_ERR_METHOD_NOT_FOUND
+	case ERR_INVALID_PARAMS:
+		return JSONRPC_ERR_INVALID_PARAMS
+	case ERR_INTERNAL:
+		return JSONRPC_ERR_INTERNAL
+	}
+	return int(errCode)
+}
+
+func newJsonRpcErrorResponse(id json.RawMessage, code int, message string) *JsonRpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &JsonRpcResponse{
+		JsonRpc: JSONRPC_VERSION,
+		Error:   &JsonRpcError{Code: code, Message: message},
+		Id:      id,
+	}
+}
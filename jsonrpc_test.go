@@ -0,0 +1,201 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJsonRpcParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		rawParams string
+		params    map[string]string
+		isErr     bool
+	}{
+		{name: "null", rawParams: "null", params: map[string]string{}},
+		{name: "empty", rawParams: "", params: map[string]string{}},
+		{
+			name:      "values",
+			rawParams: `{"Contract":"` + TEST_CONTRACT + `","from":0,"count":100,"snapshot":true,"cursor":null}`,
+			params:    map[string]string{"contract": TEST_CONTRACT, "from": "0", "count": "100", "snapshot": "true"},
+		},
+		{name: "large number", rawParams: `{"height":12345678901234567890}`, params: map[string]string{"height": "12345678901234567890"}},
+		{
+			name:      "array of strings",
+			rawParams: `{"addresses":["` + TEST_ADDRESS_A + `","` + TEST_ADDRESS_B + `"]}`,
+			params:    map[string]string{"addresses": TEST_ADDRESS_A + "," + TEST_ADDRESS_B},
+		},
+		{name: "empty array", rawParams: `{"contracts":[]}`, params: map[string]string{"contracts": ""}},
+		{name: "array of numbers", rawParams: `{"addresses":[1,2]}`, isErr: true},
+		{name: "array item with comma", rawParams: `{"addresses":["a,b"]}`, isErr: true},
+		{name: "object value", rawParams: `{"address":{"a":1}}`, isErr: true},
+		{name: "positional params", rawParams: `["` + TEST_CONTRACT + `"]`, isErr: true},
+	}
+	for _, test := range tests {
+		params, err := parseJsonRpcParams(json.RawMessage(test.rawParams))
+		if test.isErr {
+			if err == nil {
+				t.Errorf("%s: params %v, expect error", test.name, params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error:%s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: params %v, expect %v", test.name, params, test.params)
+		}
+	}
+}
+
+func TestJsonRpcResponseMarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *JsonRpcResponse
+		data string
+	}{
+		{"null result", &JsonRpcResponse{JsonRpc: JSONRPC_VERSION, Id: json.RawMessage("1")}, `{"jsonrpc":"2.0","result":null,"id":1}`},
+		{"zero result", &JsonRpcResponse{JsonRpc: JSONRPC_VERSION, Result: 0, Id: json.RawMessage("1")}, `{"jsonrpc":"2.0","result":0,"id":1}`},
+		{"error", newJsonRpcErrorResponse(json.RawMessage(`"a"`), JSONRPC_ERR_INVALID_PARAMS, "invalid params"),
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid params"},"id":"a"}`},
+		{"error without id", newJsonRpcErrorResponse(nil, JSONRPC_ERR_PARSE, "parse error"),
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.resp)
+		if err != nil {
+			t.Fatalf("%s: json.Marshal error:%s", test.name, err)
+		}
+		if string(data) != test.data {
+			t.Errorf("%s: %s, expect %s", test.name, data, test.data)
+		}
+	}
+}
+
+func TestJsonRpcHandler(t *testing.T) {
+	httpSvr := NewHttpServer()
+	httpSvr.RegHandler("echo", func(req *HttpServerRequest, resp *HttpServerResponse) {
+		value, ok := req.Params["value"]
+		if !ok {
+			resp.ErrorCode = ERR_INVALID_PARAMS
+			return
+		}
+		resp.Result = value
+	})
+	echo := func(id, value string) string {
+		if id == "" {
+			return `{"jsonrpc":"2.0","method":"echo","params":{"value":"` + value + `"}}`
+		}
+		return `{"jsonrpc":"2.0","method":"echo","params":{"value":"` + value + `"},"id":` + id + `}`
+	}
+	oversizeBatch := make([]string, JSONRPC_MAX_BATCH_SIZE+1)
+	for i := range oversizeBatch {
+		oversizeBatch[i] = echo("1", "a")
+	}
+
+	tests := []struct {
+		name       string
+		httpMethod string
+		body       string
+		statusCode int
+		response   string
+	}{
+		{
+			name:       "single",
+			httpMethod: http.MethodPost,
+			body:       echo("1", "a"),
+			statusCode: http.StatusOK,
+			response:   `{"jsonrpc":"2.0","result":"a","id":1}`,
+		},
+		{
+			name:       "batch with notification and invalid request",
+			httpMethod: http.MethodPost,
+			body:       "[" + echo(`"x"`, "a") + "," + echo("", "b") + `,{"method":"echo"},` + echo("2", "c") + "]",
+			statusCode: http.StatusOK,
+			response: `[{"jsonrpc":"2.0","result":"a","id":"x"},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null},` +
+				`{"jsonrpc":"2.0","result":"c","id":2}]`,
+		},
+		{
+			name:       "batch with errors",
+			httpMethod: http.MethodPost,
+			body:       `[{"jsonrpc":"2.0","method":"unknown","id":1},{"jsonrpc":"2.0","method":"echo","id":2}]`,
+			statusCode: http.StatusOK,
+			response: `[{"jsonrpc":"2.0","error":{"code":-32601,"message":"invalid method"},"id":1},` +
+				`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid params"},"id":2}]`,
+		},
+		{
+			name:       "null id",
+			httpMethod: http.MethodPost,
+			body:       "[" + echo("null", "a") + `,{"jsonrpc":"2.0","method":"echo","id":null}]`,
+			statusCode: http.StatusOK,
+			response: `[{"jsonrpc":"2.0","result":"a","id":null},` +
+				`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid params"},"id":null}]`,
+		},
+		{
+			name:       "notifications",
+			httpMethod: http.MethodPost,
+			body:       "[" + echo("", "a") + "," + echo("", "b") + "]",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "empty batch",
+			httpMethod: http.MethodPost,
+			body:       "[]",
+			statusCode: http.StatusOK,
+			response:   `{"jsonrpc":"2.0","error":{"code":-32600,"message":"invalid request"},"id":null}`,
+		},
+		{
+			name:       "oversize batch",
+			httpMethod: http.MethodPost,
+			body:       "[" + strings.Join(oversizeBatch, ",") + "]",
+			statusCode: http.StatusOK,
+			response:   `{"jsonrpc":"2.0","error":{"code":-32600,"message":"batch size exceed 100"},"id":null}`,
+		},
+		{
+			name:       "parse error",
+			httpMethod: http.MethodPost,
+			body:       `[{"jsonrpc":"2.0"`,
+			statusCode: http.StatusOK,
+			response:   `{"jsonrpc":"2.0","error":{"code":-32700,"message":"parse error"},"id":null}`,
+		},
+		{
+			name:       "get",
+			httpMethod: http.MethodGet,
+			statusCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		httpSvr.JsonRpcHandler(recorder, httptest.NewRequest(test.httpMethod, "/jsonrpc", strings.NewReader(test.body)))
+		if recorder.Code != test.statusCode {
+			t.Errorf("%s: status code:%d, expect %d", test.name, recorder.Code, test.statusCode)
+		}
+		if recorder.Body.String() != test.response {
+			t.Errorf("%s: response:%s, expect %s", test.name, recorder.Body.String(), test.response)
+		}
+	}
+}
@@ -0,0 +1,1426 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Items of keys are length prefixed by levelDBKey
+const (
+	LEVELDB_PREFIX_HOLDER          = byte(0x01) //holder + contract + address + token_id => AssetHolder
+	LEVELDB_PREFIX_HOLDER_ADDRESS  = byte(0x02) //holder address index + address + contract + token_id => contract
+	LEVELDB_PREFIX_EVENTNOTIFY     = byte(0x03) //eventnotify + tx_hash => TxEventNotify
+	LEVELDB_PREFIX_HEARTBEAT       = byte(0x04) //heartbeat + module => Heartbeat
+	LEVELDB_PREFIX_SYS             = byte(0x05) //sys + key => value
+	LEVELDB_PREFIX_TRANSFER        = byte(0x06) //transfer + contract + height + tx_hash + index => TxTransfer
+	LEVELDB_PREFIX_TRANSFER_INDEX  = byte(0x07) //transfer address index + address + height + tx_hash + index => contract
+	LEVELDB_PREFIX_SYNC_STATE      = byte(0x08) //sync state + contract => height
+	LEVELDB_PREFIX_CONTRACT        = byte(0x09) //contract + contract => MonitorContract
+	LEVELDB_PREFIX_TOKEN_OWNER     = byte(0x0a) //token owner + contract + token_id => TokenOwner
+	LEVELDB_PREFIX_OWNER_TOKEN     = byte(0x0b) //owner token index + contract + owner + token_id => nil
+	LEVELDB_PREFIX_ALLOWANCE       = byte(0x0c) //allowance + owner + contract + spender => Allowance
+	LEVELDB_PREFIX_MISMATCH        = byte(0x0d) //balance mismatch + contract + address + token_id => BalanceMismatch
+	LEVELDB_PREFIX_SNAPSHOT        = byte(0x0e) //holder snapshot + contract + token_id + height => HolderSnapshot
+	LEVELDB_PREFIX_SNAPSHOT_HOLDER = byte(0x0f) //snapshot holder + contract + token_id + height + rank => AssetHolder
+	LEVELDB_PREFIX_APPROVAL        = byte(0x10) //approval + owner + contract + spender + height => Allowance
+
+	LEVELDB_SYS_MAX_NOTIFY_HEIGHT = "max_notify_height"
+	LEVELDB_SYS_KEY_VERSION       = "key_version"
+
+	LEVELDB_KEY_VERSION = 2 //items of key are length prefixed since version 1, approvals are kept since version 2
+
+	LEVELDB_TIME_FORMAT = "2006-01-02 15:04:05"
+)
+
+//LevelDBHelper is an embedded HolderStore, for small deployments and tests that run without a MySQL server.
+type LevelDBHelper struct {
+	path       string
+	db         *leveldb.DB
+	lock       sync.Mutex
+	migrations []string //migrations applied by Open
+}
+
+func NewLevelDBHelper(path string) *LevelDBHelper {
+	return &LevelDBHelper{
+		path: path,
+	}
+}
+
+func (this *LevelDBHelper) Open() error {
+	db, err := leveldb.OpenFile(this.path, nil)
+	if err != nil {
+		return err
+	}
+	this.db = db
+	return this.upgradeKeys()
+}
+
+func (this *LevelDBHelper) Close() error {
+	return this.db.Close()
+}
+
+//levelDBKey returns prefix + items, every item is prefixed by its length in uvarint, so keys of different items never collide,
+//and a key of the leading items is the prefix of keys starting with them.
+func levelDBKey(prefix byte, items ...string) []byte {
+	size := 1
+	for _, item := range items {
+		size += binary.MaxVarintLen64 + len(item)
+	}
+	key := make([]byte, 0, size)
+	key = append(key, prefix)
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	for _, item := range items {
+		n := binary.PutUvarint(lenBuf, uint64(len(item)))
+		key = append(key, lenBuf[:n]...)
+		key = append(key, item...)
+	}
+	return key
+}
+
+//levelDBKeyItems decodes the length prefixed items of key, key is without prefix
+func levelDBKeyItems(key []byte) ([]string, error) {
+	items := make([]string, 0)
+	for len(key) > 0 {
+		size, n := binary.Uvarint(key)
+		if n <= 0 || uint64(len(key)-n) < size {
+			return nil, fmt.Errorf("invalid key item length")
+		}
+		items = append(items, string(key[n:n+int(size)]))
+		key = key[n+int(size):]
+	}
+	return items, nil
+}
+
+func (this *LevelDBHelper) getJson(key []byte, jsonObject interface{}) (bool, error) {
+	data, err := this.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("db.Get error:%s", err)
+	}
+	err = json.Unmarshal(data, jsonObject)
+	if err != nil {
+		return false, fmt.Errorf("json.Unmarshal error:%s", err)
+	}
+	return true, nil
+}
+
+func putJson(batch *leveldb.Batch, key []byte, jsonObject interface{}) error {
+	data, err := json.Marshal(jsonObject)
+	if err != nil {
+		return fmt.Errorf("json.Marshal error:%s", err)
+	}
+	batch.Put(key, data)
+	return nil
+}
+
+func (this *LevelDBHelper) getUint32(key []byte) (uint32, error) {
+	data, err := this.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("db.Get error:%s", err)
+	}
+	if len(data) != 4 {
+		return 0, fmt.Errorf("invalid uint32 value length:%d", len(data))
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func putUint32(batch *leveldb.Batch, key []byte, val uint32) {
+	batch.Put(key, []byte(uint32Key(val)))
+}
+
+//uint32Key encodes val in big endian, so keys are sorted by val.
+func uint32Key(val uint32) string {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, val)
+	return string(data)
+}
+
+//transferKeySuffix returns the items of transfer key after contract or address, which are height, tx_hash and index
+func transferKeySuffix(transfer *TxTransfer) []string {
+	return []string{uint32Key(transfer.Height), transfer.TxHash, uint32Key(uint32(transfer.Index))}
+}
+
+func transferKey(contract string, transfer *TxTransfer) []byte {
+	return levelDBKey(LEVELDB_PREFIX_TRANSFER, append([]string{contract}, transferKeySuffix(transfer)...)...)
+}
+
+func transferIndexKey(address string, transfer *TxTransfer) []byte {
+	return levelDBKey(LEVELDB_PREFIX_TRANSFER_INDEX, append([]string{address}, transferKeySuffix(transfer)...)...)
+}
+
+//transferHeightLimit returns the limit of transfer keys of contract up to height
+func transferHeightLimit(contract string, height uint32) []byte {
+	return util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_TRANSFER, contract, uint32Key(height))).Limit
+}
+
+//putAssetHolder puts holder, and the index of its address
+func putAssetHolder(batch *leveldb.Batch, holder *AssetHolder) error {
+	err := putJson(batch, levelDBKey(LEVELDB_PREFIX_HOLDER, holder.Contract, holder.Address, holder.TokenId), holder)
+	if err != nil {
+		return err
+	}
+	batch.Put(levelDBKey(LEVELDB_PREFIX_HOLDER_ADDRESS, holder.Address, holder.Contract, holder.TokenId), []byte(holder.Contract))
+	return nil
+}
+
+//putTransfer puts transfer, and the indexes of its from and to
+func putTransfer(batch *leveldb.Batch, transfer *TxTransfer) error {
+	err := putJson(batch, transferKey(transfer.Contract, transfer), transfer)
+	if err != nil {
+		return err
+	}
+	batch.Put(transferIndexKey(transfer.From, transfer), []byte(transfer.Contract))
+	if transfer.To != transfer.From {
+		batch.Put(transferIndexKey(transfer.To, transfer), []byte(transfer.Contract))
+	}
+	return nil
+}
+
+func approvalKey(approval *Allowance) []byte {
+	return levelDBKey(LEVELDB_PREFIX_APPROVAL, approval.Owner, approval.Contract, approval.Spender, uint32Key(approval.Height))
+}
+
+//putAllowance puts allowance, and keeps it as the approval at its height
+func putAllowance(batch *leveldb.Batch, allowance *Allowance) error {
+	err := putJson(batch, levelDBKey(LEVELDB_PREFIX_ALLOWANCE, allowance.Owner, allowance.Contract, allowance.Spender), allowance)
+	if err != nil {
+		return err
+	}
+	return putJson(batch, approvalKey(allowance), allowance)
+}
+
+func deleteTransfer(batch *leveldb.Batch, transfer *TxTransfer) {
+	batch.Delete(transferKey(transfer.Contract, transfer))
+	batch.Delete(transferIndexKey(transfer.From, transfer))
+	batch.Delete(transferIndexKey(transfer.To, transfer))
+}
+
+//OnTxEventNotify saves event notifies, holders, transfers, token owners, allowances and sync checkpoints in one leveldb batch.
+func (this *LevelDBHelper) OnTxEventNotify(evtNotify []*TxEventNotify, assetHolder []*AssetHolder, transfers []*TxTransfer, tokenOwners []*TokenOwner, allowances []*Allowance, syncStates []*SyncState) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	maxHeight, err := this.getUint32(levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_MAX_NOTIFY_HEIGHT))
+	if err != nil {
+		return fmt.Errorf("get max notify height error:%s", err)
+	}
+	batch := new(leveldb.Batch)
+	for _, notify := range evtNotify {
+		key := levelDBKey(LEVELDB_PREFIX_EVENTNOTIFY, notify.TxHash)
+		isExist, err := this.db.Has(key, nil)
+		if err != nil {
+			return fmt.Errorf("db.Has error:%s", err)
+		}
+		if isExist {
+			return fmt.Errorf("duplicate event notify tx_hash:%s", notify.TxHash)
+		}
+		err = putJson(batch, key, notify)
+		if err != nil {
+			return err
+		}
+		if notify.Height > maxHeight {
+			maxHeight = notify.Height
+		}
+	}
+	if len(evtNotify) > 0 {
+		putUint32(batch, levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_MAX_NOTIFY_HEIGHT), maxHeight)
+	}
+	for _, holder := range assetHolder {
+		err = putAssetHolder(batch, holder)
+		if err != nil {
+			return err
+		}
+	}
+	for _, transfer := range transfers {
+		err = putTransfer(batch, transfer)
+		if err != nil {
+			return err
+		}
+	}
+	for _, tokenOwner := range tokenOwners {
+		key := levelDBKey(LEVELDB_PREFIX_TOKEN_OWNER, tokenOwner.Contract, tokenOwner.TokenId)
+		oldTokenOwner := &TokenOwner{}
+		ok, err := this.getJson(key, oldTokenOwner)
+		if err != nil {
+			return err
+		}
+		if ok {
+			batch.Delete(levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, oldTokenOwner.Contract, oldTokenOwner.Owner, oldTokenOwner.TokenId))
+		}
+		err = putJson(batch, key, tokenOwner)
+		if err != nil {
+			return err
+		}
+		batch.Put(levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, tokenOwner.Contract, tokenOwner.Owner, tokenOwner.TokenId), nil)
+	}
+	for _, allowance := range allowances {
+		err = putAllowance(batch, allowance)
+		if err != nil {
+			return err
+		}
+	}
+	for _, syncState := range syncStates {
+		putUint32(batch, levelDBKey(LEVELDB_PREFIX_SYNC_STATE, syncState.Contract), syncState.Height)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	err = this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("OnTxEventNotify db.Write error:%s", err)
+	}
+	return nil
+}
+
+func (this *LevelDBHelper) Rollback(height uint32, assetHolders []*AssetHolder, tokenOwners []*TokenOwner) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	for _, holder := range assetHolders {
+		key := levelDBKey(LEVELDB_PREFIX_HOLDER, holder.Contract, holder.Address, holder.TokenId)
+		if holder.Balance.Sign() == 0 {
+			batch.Delete(key)
+			batch.Delete(levelDBKey(LEVELDB_PREFIX_HOLDER_ADDRESS, holder.Address, holder.Contract, holder.TokenId))
+			continue
+		}
+		err := putJson(batch, key, holder)
+		if err != nil {
+			return err
+		}
+	}
+	for _, tokenOwner := range tokenOwners {
+		key := levelDBKey(LEVELDB_PREFIX_TOKEN_OWNER, tokenOwner.Contract, tokenOwner.TokenId)
+		oldTokenOwner := &TokenOwner{}
+		ok, err := this.getJson(key, oldTokenOwner)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		batch.Delete(levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, oldTokenOwner.Contract, oldTokenOwner.Owner, oldTokenOwner.TokenId))
+		err = putJson(batch, key, tokenOwner)
+		if err != nil {
+			return err
+		}
+		batch.Put(levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, tokenOwner.Contract, tokenOwner.Owner, tokenOwner.TokenId), nil)
+	}
+
+	transfers, err := this.scanTransfer(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_TRANSFER)), height+1, math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	for _, transfer := range transfers {
+		deleteTransfer(batch, transfer)
+	}
+
+	maxHeight := uint32(0)
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_EVENTNOTIFY)), nil)
+	for iter.Next() {
+		notify := &TxEventNotify{}
+		err = json.Unmarshal(iter.Value(), notify)
+		if err != nil {
+			iter.Release()
+			return fmt.Errorf("json.Unmarshal notify error:%s", err)
+		}
+		if notify.Height > height {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		} else if notify.Height > maxHeight {
+			maxHeight = notify.Height
+		}
+	}
+	iter.Release()
+	err = iter.Error()
+	if err != nil {
+		return fmt.Errorf("iterator error:%s", err)
+	}
+	putUint32(batch, levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_MAX_NOTIFY_HEIGHT), maxHeight)
+
+	err = this.rollbackAllowances(batch, height)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := this.GetHolderSnapshots()
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Height <= height {
+			continue
+		}
+		err = this.deleteHolderSnapshot(batch, snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	syncStates, err := this.GetSyncStates()
+	if err != nil {
+		return err
+	}
+	for _, syncState := range syncStates {
+		if syncState.Height > height {
+			putUint32(batch, levelDBKey(LEVELDB_PREFIX_SYNC_STATE, syncState.Contract), height)
+		}
+	}
+
+	err = this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("Rollback db.Write error:%s", err)
+	}
+	return nil
+}
+
+//rollbackAllowances restores allowances approved above height to the last approval at or below height,
+//an allowance which isn't approved at or below height is deleted. Approvals above height are deleted.
+func (this *LevelDBHelper) rollbackAllowances(batch *leveldb.Batch, height uint32) error {
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_ALLOWANCE)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		allowance := &Allowance{}
+		err := json.Unmarshal(iter.Value(), allowance)
+		if err != nil {
+			return fmt.Errorf("json.Unmarshal allowance error:%s", err)
+		}
+		if allowance.Height <= height {
+			continue
+		}
+		approval, err := this.getLastApproval(allowance.Contract, allowance.Owner, allowance.Spender, height)
+		if err != nil {
+			return err
+		}
+		if approval == nil {
+			batch.Delete(append([]byte{}, iter.Key()...))
+			continue
+		}
+		err = putJson(batch, append([]byte{}, iter.Key()...), approval)
+		if err != nil {
+			return err
+		}
+	}
+	err := iter.Error()
+	if err != nil {
+		return fmt.Errorf("iterator error:%s", err)
+	}
+
+	approvalIter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_APPROVAL)), nil)
+	defer approvalIter.Release()
+	for approvalIter.Next() {
+		approval := &Allowance{}
+		err = json.Unmarshal(approvalIter.Value(), approval)
+		if err != nil {
+			return fmt.Errorf("json.Unmarshal approval error:%s", err)
+		}
+		if approval.Height > height {
+			batch.Delete(append([]byte{}, approvalIter.Key()...))
+		}
+	}
+	err = approvalIter.Error()
+	if err != nil {
+		return fmt.Errorf("iterator error:%s", err)
+	}
+	return nil
+}
+
+//getLastApproval returns the last approval of owner to spender at or below height, nil if there is none
+func (this *LevelDBHelper) getLastApproval(contract, owner, spender string, height uint32) (*Allowance, error) {
+	start := levelDBKey(LEVELDB_PREFIX_APPROVAL, owner, contract, spender)
+	limit := util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_APPROVAL, owner, contract, spender, uint32Key(height))).Limit
+	iter := this.db.NewIterator(&util.Range{Start: start, Limit: limit}, nil)
+	defer iter.Release()
+	if !iter.Last() {
+		err := iter.Error()
+		if err != nil {
+			return nil, fmt.Errorf("iterator error:%s", err)
+		}
+		return nil, nil
+	}
+	approval := &Allowance{}
+	err := json.Unmarshal(iter.Value(), approval)
+	if err != nil {
+		return nil, fmt.Errorf("json.Unmarshal approval error:%s", err)
+	}
+	return approval, nil
+}
+
+func (this *LevelDBHelper) ResetContract(contract string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	holders, err := this.scanAssetHolder(levelDBKey(LEVELDB_PREFIX_HOLDER, contract), nil)
+	if err != nil {
+		return err
+	}
+	for _, holder := range holders {
+		batch.Delete(levelDBKey(LEVELDB_PREFIX_HOLDER, holder.Contract, holder.Address, holder.TokenId))
+		batch.Delete(levelDBKey(LEVELDB_PREFIX_HOLDER_ADDRESS, holder.Address, holder.Contract, holder.TokenId))
+	}
+	transfers, err := this.scanTransfer(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_TRANSFER, contract)), 0, math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	for _, transfer := range transfers {
+		deleteTransfer(batch, transfer)
+	}
+	for _, prefix := range [][]byte{
+		levelDBKey(LEVELDB_PREFIX_TOKEN_OWNER, contract),
+		levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, contract),
+		levelDBKey(LEVELDB_PREFIX_MISMATCH, contract),
+		levelDBKey(LEVELDB_PREFIX_SNAPSHOT, contract),
+		levelDBKey(LEVELDB_PREFIX_SNAPSHOT_HOLDER, contract),
+	} {
+		iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+		for iter.Next() {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+		iter.Release()
+		err = iter.Error()
+		if err != nil {
+			return fmt.Errorf("iterator error:%s", err)
+		}
+	}
+	//Allowances and approvals are keyed by owner first
+	for _, prefix := range []byte{LEVELDB_PREFIX_ALLOWANCE, LEVELDB_PREFIX_APPROVAL} {
+		iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(prefix)), nil)
+		for iter.Next() {
+			allowance := &Allowance{}
+			err = json.Unmarshal(iter.Value(), allowance)
+			if err != nil {
+				iter.Release()
+				return fmt.Errorf("json.Unmarshal allowance error:%s", err)
+			}
+			if allowance.Contract == contract {
+				batch.Delete(append([]byte{}, iter.Key()...))
+			}
+		}
+		iter.Release()
+		err = iter.Error()
+		if err != nil {
+			return fmt.Errorf("iterator error:%s", err)
+		}
+	}
+	batch.Delete(levelDBKey(LEVELDB_PREFIX_SYNC_STATE, contract))
+
+	err = this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("ResetContract db.Write error:%s", err)
+	}
+	return nil
+}
+
+//Migrate of leveldb returns the migrations applied by Open, since keys written by an older version are upgraded when opened
+func (this *LevelDBHelper) Migrate() ([]string, error) {
+	return this.migrations, nil
+}
+
+//upgradeKeys rewrites the keys written by an older version, whose items are concatenated without length.
+//Since items of them can't be split, entries are rekeyed from their values, and indexes are rebuilt from the entries.
+func (this *LevelDBHelper) upgradeKeys() error {
+	versionKey := levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_KEY_VERSION)
+	version, err := this.getUint32(versionKey)
+	if err != nil {
+		return err
+	}
+	if version >= LEVELDB_KEY_VERSION {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	if version < 1 {
+		//Allowances are kept as approvals by upgradeKey as well
+		count, err := this.upgradeKeyItems(batch)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			this.migrations = append(this.migrations, fmt.Sprintf("upgrade %d keys to version %d", count, LEVELDB_KEY_VERSION))
+		}
+	} else {
+		count, err := this.putAllowanceApprovals(batch)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			this.migrations = append(this.migrations, fmt.Sprintf("keep %d allowances as approvals of version %d", count, LEVELDB_KEY_VERSION))
+		}
+	}
+	putUint32(batch, versionKey, LEVELDB_KEY_VERSION)
+	err = this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("upgradeKeys db.Write error:%s", err)
+	}
+	return nil
+}
+
+//upgradeKeyItems puts keys of version 0, whose items are concatenated, with length prefixed items to batch, and returns the count of keys
+func (this *LevelDBHelper) upgradeKeyItems(batch *leveldb.Batch) (int, error) {
+	//Old keys are deleted before new keys are put, so a new key is never deleted even if it equals an old key
+	newBatch := new(leveldb.Batch)
+	count := 0
+	iter := this.db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := append([]byte{}, iter.Key()...)
+		err := upgradeKey(newBatch, key, iter.Value())
+		if err != nil {
+			return 0, fmt.Errorf("upgrade key:%x error:%s", key, err)
+		}
+		batch.Delete(key)
+		count++
+	}
+	err := iter.Error()
+	if err != nil {
+		return 0, fmt.Errorf("iterator error:%s", err)
+	}
+	err = newBatch.Replay(batch)
+	if err != nil {
+		return 0, fmt.Errorf("batch.Replay error:%s", err)
+	}
+	return count, nil
+}
+
+//putAllowanceApprovals puts allowances saved by version 1 to batch as approvals, and returns the count of them.
+//Only the last approval of each allowance is known, since approvals aren't kept before version 2.
+func (this *LevelDBHelper) putAllowanceApprovals(batch *leveldb.Batch) (int, error) {
+	count := 0
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_ALLOWANCE)), nil)
+	defer iter.Release()
+	for iter.Next() {
+		allowance := &Allowance{}
+		err := json.Unmarshal(iter.Value(), allowance)
+		if err != nil {
+			return 0, fmt.Errorf("json.Unmarshal allowance error:%s", err)
+		}
+		batch.Put(approvalKey(allowance), append([]byte{}, iter.Value()...))
+		count++
+	}
+	err := iter.Error()
+	if err != nil {
+		return 0, fmt.Errorf("iterator error:%s", err)
+	}
+	return count, nil
+}
+
+//upgradeKey puts the entry of old key with new key to batch, indexes are skipped since they are put with the entries.
+func upgradeKey(batch *leveldb.Batch, key, value []byte) error {
+	var err error
+	switch key[0] {
+	case LEVELDB_PREFIX_HOLDER_ADDRESS, LEVELDB_PREFIX_TRANSFER_INDEX, LEVELDB_PREFIX_OWNER_TOKEN:
+		return nil
+	case LEVELDB_PREFIX_SYS, LEVELDB_PREFIX_SYNC_STATE:
+		batch.Put(levelDBKey(key[0], string(key[1:])), value)
+	case LEVELDB_PREFIX_HOLDER:
+		holder := &AssetHolder{}
+		err = json.Unmarshal(value, holder)
+		if err == nil {
+			err = putAssetHolder(batch, holder)
+		}
+	case LEVELDB_PREFIX_TRANSFER:
+		transfer := &TxTransfer{}
+		err = json.Unmarshal(value, transfer)
+		if err == nil {
+			err = putTransfer(batch, transfer)
+		}
+	case LEVELDB_PREFIX_TOKEN_OWNER:
+		tokenOwner := &TokenOwner{}
+		err = json.Unmarshal(value, tokenOwner)
+		if err == nil {
+			batch.Put(levelDBKey(LEVELDB_PREFIX_TOKEN_OWNER, tokenOwner.Contract, tokenOwner.TokenId), value)
+			batch.Put(levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, tokenOwner.Contract, tokenOwner.Owner, tokenOwner.TokenId), nil)
+		}
+	case LEVELDB_PREFIX_EVENTNOTIFY:
+		notify := &TxEventNotify{}
+		err = json.Unmarshal(value, notify)
+		if err == nil {
+			batch.Put(levelDBKey(LEVELDB_PREFIX_EVENTNOTIFY, notify.TxHash), value)
+		}
+	case LEVELDB_PREFIX_HEARTBEAT:
+		heartbeat := &Heartbeat{}
+		err = json.Unmarshal(value, heartbeat)
+		if err == nil {
+			batch.Put(levelDBKey(LEVELDB_PREFIX_HEARTBEAT, heartbeat.Module), value)
+		}
+	case LEVELDB_PREFIX_CONTRACT:
+		contract := &MonitorContract{}
+		err = json.Unmarshal(value, contract)
+		if err == nil {
+			batch.Put(levelDBKey(LEVELDB_PREFIX_CONTRACT, contract.Contract), value)
+		}
+	case LEVELDB_PREFIX_ALLOWANCE:
+		allowance := &Allowance{}
+		err = json.Unmarshal(value, allowance)
+		if err == nil {
+			err = putAllowance(batch, allowance)
+		}
+	case LEVELDB_PREFIX_MISMATCH:
+		mismatch := &BalanceMismatch{}
+		err = json.Unmarshal(value, mismatch)
+		if err == nil {
+			batch.Put(levelDBKey(LEVELDB_PREFIX_MISMATCH, mismatch.Contract, mismatch.Address, mismatch.TokenId), value)
+		}
+	default:
+		return fmt.Errorf("unknown key prefix:%d", key[0])
+	}
+	if err != nil {
+		return fmt.Errorf("json.Unmarshal error:%s", err)
+	}
+	return nil
+}
+
+//scanAssetHolder returns holders with key prefix, filter is used to select holders if it's not nil
+func (this *LevelDBHelper) scanAssetHolder(prefix []byte, filter func(holder *AssetHolder) bool) ([]*AssetHolder, error) {
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	holders := make([]*AssetHolder, 0)
+	for iter.Next() {
+		holder := &AssetHolder{}
+		err := json.Unmarshal(iter.Value(), holder)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal holder error:%s", err)
+		}
+		if filter != nil && !filter(holder) {
+			continue
+		}
+		holders = append(holders, holder)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return holders, nil
+}
+
+func (this *LevelDBHelper) getAssetHolderByAddress(address string) ([]*AssetHolder, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_HOLDER_ADDRESS, address)
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	holders := make([]*AssetHolder, 0)
+	for iter.Next() {
+		items, err := levelDBKeyItems(iter.Key()[len(prefix):])
+		if err != nil || len(items) != 2 {
+			return nil, fmt.Errorf("invalid holder address index:%x", iter.Key())
+		}
+		contract, tokenId := items[0], items[1]
+		holder := &AssetHolder{}
+		ok, err := this.getJson(levelDBKey(LEVELDB_PREFIX_HOLDER, contract, address, tokenId), holder)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			holders = append(holders, holder)
+		}
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return holders, nil
+}
+
+func (this *LevelDBHelper) GetAssetHolder(from, count int, cursor *HolderCursor, address, contract, tokenId string, isDescOrder ...bool) ([]*AssetHolder, error) {
+	isDesc := true
+	if len(isDescOrder) > 0 && !isDescOrder[0] {
+		isDesc = false
+	}
+	var holders []*AssetHolder
+	var err error
+	switch {
+	case contract != "" && address != "" && tokenId == "":
+		holders, err = this.scanAssetHolder(levelDBKey(LEVELDB_PREFIX_HOLDER, contract, address), nil)
+	case contract != "" && address != "":
+		holder := &AssetHolder{}
+		ok, e := this.getJson(levelDBKey(LEVELDB_PREFIX_HOLDER, contract, address, tokenId), holder)
+		if e != nil {
+			return nil, e
+		}
+		holders = make([]*AssetHolder, 0, 1)
+		if ok {
+			holders = append(holders, holder)
+		}
+	case contract != "":
+		holders, err = this.scanAssetHolder(levelDBKey(LEVELDB_PREFIX_HOLDER, contract), func(holder *AssetHolder) bool {
+			return holder.TokenId == tokenId
+		})
+	case address != "":
+		holders, err = this.getAssetHolderByAddress(address)
+	default:
+		holders, err = this.scanAssetHolder(levelDBKey(LEVELDB_PREFIX_HOLDER), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(holders, func(i, j int) bool {
+		return IsHolderBefore(holders[i].Balance, holders[i].Address, holders[j], isDesc)
+	})
+	return pageAssetHolders(holders, from, count, cursor, isDesc), nil
+}
+
+//pageAssetHolders returns a page of sorted holders, from is ignored if cursor is given
+func pageAssetHolders(holders []*AssetHolder, from, count int, cursor *HolderCursor, isDesc bool) []*AssetHolder {
+	if cursor != nil {
+		from = sort.Search(len(holders), func(i int) bool {
+			return cursor.IsAfter(holders[i], isDesc)
+		})
+	}
+	if from >= len(holders) {
+		return make([]*AssetHolder, 0)
+	}
+	if count == 0 {
+		return holders[from:]
+	}
+	end := from + count
+	if end > len(holders) {
+		end = len(holders)
+	}
+	return holders[from:end]
+}
+
+func (this *LevelDBHelper) scanTransfer(slice *util.Range, startHeight, endHeight uint32) ([]*TxTransfer, error) {
+	iter := this.db.NewIterator(slice, nil)
+	defer iter.Release()
+	transfers := make([]*TxTransfer, 0)
+	for iter.Next() {
+		transfer := &TxTransfer{}
+		err := json.Unmarshal(iter.Value(), transfer)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal transfer error:%s", err)
+		}
+		if transfer.Height < startHeight || transfer.Height > endHeight {
+			continue
+		}
+		transfers = append(transfers, transfer)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return transfers, nil
+}
+
+func (this *LevelDBHelper) getTransferByAddress(address, contract string, startHeight, endHeight uint32) ([]*TxTransfer, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_TRANSFER_INDEX, address)
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	transfers := make([]*TxTransfer, 0)
+	for iter.Next() {
+		items, err := levelDBKeyItems(iter.Key()[len(prefix):])
+		if err != nil || len(items) != 3 || len(items[0]) != 4 {
+			return nil, fmt.Errorf("invalid transfer address index:%x", iter.Key())
+		}
+		height := binary.BigEndian.Uint32([]byte(items[0]))
+		if height < startHeight || height > endHeight {
+			continue
+		}
+		transferContract := string(iter.Value())
+		if contract != "" && transferContract != contract {
+			continue
+		}
+		transfer := &TxTransfer{}
+		ok, err := this.getJson(levelDBKey(LEVELDB_PREFIX_TRANSFER, append([]string{transferContract}, items...)...), transfer)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			transfers = append(transfers, transfer)
+		}
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return transfers, nil
+}
+
+func (this *LevelDBHelper) GetTransferHistory(from, count int, address, contract string, startHeight, endHeight uint32) ([]*TxTransfer, error) {
+	var transfers []*TxTransfer
+	var err error
+	switch {
+	case address != "":
+		transfers, err = this.getTransferByAddress(address, contract, startHeight, endHeight)
+	case contract != "":
+		slice := &util.Range{
+			Start: levelDBKey(LEVELDB_PREFIX_TRANSFER, contract, uint32Key(startHeight)),
+			Limit: transferHeightLimit(contract, endHeight),
+		}
+		transfers, err = this.scanTransfer(slice, startHeight, endHeight)
+	default:
+		transfers, err = this.scanTransfer(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_TRANSFER)), startHeight, endHeight)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return IsTransferBefore(transfers[i], transfers[j])
+	})
+	if from >= len(transfers) {
+		return make([]*TxTransfer, 0), nil
+	}
+	end := from + count
+	if end > len(transfers) {
+		end = len(transfers)
+	}
+	return transfers[from:end], nil
+}
+
+func (this *LevelDBHelper) GetTokenOwner(contract, tokenId string) (*TokenOwner, error) {
+	tokenOwner := &TokenOwner{}
+	ok, err := this.getJson(levelDBKey(LEVELDB_PREFIX_TOKEN_OWNER, contract, tokenId), tokenOwner)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return tokenOwner, nil
+}
+
+func (this *LevelDBHelper) GetLastTokenTransfer(contract, tokenId string, height uint32) (*TxTransfer, error) {
+	slice := &util.Range{
+		Start: levelDBKey(LEVELDB_PREFIX_TRANSFER, contract),
+		Limit: transferHeightLimit(contract, height),
+	}
+	transfers, err := this.scanTransfer(slice, 0, height)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(transfers) - 1; i >= 0; i-- {
+		if transfers[i].TokenId == tokenId {
+			return transfers[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (this *LevelDBHelper) GetTokensOfOwner(from, count int, contract, owner string) ([]*TokenOwner, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_OWNER_TOKEN, contract, owner)
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	tokenOwners := make([]*TokenOwner, 0, count)
+	index := 0
+	for iter.Next() && len(tokenOwners) < count {
+		if index < from {
+			index++
+			continue
+		}
+		items, err := levelDBKeyItems(iter.Key()[len(prefix):])
+		if err != nil || len(items) != 1 {
+			return nil, fmt.Errorf("invalid owner token index:%x", iter.Key())
+		}
+		tokenOwner, err := this.GetTokenOwner(contract, items[0])
+		if err != nil {
+			return nil, err
+		}
+		if tokenOwner != nil {
+			tokenOwners = append(tokenOwners, tokenOwner)
+		}
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return tokenOwners, nil
+}
+
+func (this *LevelDBHelper) GetAllowance(contract, owner, spender string) (*Allowance, error) {
+	allowance := &Allowance{}
+	ok, err := this.getJson(levelDBKey(LEVELDB_PREFIX_ALLOWANCE, owner, contract, spender), allowance)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return allowance, nil
+}
+
+func (this *LevelDBHelper) GetAllowancesByOwner(from, count int, owner, contract string) ([]*Allowance, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_ALLOWANCE, owner)
+	if contract != "" {
+		prefix = levelDBKey(LEVELDB_PREFIX_ALLOWANCE, owner, contract)
+	}
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	allowances := make([]*Allowance, 0, count)
+	index := 0
+	for iter.Next() && len(allowances) < count {
+		if index < from {
+			index++
+			continue
+		}
+		allowance := &Allowance{}
+		err := json.Unmarshal(iter.Value(), allowance)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal allowance error:%s", err)
+		}
+		allowances = append(allowances, allowance)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return allowances, nil
+}
+
+//GetAssetHolderAtHeight pages holders of the snapshot saved by SaveHolderSnapshot, which are keyed by rank.
+func (this *LevelDBHelper) GetAssetHolderAtHeight(from, count int, cursor *HolderCursor, contract, tokenId string, height uint32) ([]*AssetHolder, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_SNAPSHOT_HOLDER, contract, tokenId, uint32Key(height))
+	slice := util.BytesPrefix(prefix)
+	if cursor == nil {
+		slice.Start = levelDBKey(LEVELDB_PREFIX_SNAPSHOT_HOLDER, contract, tokenId, uint32Key(height), uint32Key(uint32(from)))
+		from = 0
+	}
+	iter := this.db.NewIterator(slice, nil)
+	defer iter.Release()
+	holders := make([]*AssetHolder, 0)
+	for iter.Next() {
+		holder := &AssetHolder{}
+		err := json.Unmarshal(iter.Value(), holder)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal holder error:%s", err)
+		}
+		holders = append(holders, holder)
+		if cursor == nil && count > 0 && len(holders) == count {
+			break
+		}
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return pageAssetHolders(holders, from, count, cursor, true), nil
+}
+
+func (this *LevelDBHelper) GetFirstTransferHeight(contract string) (uint32, bool, error) {
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_TRANSFER, contract)), nil)
+	defer iter.Release()
+	if !iter.Next() {
+		return 0, false, iter.Error()
+	}
+	transfer := &TxTransfer{}
+	err := json.Unmarshal(iter.Value(), transfer)
+	if err != nil {
+		return 0, false, fmt.Errorf("json.Unmarshal transfer error:%s", err)
+	}
+	return transfer.Height, true, nil
+}
+
+func (this *LevelDBHelper) GetHolderSnapshots() ([]*HolderSnapshot, error) {
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_SNAPSHOT)), nil)
+	defer iter.Release()
+	snapshots := make([]*HolderSnapshot, 0)
+	for iter.Next() {
+		snapshot := &HolderSnapshot{}
+		err := json.Unmarshal(iter.Value(), snapshot)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal snapshot error:%s", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].CreateTime < snapshots[j].CreateTime
+	})
+	return snapshots, nil
+}
+
+//SaveHolderSnapshot saves holders keyed by their rank, holders must be ordered by balance desc.
+func (this *LevelDBHelper) SaveHolderSnapshot(snapshot *HolderSnapshot, holders []*AssetHolder) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	err := this.deleteHolderSnapshot(batch, snapshot)
+	if err != nil {
+		return err
+	}
+	for rank, holder := range holders {
+		key := levelDBKey(LEVELDB_PREFIX_SNAPSHOT_HOLDER, snapshot.Contract, snapshot.TokenId, uint32Key(snapshot.Height), uint32Key(uint32(rank)))
+		err = putJson(batch, key, holder)
+		if err != nil {
+			return err
+		}
+	}
+	err = putJson(batch, levelDBKey(LEVELDB_PREFIX_SNAPSHOT, snapshot.Contract, snapshot.TokenId, uint32Key(snapshot.Height)), snapshot)
+	if err != nil {
+		return err
+	}
+	err = this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("SaveHolderSnapshot db.Write error:%s", err)
+	}
+	return nil
+}
+
+func (this *LevelDBHelper) DeleteHolderSnapshot(snapshot *HolderSnapshot) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	batch := new(leveldb.Batch)
+	err := this.deleteHolderSnapshot(batch, snapshot)
+	if err != nil {
+		return err
+	}
+	err = this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteHolderSnapshot db.Write error:%s", err)
+	}
+	return nil
+}
+
+//deleteHolderSnapshot deletes snapshot and its holders in batch
+func (this *LevelDBHelper) deleteHolderSnapshot(batch *leveldb.Batch, snapshot *HolderSnapshot) error {
+	batch.Delete(levelDBKey(LEVELDB_PREFIX_SNAPSHOT, snapshot.Contract, snapshot.TokenId, uint32Key(snapshot.Height)))
+	prefix := levelDBKey(LEVELDB_PREFIX_SNAPSHOT_HOLDER, snapshot.Contract, snapshot.TokenId, uint32Key(snapshot.Height))
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+	err := iter.Error()
+	if err != nil {
+		return fmt.Errorf("iterator error:%s", err)
+	}
+	return nil
+}
+
+func (this *LevelDBHelper) GetSyncStates() ([]*SyncState, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_SYNC_STATE)
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	syncStates := make([]*SyncState, 0)
+	for iter.Next() {
+		if len(iter.Value()) != 4 {
+			return nil, fmt.Errorf("invalid sync state value length:%d", len(iter.Value()))
+		}
+		items, err := levelDBKeyItems(iter.Key()[len(prefix):])
+		if err != nil || len(items) != 1 {
+			return nil, fmt.Errorf("invalid sync state key:%x", iter.Key())
+		}
+		syncStates = append(syncStates, &SyncState{
+			Contract: items[0],
+			Height:   binary.BigEndian.Uint32(iter.Value()),
+		})
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return syncStates, nil
+}
+
+func (this *LevelDBHelper) GetContracts() ([]*MonitorContract, error) {
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_CONTRACT)), nil)
+	defer iter.Release()
+	contracts := make([]*MonitorContract, 0)
+	for iter.Next() {
+		contract := &MonitorContract{}
+		err := json.Unmarshal(iter.Value(), contract)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal contract error:%s", err)
+		}
+		contracts = append(contracts, contract)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return contracts, nil
+}
+
+func (this *LevelDBHelper) SaveContract(contract *MonitorContract) error {
+	batch := new(leveldb.Batch)
+	err := putJson(batch, levelDBKey(LEVELDB_PREFIX_CONTRACT, contract.Contract), contract)
+	if err != nil {
+		return err
+	}
+	return this.db.Write(batch, nil)
+}
+
+func (this *LevelDBHelper) GetSyncedEventNotifyBlockHeight() (uint32, error) {
+	return this.getUint32(levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_MAX_NOTIFY_HEIGHT))
+}
+
+func (this *LevelDBHelper) IsGenesisInit() (bool, error) {
+	iter := this.db.NewIterator(util.BytesPrefix(levelDBKey(LEVELDB_PREFIX_HOLDER)), nil)
+	defer iter.Release()
+	isInit := iter.Next()
+	err := iter.Error()
+	if err != nil {
+		return false, fmt.Errorf("iterator error:%s", err)
+	}
+	return isInit, nil
+}
+
+func (this *LevelDBHelper) IsEventNotifyExist(txHashes []string) (map[string]bool, error) {
+	if len(txHashes) == 0 {
+		return nil, nil
+	}
+	txHashMap := make(map[string]bool, 0)
+	for _, txHash := range txHashes {
+		isExist, err := this.db.Has(levelDBKey(LEVELDB_PREFIX_EVENTNOTIFY, txHash), nil)
+		if err != nil {
+			return nil, fmt.Errorf("db.Has error:%s", err)
+		}
+		if isExist {
+			txHashMap[txHash] = true
+		}
+	}
+	return txHashMap, nil
+}
+
+func (this *LevelDBHelper) GetBalances(addresses, contracts []string) ([]*AssetHolder, error) {
+	contractMap := make(map[string]bool, len(contracts))
+	for _, contract := range contracts {
+		contractMap[contract] = true
+	}
+	holders := make([]*AssetHolder, 0, len(addresses))
+	for _, address := range addresses {
+		addressHolders, err := this.getAssetHolderByAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		for _, holder := range addressHolders {
+			if len(contractMap) > 0 && !contractMap[holder.Contract] {
+				continue
+			}
+			holders = append(holders, holder)
+		}
+	}
+	return holders, nil
+}
+
+func (this *LevelDBHelper) GetAllAssetHolders(from, count int) ([]*AssetHolder, error) {
+	iter := this.db.NewIterator(util.BytesPrefix([]byte{LEVELDB_PREFIX_HOLDER}), nil)
+	defer iter.Release()
+	holders := make([]*AssetHolder, 0, count)
+	index := 0
+	for iter.Next() && len(holders) < count {
+		if index < from {
+			index++
+			continue
+		}
+		holder := &AssetHolder{}
+		err := json.Unmarshal(iter.Value(), holder)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal holder error:%s", err)
+		}
+		holders = append(holders, holder)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return holders, nil
+}
+
+func (this *LevelDBHelper) SaveBalanceMismatches(mismatches []*BalanceMismatch) error {
+	if len(mismatches) == 0 {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for _, mismatch := range mismatches {
+		err := putJson(batch, levelDBKey(LEVELDB_PREFIX_MISMATCH, mismatch.Contract, mismatch.Address, mismatch.TokenId), mismatch)
+		if err != nil {
+			return err
+		}
+	}
+	err := this.db.Write(batch, nil)
+	if err != nil {
+		return fmt.Errorf("db.Write error:%s", err)
+	}
+	return nil
+}
+
+func (this *LevelDBHelper) GetBalanceMismatches(from, count int, contract string) ([]*BalanceMismatch, error) {
+	prefix := levelDBKey(LEVELDB_PREFIX_MISMATCH)
+	if contract != "" {
+		prefix = levelDBKey(LEVELDB_PREFIX_MISMATCH, contract)
+	}
+	iter := this.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer iter.Release()
+	mismatches := make([]*BalanceMismatch, 0, count)
+	index := 0
+	for iter.Next() && len(mismatches) < count {
+		if index < from {
+			index++
+			continue
+		}
+		mismatch := &BalanceMismatch{}
+		err := json.Unmarshal(iter.Value(), mismatch)
+		if err != nil {
+			return nil, fmt.Errorf("json.Unmarshal mismatch error:%s", err)
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	err := iter.Error()
+	if err != nil {
+		return nil, fmt.Errorf("iterator error:%s", err)
+	}
+	return mismatches, nil
+}
+
+func (this *LevelDBHelper) GetAssetHolderByKey(holders []*AssetHolder) (map[string]*AssetHolder, error) {
+	count := len(holders)
+	if count == 0 {
+		return nil, nil
+	}
+	holderMap := make(map[string]*AssetHolder, count)
+	for _, item := range holders {
+		holder := &AssetHolder{}
+		ok, err := this.getJson(levelDBKey(LEVELDB_PREFIX_HOLDER, item.Contract, item.Address, item.TokenId), holder)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			holderMap[holder.Key()] = holder
+		}
+	}
+	return holderMap, nil
+}
+
+func (this *LevelDBHelper) GetAssetHolderCount(contract, tokenId string) (int, error) {
+	holders, err := this.scanAssetHolder(levelDBKey(LEVELDB_PREFIX_HOLDER, contract), func(holder *AssetHolder) bool {
+		return holder.TokenId == tokenId
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(holders), nil
+}
+
+func (this *LevelDBHelper) GetAssetHolderCounts() (map[string]int, error) {
+	holders, err := this.scanAssetHolder(levelDBKey(LEVELDB_PREFIX_HOLDER), nil)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, holder := range holders {
+		counts[holder.Contract+holder.TokenId]++
+	}
+	return counts, nil
+}
+
+func (this *LevelDBHelper) GetHeartbeat(module string) (*Heartbeat, error) {
+	heartbeat := &Heartbeat{}
+	ok, err := this.getJson(levelDBKey(LEVELDB_PREFIX_HEARTBEAT, module), heartbeat)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return heartbeat, nil
+}
+
+func (this *LevelDBHelper) putHeartbeat(heartbeat *Heartbeat) error {
+	heartbeat.UpdateTime = time.Now().Format(LEVELDB_TIME_FORMAT)
+	batch := new(leveldb.Batch)
+	err := putJson(batch, levelDBKey(LEVELDB_PREFIX_HEARTBEAT, heartbeat.Module), heartbeat)
+	if err != nil {
+		return err
+	}
+	return this.db.Write(batch, nil)
+}
+
+func (this *LevelDBHelper) InsertHeartbeat(heartbeat *Heartbeat) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	old, err := this.GetHeartbeat(heartbeat.Module)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		return fmt.Errorf("heartbeat module:%s already exist", heartbeat.Module)
+	}
+	return this.putHeartbeat(heartbeat)
+}
+
+func (this *LevelDBHelper) UpdateHeartbeat(module string, nodeId uint32) (bool, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	heartbeat, err := this.GetHeartbeat(module)
+	if err != nil {
+		return false, err
+	}
+	if heartbeat == nil || heartbeat.NodeId != nodeId {
+		return false, nil
+	}
+	err = this.putHeartbeat(heartbeat)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (this *LevelDBHelper) CheckHeartbeatTimeout(module string, timeout uint32) (uint32, error) {
+	heartbeat, err := this.GetHeartbeat(module)
+	if err != nil {
+		return 0, err
+	}
+	if heartbeat == nil {
+		return 0, nil
+	}
+	updateTime, err := time.ParseInLocation(LEVELDB_TIME_FORMAT, heartbeat.UpdateTime, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("time.Parse update time:%s error:%s", heartbeat.UpdateTime, err)
+	}
+	if time.Since(updateTime) < time.Duration(timeout)*time.Second {
+		return 0, nil
+	}
+	return heartbeat.NodeId, nil
+}
+
+func (this *LevelDBHelper) ResetHeartbeat(module string, nodeId, lastNodeId uint32) (bool, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	heartbeat, err := this.GetHeartbeat(module)
+	if err != nil {
+		return false, err
+	}
+	if heartbeat == nil || heartbeat.NodeId != lastNodeId {
+		return false, nil
+	}
+	heartbeat.NodeId = nodeId
+	err = this.putHeartbeat(heartbeat)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
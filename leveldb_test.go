@@ -0,0 +1,377 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"math/big"
+	"testing"
+)
+
+func TestLevelDBGetAssetHolderByCursor(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	//Balances tie, so pages are ordered by address
+	balances := []int64{50, 30, 30, 30, 30, 10}
+	for i, balance := range balances {
+		indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, testAddress(i), balance)
+	}
+
+	for _, count := range []int{1, 2, 4, 6} {
+		holders := make([]*AssetHolder, 0)
+		var cursor *HolderCursor
+		for page := 0; page <= len(balances); page++ {
+			items, err := store.GetAssetHolder(0, count, cursor, "", TEST_CONTRACT, "")
+			if err != nil {
+				t.Fatalf("GetAssetHolder error:%s", err)
+			}
+			holders = append(holders, items...)
+			if len(items) < count {
+				break
+			}
+			cursor = NewHolderCursor(items[len(items)-1], false, 0)
+		}
+		if len(holders) != len(balances) {
+			t.Fatalf("count:%d holders:%d, expect %d", count, len(holders), len(balances))
+		}
+		for i := 1; i < len(holders); i++ {
+			if !IsHolderBefore(holders[i-1].Balance, holders[i-1].Address, holders[i], true) {
+				t.Errorf("count:%d holder %d %s:%s is not before %s:%s", count, i,
+					holders[i-1].Address, holders[i-1].Balance, holders[i].Address, holders[i].Balance)
+			}
+		}
+	}
+
+	//A cursor is stable when holders before it change
+	first, err := store.GetAssetHolder(0, 2, nil, "", TEST_CONTRACT, "")
+	if err != nil {
+		t.Fatalf("GetAssetHolder error:%s", err)
+	}
+	cursor := NewHolderCursor(first[1], false, 0)
+	indexer.transfer(TEST_CONTRACT, "", first[0].Address, ZERO_ADDRESS, 45)
+	next, err := store.GetAssetHolder(0, 10, cursor, "", TEST_CONTRACT, "")
+	if err != nil {
+		t.Fatalf("GetAssetHolder error:%s", err)
+	}
+	if len(next) != len(balances)-1 {
+		t.Errorf("holders after cursor:%d, expect %d", len(next), len(balances)-1)
+	}
+	for _, holder := range next {
+		if holder.Address == first[1].Address {
+			t.Errorf("holder %s of cursor is repeated", holder.Address)
+		}
+	}
+}
+
+func testAddress(i int) string {
+	return fmt.Sprintf("%040x", i+1)
+}
+
+func TestPageAssetHolders(t *testing.T) {
+	//Holders in desc order, balances of the last three tie
+	descHolders := []*AssetHolder{
+		{Address: "e", Balance: big.NewInt(50)},
+		{Address: "d", Balance: big.NewInt(30)},
+		{Address: "c", Balance: big.NewInt(10)},
+		{Address: "b", Balance: big.NewInt(10)},
+		{Address: "a", Balance: big.NewInt(10)},
+	}
+	ascHolders := make([]*AssetHolder, 0, len(descHolders))
+	for i := len(descHolders) - 1; i >= 0; i-- {
+		ascHolders = append(ascHolders, descHolders[i])
+	}
+	cursor := func(balance int64, address string) *HolderCursor {
+		return &HolderCursor{Balance: big.NewInt(balance), Address: address}
+	}
+	tests := []struct {
+		name      string
+		from      int
+		count     int
+		cursor    *HolderCursor
+		isDesc    bool
+		addresses string
+	}{
+		{name: "all", isDesc: true, addresses: "edcba"},
+		{name: "from", from: 2, isDesc: true, addresses: "cba"},
+		{name: "count", count: 2, isDesc: true, addresses: "ed"},
+		{name: "from and count", from: 1, count: 2, isDesc: true, addresses: "dc"},
+		{name: "count beyond", from: 3, count: 10, isDesc: true, addresses: "ba"},
+		{name: "from beyond", from: 5, count: 10, isDesc: true, addresses: ""},
+		{name: "from beyond of all", from: 6, isDesc: true, addresses: ""},
+		{name: "cursor", count: 2, cursor: cursor(30, "d"), isDesc: true, addresses: "cb"},
+		{name: "cursor of tie", count: 2, cursor: cursor(10, "c"), isDesc: true, addresses: "ba"},
+		{name: "cursor over from", from: 4, count: 1, cursor: cursor(50, "e"), isDesc: true, addresses: "d"},
+		{name: "cursor of removed holder", cursor: cursor(20, "x"), isDesc: true, addresses: "cba"},
+		{name: "cursor of last", cursor: cursor(10, "a"), isDesc: true, addresses: ""},
+		{name: "asc", count: 3, addresses: "abc"},
+		{name: "asc cursor", count: 2, cursor: cursor(10, "b"), addresses: "cd"},
+		{name: "asc cursor of removed holder", cursor: cursor(40, "x"), addresses: "e"},
+	}
+	for _, test := range tests {
+		holders := ascHolders
+		if test.isDesc {
+			holders = descHolders
+		}
+		addresses := ""
+		for _, holder := range pageAssetHolders(holders, test.from, test.count, test.cursor, test.isDesc) {
+			addresses += holder.Address
+		}
+		if addresses != test.addresses {
+			t.Errorf("%s: holders:%s, expect %s", test.name, addresses, test.addresses)
+		}
+	}
+}
+
+func TestLevelDBKey(t *testing.T) {
+	//Keys of these items are the same if items are concatenated
+	cases := [][2][]string{
+		{{"ab", "c"}, {"a", "bc"}},
+		{{"a", ""}, {"", "a"}},
+		{{"abc"}, {"ab", "c"}},
+		{{"a", "b", "c"}, {"ab", "", "c"}},
+	}
+	for _, c := range cases {
+		key0 := levelDBKey(LEVELDB_PREFIX_HOLDER, c[0]...)
+		key1 := levelDBKey(LEVELDB_PREFIX_HOLDER, c[1]...)
+		if bytes.Equal(key0, key1) {
+			t.Errorf("keys of %q and %q collide", c[0], c[1])
+		}
+		for _, items := range c {
+			key := levelDBKey(LEVELDB_PREFIX_HOLDER, items...)
+			decoded, err := levelDBKeyItems(key[1:])
+			if err != nil || fmt.Sprint(decoded) != fmt.Sprint(items) || len(decoded) != len(items) {
+				t.Errorf("levelDBKeyItems of %q:%q error:%v", items, decoded, err)
+			}
+		}
+	}
+	//A key of leading items is the prefix of keys starting with them, but not of keys whose item starts with them
+	prefix := levelDBKey(LEVELDB_PREFIX_HOLDER, "ab")
+	if !bytes.HasPrefix(levelDBKey(LEVELDB_PREFIX_HOLDER, "ab", "c"), prefix) {
+		t.Errorf("key of leading items isn't prefix")
+	}
+	if bytes.HasPrefix(levelDBKey(LEVELDB_PREFIX_HOLDER, "abc"), prefix) {
+		t.Errorf("key of item is prefix of longer item")
+	}
+	_, err := levelDBKeyItems([]byte{0x05, 'a'})
+	if err == nil {
+		t.Errorf("levelDBKeyItems of truncated item succeeded")
+	}
+}
+
+//TestLevelDBHelperItemPrefix saves items which are prefix of others, they are returned only by their own keys
+func TestLevelDBHelperItemPrefix(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	const contract, address, longAddress = "c1", "aa", "aab"
+	holders := []*AssetHolder{
+		{Address: address, Contract: contract, TokenId: "1", Balance: big.NewInt(1)},
+		{Address: longAddress, Contract: contract, TokenId: "", Balance: big.NewInt(2)},
+		{Address: address, Contract: contract + "2", TokenId: "", Balance: big.NewInt(3)},
+	}
+	transfers := []*TxTransfer{
+		{TxHash: "01", Height: 1, Contract: contract, From: ZERO_ADDRESS, To: address, Amount: big.NewInt(1)},
+		{TxHash: "02", Height: 2, Contract: contract, From: ZERO_ADDRESS, To: longAddress, Amount: big.NewInt(2)},
+		{TxHash: "03", Height: 256, Contract: contract + "2", From: ZERO_ADDRESS, To: address, Amount: big.NewInt(3)},
+	}
+	tokenOwners := []*TokenOwner{
+		{Contract: contract, TokenId: "1", Owner: address},
+		{Contract: contract, TokenId: "2", Owner: longAddress},
+	}
+	allowances := []*Allowance{
+		{Contract: contract, Owner: address, Spender: longAddress, Amount: big.NewInt(1)},
+		{Contract: contract, Owner: longAddress, Spender: address, Amount: big.NewInt(2)},
+	}
+	syncStates := []*SyncState{{Contract: contract, Height: 2}, {Contract: contract + "2", Height: 256}}
+	err := store.OnTxEventNotify(nil, holders, transfers, tokenOwners, allowances, syncStates)
+	if err != nil {
+		t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+
+	result, err := store.GetAssetHolder(0, 10, nil, address, contract, "")
+	if err != nil || len(result) != 1 || result[0].TokenId != "1" {
+		t.Errorf("GetAssetHolder of address:%v error:%v", result, err)
+	}
+	result, err = store.GetBalances([]string{address}, nil)
+	if err != nil || len(result) != 2 {
+		t.Errorf("GetBalances of address:%v error:%v", result, err)
+	}
+	history, err := store.GetTransferHistory(0, 10, address, "", 0, 1000)
+	if err != nil || len(history) != 2 || history[0].TxHash != "03" || history[1].TxHash != "01" {
+		t.Errorf("GetTransferHistory of address:%v error:%v", history, err)
+	}
+	history, err = store.GetTransferHistory(0, 10, "", contract, 0, 1)
+	if err != nil || len(history) != 1 || history[0].TxHash != "01" {
+		t.Errorf("GetTransferHistory of contract to height 1:%v error:%v", history, err)
+	}
+	history, err = store.GetTransferHistory(0, 10, "", contract+"2", 255, 256)
+	if err != nil || len(history) != 1 || history[0].TxHash != "03" {
+		t.Errorf("GetTransferHistory of contract at height 256:%v error:%v", history, err)
+	}
+	owned, err := store.GetTokensOfOwner(0, 10, contract, address)
+	if err != nil || len(owned) != 1 || owned[0].TokenId != "1" {
+		t.Errorf("GetTokensOfOwner:%v error:%v", owned, err)
+	}
+	approved, err := store.GetAllowancesByOwner(0, 10, address, "")
+	if err != nil || len(approved) != 1 || approved[0].Spender != longAddress {
+		t.Errorf("GetAllowancesByOwner:%v error:%v", approved, err)
+	}
+	states, err := store.GetSyncStates()
+	if err != nil || len(states) != 2 || states[0].Contract != contract || states[1].Contract != contract+"2" {
+		t.Errorf("GetSyncStates:%v error:%v", states, err)
+	}
+}
+
+//oldLevelDBKey is the key written by an older version, whose items are concatenated
+func oldLevelDBKey(prefix byte, items ...string) []byte {
+	key := []byte{prefix}
+	for _, item := range items {
+		key = append(key, item...)
+	}
+	return key
+}
+
+func TestLevelDBHelperUpgradeKeys(t *testing.T) {
+	path := t.TempDir()
+	store := NewLevelDBHelper(path)
+	err := store.Open()
+	if err != nil {
+		t.Fatalf("Open error:%s", err)
+	}
+	//Write entries as an older version
+	err = store.db.Delete(levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_KEY_VERSION), nil)
+	if err != nil {
+		t.Fatalf("db.Delete error:%s", err)
+	}
+	holder := `{"Address":"` + TEST_ADDRESS_A + `","Contract":"` + TEST_CONTRACT + `","TokenId":"","Balance":100,"Transactions":1}`
+	transfer := `{"TxHash":"` + fmt.Sprintf("%064x", 1) + `","Index":0,"Height":1,"Name":"transfer","Contract":"` + TEST_CONTRACT +
+		`","From":"` + ZERO_ADDRESS + `","To":"` + TEST_ADDRESS_A + `","Amount":100}`
+	notify := `{"TxHash":"` + fmt.Sprintf("%064x", 1) + `","Height":1,"State":1}`
+	entries := map[string]string{
+		string(oldLevelDBKey(LEVELDB_PREFIX_HOLDER, TEST_CONTRACT, TEST_ADDRESS_A)):                                        holder,
+		string(oldLevelDBKey(LEVELDB_PREFIX_HOLDER_ADDRESS, TEST_ADDRESS_A, TEST_CONTRACT)):                                "",
+		string(oldLevelDBKey(LEVELDB_PREFIX_TRANSFER, TEST_CONTRACT, uint32Key(1), fmt.Sprintf("%064x", 1), uint32Key(0))): transfer,
+		string(oldLevelDBKey(LEVELDB_PREFIX_EVENTNOTIFY, fmt.Sprintf("%064x", 1))):                                         notify,
+		string(oldLevelDBKey(LEVELDB_PREFIX_SYNC_STATE, TEST_CONTRACT)):                                                    uint32Key(1),
+		string(oldLevelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_MAX_NOTIFY_HEIGHT)):                                           uint32Key(1),
+		string(oldLevelDBKey(LEVELDB_PREFIX_CONTRACT, TEST_CONTRACT)):                                                      `{"Contract":"` + TEST_CONTRACT + `"}`,
+	}
+	for key, value := range entries {
+		err = store.db.Put([]byte(key), []byte(value), nil)
+		if err != nil {
+			t.Fatalf("db.Put error:%s", err)
+		}
+	}
+	store.Close()
+
+	store = NewLevelDBHelper(path)
+	err = store.Open()
+	if err != nil {
+		t.Fatalf("Open error:%s", err)
+	}
+	defer store.Close()
+	migrations, err := store.Migrate()
+	if err != nil || len(migrations) != 1 {
+		t.Errorf("Migrate:%v error:%v", migrations, err)
+	}
+	for key := range entries {
+		ok, err := store.db.Has([]byte(key), nil)
+		if err != nil || ok {
+			t.Errorf("old key:%x is kept", key)
+		}
+	}
+	holders, err := store.GetBalances([]string{TEST_ADDRESS_A}, nil)
+	if err != nil || len(holders) != 1 || holders[0].Balance.Int64() != 100 {
+		t.Errorf("GetBalances:%v error:%v", holders, err)
+	}
+	history, err := store.GetTransferHistory(0, 10, TEST_ADDRESS_A, "", 0, 1)
+	if err != nil || len(history) != 1 {
+		t.Errorf("GetTransferHistory:%v error:%v", history, err)
+	}
+	exists, err := store.IsEventNotifyExist([]string{fmt.Sprintf("%064x", 1)})
+	if err != nil || len(exists) != 1 {
+		t.Errorf("IsEventNotifyExist:%v error:%v", exists, err)
+	}
+	height, err := store.GetSyncedEventNotifyBlockHeight()
+	if err != nil || height != 1 {
+		t.Errorf("GetSyncedEventNotifyBlockHeight:%d error:%v", height, err)
+	}
+	states, err := store.GetSyncStates()
+	if err != nil || len(states) != 1 || states[0].Contract != TEST_CONTRACT || states[0].Height != 1 {
+		t.Errorf("GetSyncStates:%v error:%v", states, err)
+	}
+	contracts, err := store.GetContracts()
+	if err != nil || len(contracts) != 1 || contracts[0].Contract != TEST_CONTRACT {
+		t.Errorf("GetContracts:%v error:%v", contracts, err)
+	}
+
+	//Keys are upgraded only once
+	store.Close()
+	store = NewLevelDBHelper(path)
+	err = store.Open()
+	if err != nil {
+		t.Fatalf("Open error:%s", err)
+	}
+	migrations, _ = store.Migrate()
+	if len(migrations) != 0 {
+		t.Errorf("Migrate again:%v", migrations)
+	}
+}
+
+func TestLevelDBHelperUpgradeApprovals(t *testing.T) {
+	path := t.TempDir()
+	store := NewLevelDBHelper(path)
+	err := store.Open()
+	if err != nil {
+		t.Fatalf("Open error:%s", err)
+	}
+	//Write an allowance as version 1, which doesn't keep approvals
+	allowance := &Allowance{Contract: TEST_CONTRACT, Owner: TEST_ADDRESS_A, Spender: TEST_ADDRESS_B, Amount: big.NewInt(100), TxHash: fmt.Sprintf("%064x", 2), Height: 2}
+	batch := new(leveldb.Batch)
+	err = putJson(batch, levelDBKey(LEVELDB_PREFIX_ALLOWANCE, allowance.Owner, allowance.Contract, allowance.Spender), allowance)
+	if err != nil {
+		t.Fatalf("putJson error:%s", err)
+	}
+	putUint32(batch, levelDBKey(LEVELDB_PREFIX_SYS, LEVELDB_SYS_KEY_VERSION), 1)
+	err = store.db.Write(batch, nil)
+	if err != nil {
+		t.Fatalf("db.Write error:%s", err)
+	}
+	store.Close()
+
+	store = NewLevelDBHelper(path)
+	err = store.Open()
+	if err != nil {
+		t.Fatalf("Open error:%s", err)
+	}
+	defer store.Close()
+	migrations, err := store.Migrate()
+	if err != nil || len(migrations) != 1 {
+		t.Errorf("Migrate:%v error:%v", migrations, err)
+	}
+	for _, test := range []struct {
+		height     uint32
+		isApproved bool
+	}{{1, false}, {2, true}, {3, true}} {
+		approval, err := store.getLastApproval(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B, test.height)
+		if err != nil || (approval != nil) != test.isApproved || (approval != nil && approval.Amount.Int64() != 100) {
+			t.Errorf("approval at height %d:%+v error:%v", test.height, approval, err)
+		}
+	}
+}
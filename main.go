@@ -19,8 +19,9 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	log4 "github.com/alecthomas/log4go"
-	ontsdk "github.com/ontio/ontology-go-sdk"
 	"os"
 	"os/signal"
 	"runtime"
@@ -38,58 +39,83 @@ var (
 func main() {
 	defer time.Sleep(time.Millisecond * 10)
 	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	flag.StringVar(&CfgPath, "config", CfgPath, "config file")
+	flag.StringVar(&LogPath, "log", LogPath, "log4go config file")
+	flag.StringVar(&DBInstallFile, "db_install", DBInstallFile, "sql file to create tables")
+	flag.StringVar(&NodeIdFile, "node_id", NodeIdFile, "file to save node id")
+	flag.Usage = printUsage
+	flag.Parse()
 	log4.LoadConfiguration(LogPath)
 
+	name := DEFAULT_COMMAND
+	args := flag.Args()
+	if len(args) > 0 {
+		name = args[0]
+		args = args[1:]
+	}
+	command := getCommand(name)
+	if command == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command:%s\n\n", name)
+		printUsage()
+		exit(1)
+	}
+	err := command.Run(args)
+	if err != nil {
+		log4.Error("%s error:%s", command.Name, err)
+		fmt.Fprintf(os.Stderr, "%s error:%s\n", command.Name, err)
+		exit(1)
+	}
+}
+
+//exit flushes logs and exits with code, since os.Exit doesn't run deferred calls
+func exit(code int) {
+	log4.Close()
+	os.Exit(code)
+}
+
+//runNode starts the holder node, and waits for exit signal.
+func runNode(args []string) error {
 	err := GetJsonObject(CfgPath, DefConfig)
 	if err != nil {
-		log4.Error("Init config error:%s", err)
-		return
+		return fmt.Errorf("init config error:%s", err)
+	}
+	log4.Info("Config:%+v", redactConfig(DefConfig))
+	err = CheckContractDecoders()
+	if err != nil {
+		return fmt.Errorf("CheckContractDecoders error:%s", err)
 	}
-	log4.Info("Config:%+v", DefConfig)
 	_, err = InitNodeId(NodeIdFile)
 	if err != nil {
-		log4.Error("InitNodeId error:%s", err)
-		return
+		return fmt.Errorf("InitNodeId error:%s", err)
 	}
 	log4.Info("Ontology-holder NodeId:%d", NodeId)
 
-	mySqlHelper := NewMySqlHelper(
-		DefConfig.MySqlAddress,
-		DefConfig.MySqlUserName,
-		DefConfig.MySqlPassword,
-		DefConfig.MySqlDBName,
-		DefConfig.MySqlMaxIdleConnSize,
-		DefConfig.MySqlMaxOpenConnSize,
-		DefConfig.MySqlConnMaxLifetime)
-	err = mySqlHelper.Open()
+	store, err := NewHolderStore(DefConfig, DBInstallFile)
 	if err != nil {
-		log4.Error("Open mysql error:%s", err)
-		return
+		return fmt.Errorf("NewHolderStore error:%s", err)
 	}
+	defer store.Close()
+	log4.Info("Store:%s init success", DefConfig.GetStoreType())
 
-	err = mySqlHelper.InitDB(DBInstallFile)
+	rpcMgr, err := NewRpcManager(DefConfig)
 	if err != nil {
-		log4.Error("InitDB error:%s", err)
-		return
+		return fmt.Errorf("NewRpcManager error:%s", err)
 	}
-	defer mySqlHelper.Close()
-	log4.Info("MySql init success")
-
-	ontSdk := ontsdk.NewOntologySdk()
-	rpcClient := ontSdk.NewRpcClient().SetAddress(DefConfig.OntologyRpcAddress)
-	ontSdk.SetDefaultClient(rpcClient)
+	rpcMgr.Start()
+	defer rpcMgr.Close()
 
-	DefOntologyMgr = NewOntologyManager(ontSdk, mySqlHelper)
+	DefOntologyMgr = NewOntologyManager(rpcMgr, store)
 	err = DefOntologyMgr.Start()
 	if err != nil {
-		log4.Error("DefOntologyMgr Start error:%s", err)
-		return
+		return fmt.Errorf("DefOntologyMgr Start error:%s", err)
 	}
 	defer DefOntologyMgr.Close()
 
 	DefHttpSvr.Start(uint(DefConfig.HttpServerPort))
 
 	waitToExit()
+	return nil
 }
 
 func waitToExit() {
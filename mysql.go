@@ -38,6 +38,7 @@ type MySqlHelper struct {
 	MySqlMaxOpenConnSize uint32
 	MySqlConnMaxLifetime uint32
 	db                   *sql.DB
+	migrations           []string
 }
 
 func NewMySqlHelper(address, username, passwd, dbName string, maxIdleConnSize, maxOpenConnSize, connMaxLiftTime uint32) *MySqlHelper {
@@ -73,34 +74,181 @@ func (this *MySqlHelper) Close() error {
 	return this.db.Close()
 }
 
+//InitDB executes the install file on every start. Tables are created with "If Not Exists",
+//so tables added by newer versions are created on existing databases as well.
+//Tables created by an older version are upgraded by mySqlMigrations then, which "If Not Exists" skips.
 func (this *MySqlHelper) InitDB(installFile string) error {
-	isTableCreate, err := this.isTableCreate()
+	err := this.createTable(installFile)
 	if err != nil {
 		return err
 	}
-	if !isTableCreate {
-		err = this.createTable(installFile)
+	migrations, err := this.migrate()
+	if err != nil {
+		return fmt.Errorf("migrate error:%s", err)
+	}
+	for _, migration := range migrations {
+		log4.Info("Migrate mysql:%s", migration)
+	}
+	this.migrations = migrations
+	return nil
+}
+
+//MySqlMigration upgrades Column or Index of Table created by an older version.
+//It's applied if the column doesn't exist, or ColumnType is set and the column type is different.
+//If Index is set, it's applied if the index doesn't exist.
+//If CopyFrom is set, it fills Table added by a newer version from table CopyFrom, it's applied if Table is empty and CopyFrom isn't.
+type MySqlMigration struct {
+	Table      string
+	Column     string
+	ColumnType string
+	Index      string
+	CopyFrom   string
+	SqlText    string
+}
+
+var mySqlMigrations = []*MySqlMigration{
+	{
+		Table:      "holder",
+		Column:     "balance",
+		ColumnType: "decimal(65,0)",
+		SqlText:    "ALTER TABLE holder MODIFY `balance` decimal(65,0) NOT NULL;",
+	},
+	{
+		Table:   "transfers",
+		Column:  "token_id",
+		SqlText: "ALTER TABLE transfers ADD `token_id` varchar(128) NOT NULL DEFAULT '' AFTER `contract`;",
+	},
+	{
+		Table:   "holder",
+		Column:  "token_id",
+		SqlText: "ALTER TABLE holder ADD `token_id` varchar(128) NOT NULL DEFAULT '' AFTER `contract`, DROP PRIMARY KEY, ADD PRIMARY KEY (`address`,`contract`,`token_id`);",
+	},
+	{
+		Table:   "holder",
+		Index:   "contract_balance",
+		SqlText: "ALTER TABLE holder ADD KEY `contract_balance` (`contract`,`token_id`,`balance`,`address`);",
+	},
+	{
+		Table:   "transfers",
+		Column:  "is_invalid",
+		SqlText: "ALTER TABLE transfers ADD `is_invalid` tinyint(1) NOT NULL DEFAULT 0 AFTER `timestamp`;",
+	},
+	{
+		Table:    "approval",
+		CopyFrom: "allowance",
+		SqlText:  "INSERT INTO approval(owner, contract, spender, height, amount, tx_hash) SELECT owner, contract, spender, height, amount, tx_hash FROM allowance;",
+	},
+}
+
+//Migrate of mysql returns the migrations applied by InitDB, since tables are upgraded on every start
+func (this *MySqlHelper) Migrate() ([]string, error) {
+	return this.migrations, nil
+}
+
+func (this *MySqlHelper) migrate() ([]string, error) {
+	applied := make([]string, 0)
+	for _, migration := range mySqlMigrations {
+		if migration.CopyFrom != "" {
+			isEmpty, err := this.isTableEmpty(migration.Table)
+			if err != nil {
+				return applied, err
+			}
+			isSourceEmpty, err := this.isTableEmpty(migration.CopyFrom)
+			if err != nil {
+				return applied, err
+			}
+			if !isEmpty || isSourceEmpty {
+				continue
+			}
+		} else if migration.Index != "" {
+			ok, err := this.isIndexExist(migration.Table, migration.Index)
+			if err != nil {
+				return applied, err
+			}
+			if ok {
+				continue
+			}
+		} else {
+			columnType, ok, err := this.getColumnType(migration.Table, migration.Column)
+			if err != nil {
+				return applied, err
+			}
+			if ok && (migration.ColumnType == "" || columnType == migration.ColumnType) {
+				continue
+			}
+		}
+		_, err := this.db.Exec(migration.SqlText)
 		if err != nil {
-			return err
+			return applied, fmt.Errorf("db.Exec:%s error:%s", migration.SqlText, err)
 		}
+		applied = append(applied, migration.SqlText)
 	}
-	return nil
+	return applied, nil
 }
 
-func (this *MySqlHelper) isTableCreate() (bool, error) {
-	sqlText := "SELECT count(*) FROM information_schema.TABLES WHERE table_name ='holder' And table_schema ='" + this.MySqlDBName + "';"
-	rows, err := this.db.Query(sqlText)
+func (this *MySqlHelper) isTableEmpty(table string) (bool, error) {
+	rows, err := this.db.Query("Select 1 From " + table + " Limit 1;")
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("db.Query error:%s", err)
 	}
 	defer rows.Close()
-	count := 0
-	rows.Next()
-	err = rows.Scan(&count)
+	return !rows.Next(), nil
+}
+
+func (this *MySqlHelper) isIndexExist(table, index string) (bool, error) {
+	sqlText := "Select index_name From information_schema.statistics Where table_schema = Database() And table_name = ? And index_name = ?;"
+	rows, err := this.db.Query(sqlText, table, index)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("db.Query error:%s", err)
 	}
-	return count != 0, nil
+	defer rows.Close()
+	return rows.Next(), nil
+}
+
+func (this *MySqlHelper) getColumnType(table, column string) (string, bool, error) {
+	sqlText := "Select column_type From information_schema.columns Where table_schema = Database() And table_name = ? And column_name = ?;"
+	rows, err := this.db.Query(sqlText, table, column)
+	if err != nil {
+		return "", false, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", false, nil
+	}
+	columnType := ""
+	err = rows.Scan(&columnType)
+	if err != nil {
+		return "", false, fmt.Errorf("row.Scan error:%s", err)
+	}
+	return columnType, true, nil
+}
+
+func (this *MySqlHelper) ResetContract(contract string) error {
+	dbTx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction error:%s", err)
+	}
+	rollBack := true
+	defer func() {
+		if rollBack {
+			e := dbTx.Rollback()
+			if e != nil {
+				log4.Error("ResetContract dbTx Rollback error %s", err)
+			}
+		}
+	}()
+	for _, table := range []string{"holder", "transfers", "token_owner", "allowance", "approval", "balance_mismatch", "holder_snapshot", "snapshot_holder", "sync_state"} {
+		_, err = dbTx.Exec("Delete From "+table+" Where contract = ?;", contract)
+		if err != nil {
+			return fmt.Errorf("delete %s dbTx.Exec error:%s", table, err)
+		}
+	}
+	err = dbTx.Commit()
+	if err != nil {
+		return fmt.Errorf("ResetContract dbTx.Commit error:%s", err)
+	}
+	rollBack = false
+	return nil
 }
 
 func (this *MySqlHelper) createTable(installFile string) error {
@@ -147,38 +295,135 @@ func (this *MySqlHelper) createTable(installFile string) error {
 	return nil
 }
 
-func (this *MySqlHelper) OnTxEventNotify(evtNotify []*TxEventNotify, assetHolder []*AssetHolder) error {
+//OnTxEventNotify saves event notifies, holders, transfers, token owners, allowances and sync checkpoints in one db transaction.
+func (this *MySqlHelper) OnTxEventNotify(evtNotify []*TxEventNotify, assetHolder []*AssetHolder, transfers []*TxTransfer, tokenOwners []*TokenOwner, allowances []*Allowance, syncStates []*SyncState) error {
 	notifyCount := len(evtNotify)
-	if notifyCount == 0 {
+	holderCount := len(assetHolder)
+	transferCount := len(transfers)
+	tokenOwnerCount := len(tokenOwners)
+	allowanceCount := len(allowances)
+	syncStateCount := len(syncStates)
+	if notifyCount == 0 && holderCount == 0 && transferCount == 0 && tokenOwnerCount == 0 && allowanceCount == 0 && syncStateCount == 0 {
 		return nil
 	}
-	notifySqlBuf := bytes.NewBuffer(nil)
-	notifySqlBuf.WriteString("Insert Into eventnotify(tx_hash, height, state, gas_consumed, notify) Values ")
-	for i, notify := range evtNotify {
-		notifySqlBuf.WriteString(fmt.Sprintf("('%s', %d, %d, %d ,'%s')", notify.TxHash, notify.Height, notify.State, notify.GasConsumed, notify.Notify))
-		if i == notifyCount-1 {
-			notifySqlBuf.WriteString(";")
-		} else {
-			notifySqlBuf.WriteString(",")
+	dbTx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction error:%s", err)
+	}
+	rollBack := true
+	defer func() {
+		if rollBack {
+			e := dbTx.Rollback()
+			if e != nil {
+				log4.Error("OnTxEventNotify dbTx Rollback error %s", err)
+			}
+		}
+	}()
+
+	if notifyCount > 0 {
+		notifyArgs := make([]interface{}, 0, notifyCount*5)
+		for _, notify := range evtNotify {
+			notifyArgs = append(notifyArgs, notify.TxHash, notify.Height, notify.State, notify.GasConsumed, notify.Notify)
+		}
+		notifySqlText := "Insert Into eventnotify(tx_hash, height, state, gas_consumed, notify) Values " + sqlPlaceholders(notifyCount, 5) + ";"
+		results, err := dbTx.Exec(notifySqlText, notifyArgs...)
+		if err != nil {
+			return fmt.Errorf("insert notify dbTx.Exec error:%s", err)
+		}
+		affected, err := results.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("insert notify dbTx.Exec RowsAffected error:%s", err)
+		}
+		if int(affected) != notifyCount {
+			return fmt.Errorf("insert notify dbTx.Exec RowsAffected %d != %d", affected, notifyCount)
 		}
 	}
-	notifySqlText := notifySqlBuf.String()
 
-	holderCount := len(assetHolder)
-	if holderCount == 0 {
-		return nil
+	if holderCount > 0 {
+		holderArgs := make([]interface{}, 0, holderCount*5)
+		for _, holder := range assetHolder {
+			holderArgs = append(holderArgs, holder.Address, holder.Contract, holder.TokenId, holder.Balance.String(), holder.Transactions)
+		}
+		holderSqlText := "Insert Into holder(address, contract, token_id, balance, transactions) Values " + sqlPlaceholders(holderCount, 5) +
+			" On Duplicate key Update balance=Values(balance), transactions=Values(transactions);"
+		_, err = dbTx.Exec(holderSqlText, holderArgs...)
+		if err != nil {
+			return fmt.Errorf("insert holder dbTx.Exec error:%s", err)
+		}
 	}
-	holderSqlBuf := bytes.NewBuffer(nil)
-	holderSqlBuf.WriteString("Insert Into holder(address, contract, balance, transactions) Values ")
-	for i, holder := range assetHolder {
-		holderSqlBuf.WriteString(fmt.Sprintf("('%s', '%s', %d, %d)", holder.Address, holder.Contract, holder.Balance, holder.Transactions))
-		if i == holderCount-1 {
-			holderSqlBuf.WriteString(" On Duplicate key Update balance=Values(balance);")
-		} else {
-			holderSqlBuf.WriteString(",")
+
+	if transferCount > 0 {
+		transferArgs := make([]interface{}, 0, transferCount*11)
+		for _, transfer := range transfers {
+			transferArgs = append(transferArgs, transfer.TxHash, transfer.Index, transfer.Height, transfer.Name, transfer.Contract,
+				transfer.TokenId, transfer.From, transfer.To, transfer.Amount.String(), transfer.Timestamp, transfer.IsInvalid)
+		}
+		transferSqlText := "Insert Into transfers(tx_hash, notify_index, height, name, contract, token_id, from_address, to_address, amount, timestamp, is_invalid) Values " +
+			sqlPlaceholders(transferCount, 11) + ";"
+		_, err = dbTx.Exec(transferSqlText, transferArgs...)
+		if err != nil {
+			return fmt.Errorf("insert transfers dbTx.Exec error:%s", err)
+		}
+	}
+
+	if tokenOwnerCount > 0 {
+		tokenOwnerArgs := make([]interface{}, 0, tokenOwnerCount*4)
+		for _, tokenOwner := range tokenOwners {
+			tokenOwnerArgs = append(tokenOwnerArgs, tokenOwner.Contract, tokenOwner.TokenId, tokenOwner.Owner, tokenOwner.Height)
+		}
+		tokenOwnerSqlText := "Insert Into token_owner(contract, token_id, owner, height) Values " + sqlPlaceholders(tokenOwnerCount, 4) +
+			" On Duplicate key Update owner=Values(owner), height=Values(height);"
+		_, err = dbTx.Exec(tokenOwnerSqlText, tokenOwnerArgs...)
+		if err != nil {
+			return fmt.Errorf("insert token_owner dbTx.Exec error:%s", err)
+		}
+	}
+
+	if allowanceCount > 0 {
+		allowanceArgs := make([]interface{}, 0, allowanceCount*6)
+		for _, allowance := range allowances {
+			allowanceArgs = append(allowanceArgs, allowance.Owner, allowance.Contract, allowance.Spender, allowance.Amount.String(), allowance.TxHash, allowance.Height)
+		}
+		allowanceSqlText := "Insert Into allowance(owner, contract, spender, amount, tx_hash, height) Values " + sqlPlaceholders(allowanceCount, 6) +
+			" On Duplicate key Update amount=Values(amount), tx_hash=Values(tx_hash), height=Values(height);"
+		_, err = dbTx.Exec(allowanceSqlText, allowanceArgs...)
+		if err != nil {
+			return fmt.Errorf("insert allowance dbTx.Exec error:%s", err)
+		}
+		approvalSqlText := "Insert Into approval(owner, contract, spender, height, amount, tx_hash) Values " + sqlPlaceholders(allowanceCount, 6) +
+			" On Duplicate key Update amount=Values(amount), tx_hash=Values(tx_hash);"
+		approvalArgs := make([]interface{}, 0, allowanceCount*6)
+		for _, allowance := range allowances {
+			approvalArgs = append(approvalArgs, allowance.Owner, allowance.Contract, allowance.Spender, allowance.Height, allowance.Amount.String(), allowance.TxHash)
+		}
+		_, err = dbTx.Exec(approvalSqlText, approvalArgs...)
+		if err != nil {
+			return fmt.Errorf("insert approval dbTx.Exec error:%s", err)
+		}
+	}
+
+	if syncStateCount > 0 {
+		syncStateArgs := make([]interface{}, 0, syncStateCount*2)
+		for _, syncState := range syncStates {
+			syncStateArgs = append(syncStateArgs, syncState.Contract, syncState.Height)
+		}
+		syncStateSqlText := "Insert Into sync_state(contract, height) Values " + sqlPlaceholders(syncStateCount, 2) +
+			" On Duplicate key Update height=Values(height);"
+		_, err = dbTx.Exec(syncStateSqlText, syncStateArgs...)
+		if err != nil {
+			return fmt.Errorf("insert sync_state dbTx.Exec error:%s", err)
 		}
 	}
-	holderSqlText := holderSqlBuf.String()
+
+	err = dbTx.Commit()
+	if err != nil {
+		return fmt.Errorf("OnTxEventNotify dbTx.Commit error:%s", err)
+	}
+	rollBack = false
+	return nil
+}
+
+func (this *MySqlHelper) Rollback(height uint32, assetHolders []*AssetHolder, tokenOwners []*TokenOwner) error {
 	dbTx, err := this.db.Begin()
 	if err != nil {
 		return fmt.Errorf("begin transaction error:%s", err)
@@ -188,85 +433,582 @@ func (this *MySqlHelper) OnTxEventNotify(evtNotify []*TxEventNotify, assetHolder
 		if rollBack {
 			e := dbTx.Rollback()
 			if e != nil {
-				log4.Error("OnTxEventNotify dbTx Rollback error %s", err)
+				log4.Error("Rollback dbTx Rollback error %s", err)
 			}
 		}
 	}()
 
-	results, err := dbTx.Exec(notifySqlText)
-	if err != nil {
-		return fmt.Errorf("insert notify dbTx.Exec error:%s", err)
+	for _, holder := range assetHolders {
+		if holder.Balance.Sign() == 0 {
+			_, err = dbTx.Exec("Delete From holder Where address = ? And contract = ? And token_id = ?;", holder.Address, holder.Contract, holder.TokenId)
+		} else {
+			_, err = dbTx.Exec("Update holder Set balance = ?, transactions = ? Where address = ? And contract = ? And token_id = ?;",
+				holder.Balance.String(), holder.Transactions, holder.Address, holder.Contract, holder.TokenId)
+		}
+		if err != nil {
+			return fmt.Errorf("update holder dbTx.Exec error:%s", err)
+		}
 	}
-	affected, err := results.RowsAffected()
+	for _, tokenOwner := range tokenOwners {
+		_, err = dbTx.Exec("Update token_owner Set owner = ?, height = ? Where contract = ? And token_id = ?;",
+			tokenOwner.Owner, tokenOwner.Height, tokenOwner.Contract, tokenOwner.TokenId)
+		if err != nil {
+			return fmt.Errorf("update token_owner dbTx.Exec error:%s", err)
+		}
+	}
+	err = rollbackAllowances(dbTx, height)
 	if err != nil {
-		return fmt.Errorf("insert notify dbTx.Exec RowsAffected error:%s", err)
+		return err
 	}
-	if int(affected) != notifyCount {
-		fmt.Printf("Insert notify dbTx.Exec  RowsAffected %d != %d\n", affected, notifyCount)
-		return nil
+	for _, table := range []string{"transfers", "eventnotify", "approval", "holder_snapshot", "snapshot_holder"} {
+		_, err = dbTx.Exec("Delete From "+table+" Where height > ?;", height)
+		if err != nil {
+			return fmt.Errorf("delete %s dbTx.Exec error:%s", table, err)
+		}
 	}
-	_, err = dbTx.Exec(holderSqlText)
+	_, err = dbTx.Exec("Update sync_state Set height = ? Where height > ?;", height, height)
 	if err != nil {
-		return fmt.Errorf("insert holder dbTx.Exec error:%s", err)
+		return fmt.Errorf("update sync_state dbTx.Exec error:%s", err)
 	}
 
 	err = dbTx.Commit()
 	if err != nil {
-		return fmt.Errorf("OnTxEventNotify dbTx.Commit error:%s", err)
+		return fmt.Errorf("Rollback dbTx.Commit error:%s", err)
 	}
 	rollBack = false
 	return nil
 }
 
-func (this *MySqlHelper) GetAssetHolder(from, count int, address, contract string, isDescOrder ...bool) ([]*AssetHolder, error) {
-	order := "DESC"
-	if len(isDescOrder) > 0 && !isDescOrder[0] {
-		order = "ASC"
+//rollbackAllowances restores allowances approved above height to the last approval at or below height,
+//an allowance which isn't approved at or below height is deleted.
+func rollbackAllowances(dbTx *sql.Tx, height uint32) error {
+	allowances, err := getAllowancesAboveHeight(dbTx, height)
+	if err != nil {
+		return err
 	}
-	buf := bytes.NewBuffer(nil)
+	for _, allowance := range allowances {
+		approval, err := getLastApproval(dbTx, allowance.Contract, allowance.Owner, allowance.Spender, height)
+		if err != nil {
+			return err
+		}
+		if approval == nil {
+			_, err = dbTx.Exec("Delete From allowance Where owner = ? And contract = ? And spender = ?;",
+				allowance.Owner, allowance.Contract, allowance.Spender)
+		} else {
+			_, err = dbTx.Exec("Update allowance Set amount = ?, tx_hash = ?, height = ? Where owner = ? And contract = ? And spender = ?;",
+				approval.Amount.String(), approval.TxHash, approval.Height, allowance.Owner, allowance.Contract, allowance.Spender)
+		}
+		if err != nil {
+			return fmt.Errorf("update allowance dbTx.Exec error:%s", err)
+		}
+	}
+	return nil
+}
+
+func getAllowancesAboveHeight(dbTx *sql.Tx, height uint32) ([]*Allowance, error) {
+	rows, err := dbTx.Query("Select owner, contract, spender From allowance Where height > ?;", height)
+	if err != nil {
+		return nil, fmt.Errorf("select allowance dbTx.Query error:%s", err)
+	}
+	defer rows.Close()
+	allowances := make([]*Allowance, 0)
+	for rows.Next() {
+		allowance := &Allowance{}
+		err = rows.Scan(&allowance.Owner, &allowance.Contract, &allowance.Spender)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		allowances = append(allowances, allowance)
+	}
+	return allowances, nil
+}
+
+//getLastApproval returns the last approval of owner to spender at or below height, nil if there is none
+func getLastApproval(dbTx *sql.Tx, contract, owner, spender string, height uint32) (*Allowance, error) {
+	sqlText := "Select amount, tx_hash, height From approval Where owner = ? And contract = ? And spender = ? And height <= ? Order By height Desc Limit 1;"
+	rows, err := dbTx.Query(sqlText, owner, contract, spender, height)
+	if err != nil {
+		return nil, fmt.Errorf("select approval dbTx.Query error:%s", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+	approval := &Allowance{
+		Contract: contract,
+		Owner:    owner,
+		Spender:  spender,
+	}
+	amount := ""
+	err = rows.Scan(&amount, &approval.TxHash, &approval.Height)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan error:%s", err)
+	}
+	approval.Amount, err = ParseBigInt(amount)
+	if err != nil {
+		return nil, err
+	}
+	return approval, nil
+}
+
+func (this *MySqlHelper) GetAssetHolder(from, count int, cursor *HolderCursor, address, contract, tokenId string, isDescOrder ...bool) ([]*AssetHolder, error) {
+	isDesc := len(isDescOrder) == 0 || isDescOrder[0]
+	sqlText, args := getAssetHolderSql(from, count, cursor, address, contract, tokenId, isDesc)
+	log4.Debug("GetAssetHolder SqlText:%s Args:%v", sqlText, args)
+
+	rows, err := this.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+
+	holders := make([]*AssetHolder, 0, count)
+	for rows.Next() {
+		holder := &AssetHolder{}
+		balance := ""
+		err = rows.Scan(&holder.Address, &holder.Contract, &holder.TokenId, &balance, &holder.Transactions)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		holder.Balance, err = ParseBigInt(balance)
+		if err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, nil
+}
+
+//getAssetHolderSql returns the query of GetAssetHolder. Holders are ordered by balance and address in the same direction,
+//so that the query is served by a forward or backward scan of the contract_balance index without filesort.
+func getAssetHolderSql(from, count int, cursor *HolderCursor, address, contract, tokenId string, isDesc bool) (string, []interface{}) {
+	conditions := make([]string, 0, 4)
+	args := make([]interface{}, 0, 9)
 	if contract != "" {
-		buf.WriteString("Select address, balance, transactions From holder Where contract = '" + contract + "' ")
-	} else {
-		buf.WriteString("Select address, contract, balance, transactions From holder Where ")
+		conditions = append(conditions, "contract = ?")
+		args = append(args, contract)
+		if address == "" || tokenId != "" {
+			conditions = append(conditions, "token_id = ?")
+			args = append(args, tokenId)
+		}
 	}
 	if address != "" {
-		if contract != "" {
-			buf.WriteString("And ")
-		}
-		buf.WriteString("address = '" + address + "' ")
+		conditions = append(conditions, "address = ?")
+		args = append(args, address)
+	}
+	order := "ASC"
+	compare := ">"
+	if isDesc {
+		order = "DESC"
+		compare = "<"
+	}
+	if cursor != nil {
+		//Balance is compared as decimal, string args are compared as double by MySql
+		conditions = append(conditions, "balance "+compare+"= Cast(? As Decimal(65,0))",
+			"(balance "+compare+" Cast(? As Decimal(65,0)) Or address "+compare+" ?)")
+		args = append(args, cursor.Balance.String(), cursor.Balance.String(), cursor.Address)
+		from = 0
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("Select address, contract, token_id, balance, transactions From holder ")
+	if len(conditions) > 0 {
+		buf.WriteString("Where " + strings.Join(conditions, " And ") + " ")
 	}
-	buf.WriteString("Order By balance " + order)
+	buf.WriteString("Order By balance " + order + ", address " + order)
 	if count == 0 {
 		buf.WriteString(";")
 	} else {
-		buf.WriteString(fmt.Sprintf(" Limit %d, %d;", from, count))
+		buf.WriteString(" Limit ?, ?;")
+		args = append(args, from, count)
+	}
+	return buf.String(), args
+}
+
+func (this *MySqlHelper) GetTransferHistory(from, count int, address, contract string, startHeight, endHeight uint32) ([]*TxTransfer, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("Select tx_hash, notify_index, height, name, contract, token_id, from_address, to_address, amount, timestamp, is_invalid " +
+		"From transfers Where height >= ? And height <= ? ")
+	args := make([]interface{}, 0, 7)
+	args = append(args, startHeight, endHeight)
+	if address != "" {
+		buf.WriteString("And (from_address = ? Or to_address = ?) ")
+		args = append(args, address, address)
 	}
+	if contract != "" {
+		buf.WriteString("And contract = ? ")
+		args = append(args, contract)
+	}
+	buf.WriteString("Order By height DESC, tx_hash ASC, notify_index ASC Limit ?, ?;")
+	args = append(args, from, count)
 	sqlText := buf.String()
-	log4.Debug("GetAssetHolder SqlText:%s", sqlText)
+	log4.Debug("GetTransferHistory SqlText:%s Args:%v", sqlText, args)
 
-	rows, err := this.db.Query(sqlText)
+	rows, err := this.db.Query(sqlText, args...)
 	if err != nil {
 		return nil, fmt.Errorf("db.Query error:%s", err)
 	}
 	defer rows.Close()
 
-	holders := make([]*AssetHolder, 0, count)
+	transfers := make([]*TxTransfer, 0, count)
 	for rows.Next() {
-		holder := &AssetHolder{}
-		if contract != "" {
-			err = rows.Scan(&holder.Address, &holder.Balance, &holder.Transactions)
-			holder.Contract = contract
-		} else {
-			err = rows.Scan(&holder.Address, &holder.Contract, &holder.Balance, &holder.Transactions)
+		transfer := &TxTransfer{}
+		amount := ""
+		err = rows.Scan(&transfer.TxHash, &transfer.Index, &transfer.Height, &transfer.Name, &transfer.Contract,
+			&transfer.TokenId, &transfer.From, &transfer.To, &amount, &transfer.Timestamp, &transfer.IsInvalid)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		transfer.Amount, err = ParseBigInt(amount)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, nil
+}
+
+func (this *MySqlHelper) GetTokenOwner(contract, tokenId string) (*TokenOwner, error) {
+	sqlText := "Select owner, height From token_owner Where contract = ? And token_id = ?;"
+	rows, err := this.db.Query(sqlText, contract, tokenId)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+	tokenOwner := &TokenOwner{
+		Contract: contract,
+		TokenId:  tokenId,
+	}
+	err = rows.Scan(&tokenOwner.Owner, &tokenOwner.Height)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan error:%s", err)
+	}
+	return tokenOwner, nil
+}
+
+func (this *MySqlHelper) GetLastTokenTransfer(contract, tokenId string, height uint32) (*TxTransfer, error) {
+	sqlText := "Select tx_hash, notify_index, height, name, from_address, to_address, amount, timestamp From transfers " +
+		"Where contract = ? And token_id = ? And height <= ? Order By height DESC, notify_index DESC Limit 1;"
+	rows, err := this.db.Query(sqlText, contract, tokenId, height)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+	transfer := &TxTransfer{
+		Contract: contract,
+		TokenId:  tokenId,
+	}
+	amount := ""
+	err = rows.Scan(&transfer.TxHash, &transfer.Index, &transfer.Height, &transfer.Name, &transfer.From, &transfer.To, &amount, &transfer.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan error:%s", err)
+	}
+	transfer.Amount, err = ParseBigInt(amount)
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func (this *MySqlHelper) GetTokensOfOwner(from, count int, contract, owner string) ([]*TokenOwner, error) {
+	sqlText := "Select token_id, height From token_owner Where contract = ? And owner = ? Order By token_id ASC Limit ?, ?;"
+	rows, err := this.db.Query(sqlText, contract, owner, from, count)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	tokenOwners := make([]*TokenOwner, 0, count)
+	for rows.Next() {
+		tokenOwner := &TokenOwner{
+			Contract: contract,
+			Owner:    owner,
 		}
+		err = rows.Scan(&tokenOwner.TokenId, &tokenOwner.Height)
 		if err != nil {
 			return nil, fmt.Errorf("row.Scan error:%s", err)
 		}
+		tokenOwners = append(tokenOwners, tokenOwner)
+	}
+	return tokenOwners, nil
+}
+
+func (this *MySqlHelper) GetAllowance(contract, owner, spender string) (*Allowance, error) {
+	sqlText := "Select amount, tx_hash, height From allowance Where owner = ? And contract = ? And spender = ?;"
+	rows, err := this.db.Query(sqlText, owner, contract, spender)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, nil
+	}
+	allowance := &Allowance{
+		Contract: contract,
+		Owner:    owner,
+		Spender:  spender,
+	}
+	var amount string
+	err = rows.Scan(&amount, &allowance.TxHash, &allowance.Height)
+	if err != nil {
+		return nil, fmt.Errorf("row.Scan error:%s", err)
+	}
+	allowance.Amount, err = ParseBigInt(amount)
+	if err != nil {
+		return nil, err
+	}
+	return allowance, nil
+}
+
+func (this *MySqlHelper) GetAllowancesByOwner(from, count int, owner, contract string) ([]*Allowance, error) {
+	sqlText := "Select contract, spender, amount, tx_hash, height From allowance Where owner = ? "
+	args := []interface{}{owner}
+	if contract != "" {
+		sqlText += "And contract = ? "
+		args = append(args, contract)
+	}
+	sqlText += "Order By contract ASC, spender ASC Limit ?, ?;"
+	args = append(args, from, count)
+	rows, err := this.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	allowances := make([]*Allowance, 0, count)
+	for rows.Next() {
+		allowance := &Allowance{
+			Owner: owner,
+		}
+		var amount string
+		err = rows.Scan(&allowance.Contract, &allowance.Spender, &amount, &allowance.TxHash, &allowance.Height)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		allowance.Amount, err = ParseBigInt(amount)
+		if err != nil {
+			return nil, err
+		}
+		allowances = append(allowances, allowance)
+	}
+	return allowances, nil
+}
+
+//GetAssetHolderAtHeight pages holders of the snapshot saved by SaveHolderSnapshot.
+func (this *MySqlHelper) GetAssetHolderAtHeight(from, count int, cursor *HolderCursor, contract, tokenId string, height uint32) ([]*AssetHolder, error) {
+	sqlText, args := getAssetHolderAtHeightSql(from, count, cursor, contract, tokenId, height)
+	log4.Debug("GetAssetHolderAtHeight SqlText:%s Args:%v", sqlText, args)
+
+	rows, err := this.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+
+	holders := make([]*AssetHolder, 0, count)
+	for rows.Next() {
+		holder := &AssetHolder{Contract: contract, TokenId: tokenId}
+		balance := ""
+		err = rows.Scan(&holder.Address, &balance, &holder.Transactions)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		holder.Balance, err = ParseBigInt(balance)
+		if err != nil {
+			return nil, err
+		}
 		holders = append(holders, holder)
 	}
 	return holders, nil
 }
 
+//getAssetHolderAtHeightSql returns the query of GetAssetHolderAtHeight, served by the snapshot_balance index.
+func getAssetHolderAtHeightSql(from, count int, cursor *HolderCursor, contract, tokenId string, height uint32) (string, []interface{}) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("Select address, balance, transactions From snapshot_holder Where contract = ? And token_id = ? And height = ? ")
+	args := make([]interface{}, 0, 8)
+	args = append(args, contract, tokenId, height)
+	if cursor != nil {
+		buf.WriteString("And balance <= Cast(? As Decimal(65,0)) And (balance < Cast(? As Decimal(65,0)) Or address < ?) ")
+		args = append(args, cursor.Balance.String(), cursor.Balance.String(), cursor.Address)
+		from = 0
+	}
+	buf.WriteString("Order By balance DESC, address DESC")
+	if count == 0 {
+		buf.WriteString(";")
+	} else {
+		buf.WriteString(" Limit ?, ?;")
+		args = append(args, from, count)
+	}
+	return buf.String(), args
+}
+
+func (this *MySqlHelper) GetFirstTransferHeight(contract string) (uint32, bool, error) {
+	sqlText := "Select Min(height) From transfers Where contract = ?;"
+	rows, err := this.db.Query(sqlText, contract)
+	if err != nil {
+		return 0, false, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return 0, false, nil
+	}
+	height := sql.NullInt64{}
+	err = rows.Scan(&height)
+	if err != nil {
+		return 0, false, fmt.Errorf("row.Scan error:%s", err)
+	}
+	return uint32(height.Int64), height.Valid, nil
+}
+
+func (this *MySqlHelper) GetHolderSnapshots() ([]*HolderSnapshot, error) {
+	sqlText := "Select contract, token_id, height, holders, create_time From holder_snapshot Order By create_time ASC;"
+	rows, err := this.db.Query(sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	snapshots := make([]*HolderSnapshot, 0)
+	for rows.Next() {
+		snapshot := &HolderSnapshot{}
+		err = rows.Scan(&snapshot.Contract, &snapshot.TokenId, &snapshot.Height, &snapshot.Holders, &snapshot.CreateTime)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+func (this *MySqlHelper) SaveHolderSnapshot(snapshot *HolderSnapshot, holders []*AssetHolder) error {
+	dbTx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction error:%s", err)
+	}
+	rollBack := true
+	defer func() {
+		if rollBack {
+			e := dbTx.Rollback()
+			if e != nil {
+				log4.Error("SaveHolderSnapshot dbTx Rollback error %s", err)
+			}
+		}
+	}()
+
+	_, err = dbTx.Exec("Delete From snapshot_holder Where contract = ? And token_id = ? And height = ?;", snapshot.Contract, snapshot.TokenId, snapshot.Height)
+	if err != nil {
+		return fmt.Errorf("delete snapshot_holder dbTx.Exec error:%s", err)
+	}
+	for start := 0; start < len(holders); start += SNAPSHOT_PAGE_SIZE {
+		end := start + SNAPSHOT_PAGE_SIZE
+		if end > len(holders) {
+			end = len(holders)
+		}
+		holderArgs := make([]interface{}, 0, (end-start)*6)
+		for _, holder := range holders[start:end] {
+			holderArgs = append(holderArgs, snapshot.Contract, snapshot.TokenId, snapshot.Height, holder.Address, holder.Balance.String(), holder.Transactions)
+		}
+		holderSqlText := "Insert Into snapshot_holder(contract, token_id, height, address, balance, transactions) Values " + sqlPlaceholders(end-start, 6) + ";"
+		_, err = dbTx.Exec(holderSqlText, holderArgs...)
+		if err != nil {
+			return fmt.Errorf("insert snapshot_holder dbTx.Exec error:%s", err)
+		}
+	}
+	_, err = dbTx.Exec("Insert Into holder_snapshot(contract, token_id, height, holders, create_time) Values (?, ?, ?, ?, ?) "+
+		"On Duplicate key Update holders=Values(holders), create_time=Values(create_time);",
+		snapshot.Contract, snapshot.TokenId, snapshot.Height, snapshot.Holders, snapshot.CreateTime)
+	if err != nil {
+		return fmt.Errorf("insert holder_snapshot dbTx.Exec error:%s", err)
+	}
+
+	err = dbTx.Commit()
+	if err != nil {
+		return fmt.Errorf("SaveHolderSnapshot dbTx.Commit error:%s", err)
+	}
+	rollBack = false
+	return nil
+}
+
+func (this *MySqlHelper) DeleteHolderSnapshot(snapshot *HolderSnapshot) error {
+	dbTx, err := this.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction error:%s", err)
+	}
+	rollBack := true
+	defer func() {
+		if rollBack {
+			e := dbTx.Rollback()
+			if e != nil {
+				log4.Error("DeleteHolderSnapshot dbTx Rollback error %s", err)
+			}
+		}
+	}()
+	for _, table := range []string{"snapshot_holder", "holder_snapshot"} {
+		_, err = dbTx.Exec("Delete From "+table+" Where contract = ? And token_id = ? And height = ?;", snapshot.Contract, snapshot.TokenId, snapshot.Height)
+		if err != nil {
+			return fmt.Errorf("delete %s dbTx.Exec error:%s", table, err)
+		}
+	}
+	err = dbTx.Commit()
+	if err != nil {
+		return fmt.Errorf("DeleteHolderSnapshot dbTx.Commit error:%s", err)
+	}
+	rollBack = false
+	return nil
+}
+
+func (this *MySqlHelper) GetSyncStates() ([]*SyncState, error) {
+	sqlText := "Select contract, height From sync_state;"
+	rows, err := this.db.Query(sqlText)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	syncStates := make([]*SyncState, 0)
+	for rows.Next() {
+		syncState := &SyncState{}
+		err = rows.Scan(&syncState.Contract, &syncState.Height)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		syncStates = append(syncStates, syncState)
+	}
+	return syncStates, nil
+}
+
+func (this *MySqlHelper) GetContracts() ([]*MonitorContract, error) {
+	sqlText := "Select contract, start_height, state From contracts;"
+	rows, err := this.db.Query(sqlText)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	contracts := make([]*MonitorContract, 0)
+	for rows.Next() {
+		contract := &MonitorContract{}
+		err = rows.Scan(&contract.Contract, &contract.StartHeight, &contract.State)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		contracts = append(contracts, contract)
+	}
+	return contracts, nil
+}
+
+func (this *MySqlHelper) SaveContract(contract *MonitorContract) error {
+	sqlText := "Insert Into contracts(contract, start_height, state, update_time) Values (?, ?, ?, Now()) " +
+		"On Duplicate key Update start_height=Values(start_height), state=Values(state), update_time=Values(update_time);"
+	_, err := this.db.Exec(sqlText, contract.Contract, contract.StartHeight, contract.State)
+	if err != nil {
+		return fmt.Errorf("db.Exec error:%s", err)
+	}
+	return nil
+}
+
 func (this *MySqlHelper) GetSyncedEventNotifyBlockHeight() (uint32, error) {
 	sqlText := "Select ifnull(max(height),0) From eventnotify;"
 	rows, err := this.db.Query(sqlText)
@@ -308,17 +1050,12 @@ func (this *MySqlHelper) IsEventNotifyExist(txHashes []string) (map[string]bool,
 	if count == 0 {
 		return nil, nil
 	}
-	sqlBuf := bytes.NewBuffer(nil)
-	sqlBuf.WriteString("Select tx_hash From eventnotify Where tx_hash In (")
-	for i, txHash := range txHashes {
-		sqlBuf.WriteString("'" + txHash + "'")
-		if i == count-1 {
-			sqlBuf.WriteString(");")
-		} else {
-			sqlBuf.WriteString(", ")
-		}
+	args := make([]interface{}, 0, count)
+	for _, txHash := range txHashes {
+		args = append(args, txHash)
 	}
-	rows, err := this.db.Query(sqlBuf.String())
+	sqlText := "Select tx_hash From eventnotify Where tx_hash In " + sqlPlaceholders(1, count) + ";"
+	rows, err := this.db.Query(sqlText, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -335,22 +1072,142 @@ func (this *MySqlHelper) IsEventNotifyExist(txHashes []string) (map[string]bool,
 	return txHashMap, nil
 }
 
+func (this *MySqlHelper) GetBalances(addresses, contracts []string) ([]*AssetHolder, error) {
+	if len(addresses) == 0 {
+		return make([]*AssetHolder, 0), nil
+	}
+	args := make([]interface{}, 0, len(addresses)+len(contracts))
+	for _, address := range addresses {
+		args = append(args, address)
+	}
+	sqlText := "Select address, contract, token_id, balance, transactions From holder Where address In " + sqlPlaceholders(1, len(addresses))
+	if len(contracts) > 0 {
+		sqlText += " And contract In " + sqlPlaceholders(1, len(contracts))
+		for _, contract := range contracts {
+			args = append(args, contract)
+		}
+	}
+	sqlText += " Order By address, contract, token_id;"
+	rows, err := this.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	holders := make([]*AssetHolder, 0, len(addresses))
+	for rows.Next() {
+		holder := &AssetHolder{}
+		balance := ""
+		err = rows.Scan(&holder.Address, &holder.Contract, &holder.TokenId, &balance, &holder.Transactions)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		holder.Balance, err = ParseBigInt(balance)
+		if err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, nil
+}
+
+func (this *MySqlHelper) GetAllAssetHolders(from, count int) ([]*AssetHolder, error) {
+	sqlText := "Select address, contract, token_id, balance, transactions From holder Order By address, contract, token_id Limit ?, ?;"
+	rows, err := this.db.Query(sqlText, from, count)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	holders := make([]*AssetHolder, 0, count)
+	for rows.Next() {
+		holder := &AssetHolder{}
+		balance := ""
+		err = rows.Scan(&holder.Address, &holder.Contract, &holder.TokenId, &balance, &holder.Transactions)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		holder.Balance, err = ParseBigInt(balance)
+		if err != nil {
+			return nil, err
+		}
+		holders = append(holders, holder)
+	}
+	return holders, nil
+}
+
+func (this *MySqlHelper) SaveBalanceMismatches(mismatches []*BalanceMismatch) error {
+	count := len(mismatches)
+	if count == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, count*8)
+	for _, mismatch := range mismatches {
+		args = append(args, mismatch.Address, mismatch.Contract, mismatch.TokenId, mismatch.IndexedBalance.String(),
+			mismatch.ChainBalance.String(), mismatch.Height, mismatch.IsCorrected, mismatch.Timestamp)
+	}
+	sqlText := "Insert Into balance_mismatch(address, contract, token_id, indexed_balance, chain_balance, height, is_corrected, timestamp) Values " +
+		sqlPlaceholders(count, 8) + " On Duplicate key Update indexed_balance=Values(indexed_balance), chain_balance=Values(chain_balance), " +
+		"height=Values(height), is_corrected=Values(is_corrected), timestamp=Values(timestamp);"
+	_, err := this.db.Exec(sqlText, args...)
+	if err != nil {
+		return fmt.Errorf("db.Exec error:%s", err)
+	}
+	return nil
+}
+
+func (this *MySqlHelper) GetBalanceMismatches(from, count int, contract string) ([]*BalanceMismatch, error) {
+	sqlText := "Select address, contract, token_id, indexed_balance, chain_balance, height, is_corrected, timestamp From balance_mismatch "
+	args := make([]interface{}, 0, 3)
+	if contract != "" {
+		sqlText += "Where contract = ? "
+		args = append(args, contract)
+	}
+	sqlText += "Order By contract, address, token_id Limit ?, ?;"
+	args = append(args, from, count)
+	rows, err := this.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db.Query error:%s", err)
+	}
+	defer rows.Close()
+	mismatches := make([]*BalanceMismatch, 0, count)
+	for rows.Next() {
+		mismatch := &BalanceMismatch{}
+		var indexedBalance, chainBalance string
+		err = rows.Scan(&mismatch.Address, &mismatch.Contract, &mismatch.TokenId, &indexedBalance, &chainBalance,
+			&mismatch.Height, &mismatch.IsCorrected, &mismatch.Timestamp)
+		if err != nil {
+			return nil, fmt.Errorf("row.Scan error:%s", err)
+		}
+		mismatch.IndexedBalance, err = ParseBigInt(indexedBalance)
+		if err != nil {
+			return nil, err
+		}
+		mismatch.ChainBalance, err = ParseBigInt(chainBalance)
+		if err != nil {
+			return nil, err
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches, nil
+}
+
 func (this *MySqlHelper) GetAssetHolderByKey(holders []*AssetHolder) (map[string]*AssetHolder, error) {
 	count := len(holders)
 	if count == 0 {
 		return nil, nil
 	}
 	sqlBuf := bytes.NewBuffer(nil)
-	sqlBuf.WriteString("Select address, contract, balance, transactions From holder Where ")
+	sqlBuf.WriteString("Select address, contract, token_id, balance, transactions From holder Where ")
+	args := make([]interface{}, 0, count*3)
 	for i, holder := range holders {
 		if i == count-1 {
-			sqlBuf.WriteString("(address='" + holder.Address + "' And contract='" + holder.Contract + "');")
+			sqlBuf.WriteString("(address = ? And contract = ? And token_id = ?);")
 		} else {
-			sqlBuf.WriteString("(address='" + holder.Address + "' And contract='" + holder.Contract + "') Or")
+			sqlBuf.WriteString("(address = ? And contract = ? And token_id = ?) Or ")
 		}
+		args = append(args, holder.Address, holder.Contract, holder.TokenId)
 	}
 	sqlText := sqlBuf.String()
-	rows, err := this.db.Query(sqlText)
+	rows, err := this.db.Query(sqlText, args...)
 	if err != nil {
 		return nil, fmt.Errorf("db.Query error:%s", err)
 	}
@@ -359,18 +1216,23 @@ func (this *MySqlHelper) GetAssetHolderByKey(holders []*AssetHolder) (map[string
 	holderMap := make(map[string]*AssetHolder, count)
 	for rows.Next() {
 		holder := &AssetHolder{}
-		err = rows.Scan(&holder.Address, &holder.Contract, &holder.Balance, &holder.Transactions)
+		balance := ""
+		err = rows.Scan(&holder.Address, &holder.Contract, &holder.TokenId, &balance, &holder.Transactions)
 		if err != nil {
 			return nil, fmt.Errorf("row.Scan error:%s", err)
 		}
-		holderMap[holder.Address+holder.Contract] = holder
+		holder.Balance, err = ParseBigInt(balance)
+		if err != nil {
+			return nil, err
+		}
+		holderMap[holder.Key()] = holder
 	}
 	return holderMap, nil
 }
 
-func (this *MySqlHelper) GetAssetHolderCount(contract string) (int, error) {
-	sqlText := "Select ifnull(count(balance),0) From holder Where contract = '" + contract + "';"
-	rows, err := this.db.Query(sqlText)
+func (this *MySqlHelper) GetAssetHolderCount(contract, tokenId string) (int, error) {
+	sqlText := "Select ifnull(count(balance),0) From holder Where contract = ? And token_id = ?;"
+	rows, err := this.db.Query(sqlText, contract, tokenId)
 	if err != nil {
 		return 0, err
 	}
@@ -387,7 +1249,7 @@ func (this *MySqlHelper) GetAssetHolderCount(contract string) (int, error) {
 }
 
 func (this *MySqlHelper) GetAssetHolderCounts() (map[string]int, error) {
-	sqlText := "Select contract, count(*) From holder group by contract;"
+	sqlText := "Select contract, token_id, count(*) From holder group by contract, token_id;"
 	rows, err := this.db.Query(sqlText)
 	if err != nil {
 		return nil, err
@@ -396,19 +1258,20 @@ func (this *MySqlHelper) GetAssetHolderCounts() (map[string]int, error) {
 	counts := make(map[string]int)
 	for rows.Next() {
 		contract := ""
+		tokenId := ""
 		count := 0
-		err = rows.Scan(&contract, &count)
+		err = rows.Scan(&contract, &tokenId, &count)
 		if err != nil {
 			return nil, fmt.Errorf("row.Scan error:%s", err)
 		}
-		counts[contract] = count
+		counts[contract+tokenId] = count
 	}
 	return counts, nil
 }
 
 func (this *MySqlHelper) GetHeartbeat(module string) (*Heartbeat, error) {
-	sqlText := "Select node_id, update_time From heartbeat Where module = '" + module + "'"
-	rows, err := this.db.Query(sqlText)
+	sqlText := "Select node_id, update_time From heartbeat Where module = ?;"
+	rows, err := this.db.Query(sqlText, module)
 	if err != nil {
 		return nil, err
 	}
@@ -425,8 +1288,8 @@ func (this *MySqlHelper) GetHeartbeat(module string) (*Heartbeat, error) {
 }
 
 func (this *MySqlHelper) InsertHeartbeat(heartbeat *Heartbeat) error {
-	sqlText := fmt.Sprintf("Insert into heartbeat(module, node_id, update_time) Values ('%s', %d, Now());", heartbeat.Module, heartbeat.NodeId)
-	results, err := this.db.Exec(sqlText)
+	sqlText := "Insert into heartbeat(module, node_id, update_time) Values (?, ?, Now());"
+	results, err := this.db.Exec(sqlText, heartbeat.Module, heartbeat.NodeId)
 	if err != nil {
 		return fmt.Errorf("db.Exec error:%s", err)
 	}
@@ -441,8 +1304,8 @@ func (this *MySqlHelper) InsertHeartbeat(heartbeat *Heartbeat) error {
 }
 
 func (this *MySqlHelper) UpdateHeartbeat(module string, nodeId uint32) (bool, error) {
-	sqlText := fmt.Sprintf("Update heartbeat Set update_time = Now() Where node_id = %d;", nodeId)
-	results, err := this.db.Exec(sqlText)
+	sqlText := "Update heartbeat Set update_time = Now() Where module = ? And node_id = ?;"
+	results, err := this.db.Exec(sqlText, module, nodeId)
 	if err != nil {
 		return false, fmt.Errorf("db.Exec error:%s", err)
 	}
@@ -457,8 +1320,8 @@ func (this *MySqlHelper) UpdateHeartbeat(module string, nodeId uint32) (bool, er
 }
 
 func (this *MySqlHelper) CheckHeartbeatTimeout(module string, timeout uint32) (uint32, error) {
-	sqlText := fmt.Sprintf("Select ifnull(node_id,0) From heartbeat Where module = '%s' And time_to_sec(timediff(Now(),update_time)) >= %d;", module, timeout)
-	rows, err := this.db.Query(sqlText)
+	sqlText := "Select ifnull(node_id,0) From heartbeat Where module = ? And time_to_sec(timediff(Now(),update_time)) >= ?;"
+	rows, err := this.db.Query(sqlText, module, timeout)
 	if err != nil {
 		return 0, err
 	}
@@ -475,8 +1338,8 @@ func (this *MySqlHelper) CheckHeartbeatTimeout(module string, timeout uint32) (u
 }
 
 func (this *MySqlHelper) ResetHeartbeat(module string, nodeId, lastNodeId uint32) (bool, error) {
-	sqlText := fmt.Sprintf("Update heartbeat Set node_id = %d, update_time = Now() Where module = '%s' And node_id = %d;", nodeId, module, lastNodeId)
-	results, err := this.db.Exec(sqlText)
+	sqlText := "Update heartbeat Set node_id = ?, update_time = Now() Where module = ? And node_id = ?;"
+	results, err := this.db.Exec(sqlText, nodeId, module, lastNodeId)
 	if err != nil {
 		return false, fmt.Errorf("db.Exec error:%s", err)
 	}
@@ -486,3 +1349,10 @@ func (this *MySqlHelper) ResetHeartbeat(module string, nodeId, lastNodeId uint32
 	}
 	return affected == 1, nil
 }
+
+//sqlPlaceholders returns rowCount groups of columnCount placeholders, like "(?, ?),(?, ?)",
+//for multi-row inserts and In lists. Values are always passed as query args, never formatted into sql text.
+func sqlPlaceholders(rowCount, columnCount int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", columnCount), ", ") + ")"
+	return strings.TrimSuffix(strings.Repeat(row+",", rowCount), ",")
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestGetAssetHolderSql(t *testing.T) {
+	cursor := &HolderCursor{Balance: big.NewInt(100), Address: TEST_ADDRESS_A}
+	tests := []struct {
+		name     string
+		from     int
+		count    int
+		cursor   *HolderCursor
+		address  string
+		contract string
+		tokenId  string
+		isDesc   bool
+		sqlText  string
+		args     []interface{}
+	}{
+		{
+			name: "holders of contract", from: 10, count: 5, contract: TEST_CONTRACT, isDesc: true,
+			sqlText: "Select address, contract, token_id, balance, transactions From holder Where contract = ? And token_id = ? Order By balance DESC, address DESC Limit ?, ?;",
+			args:    []interface{}{TEST_CONTRACT, "", 10, 5},
+		},
+		{
+			name: "cursor desc", from: 10, count: 5, cursor: cursor, contract: TEST_CONTRACT, tokenId: "01", isDesc: true,
+			sqlText: "Select address, contract, token_id, balance, transactions From holder Where contract = ? And token_id = ? " +
+				"And balance <= Cast(? As Decimal(65,0)) And (balance < Cast(? As Decimal(65,0)) Or address < ?) Order By balance DESC, address DESC Limit ?, ?;",
+			args: []interface{}{TEST_CONTRACT, "01", "100", "100", TEST_ADDRESS_A, 0, 5},
+		},
+		{
+			name: "cursor asc", count: 5, cursor: cursor, contract: TEST_CONTRACT,
+			sqlText: "Select address, contract, token_id, balance, transactions From holder Where contract = ? And token_id = ? " +
+				"And balance >= Cast(? As Decimal(65,0)) And (balance > Cast(? As Decimal(65,0)) Or address > ?) Order By balance ASC, address ASC Limit ?, ?;",
+			args: []interface{}{TEST_CONTRACT, "", "100", "100", TEST_ADDRESS_A, 0, 5},
+		},
+		{
+			name: "balances of address", address: TEST_ADDRESS_A, isDesc: true,
+			sqlText: "Select address, contract, token_id, balance, transactions From holder Where address = ? Order By balance DESC, address DESC;",
+			args:    []interface{}{TEST_ADDRESS_A},
+		},
+		{
+			name: "balance of address of all token ids", address: TEST_ADDRESS_A, contract: TEST_CONTRACT, isDesc: true,
+			sqlText: "Select address, contract, token_id, balance, transactions From holder Where contract = ? And address = ? Order By balance DESC, address DESC;",
+			args:    []interface{}{TEST_CONTRACT, TEST_ADDRESS_A},
+		},
+		{
+			name: "all holders", count: 5, isDesc: true,
+			sqlText: "Select address, contract, token_id, balance, transactions From holder Order By balance DESC, address DESC Limit ?, ?;",
+			args:    []interface{}{0, 5},
+		},
+	}
+	for _, test := range tests {
+		sqlText, args := getAssetHolderSql(test.from, test.count, test.cursor, test.address, test.contract, test.tokenId, test.isDesc)
+		if sqlText != test.sqlText {
+			t.Errorf("%s: sql\n%s\nexpect\n%s", test.name, sqlText, test.sqlText)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: args %v, expect %v", test.name, args, test.args)
+		}
+	}
+}
+
+func TestGetAssetHolderAtHeightSql(t *testing.T) {
+	cursor := &HolderCursor{Balance: big.NewInt(100), Address: TEST_ADDRESS_A, IsSnapshot: true, Height: 20}
+	tests := []struct {
+		name    string
+		from    int
+		count   int
+		cursor  *HolderCursor
+		tokenId string
+		sqlText string
+		args    []interface{}
+	}{
+		{
+			name: "page by from", from: 10, count: 5,
+			sqlText: "Select address, balance, transactions From snapshot_holder Where contract = ? And token_id = ? And height = ? Order By balance DESC, address DESC Limit ?, ?;",
+			args:    []interface{}{TEST_CONTRACT, "", uint32(20), 10, 5},
+		},
+		{
+			name: "page by cursor", from: 10, count: 5, cursor: cursor, tokenId: "01",
+			sqlText: "Select address, balance, transactions From snapshot_holder Where contract = ? And token_id = ? And height = ? " +
+				"And balance <= Cast(? As Decimal(65,0)) And (balance < Cast(? As Decimal(65,0)) Or address < ?) Order By balance DESC, address DESC Limit ?, ?;",
+			args: []interface{}{TEST_CONTRACT, "01", uint32(20), "100", "100", TEST_ADDRESS_A, 0, 5},
+		},
+		{
+			name:    "all holders",
+			sqlText: "Select address, balance, transactions From snapshot_holder Where contract = ? And token_id = ? And height = ? Order By balance DESC, address DESC;",
+			args:    []interface{}{TEST_CONTRACT, "", uint32(20)},
+		},
+	}
+	for _, test := range tests {
+		sqlText, args := getAssetHolderAtHeightSql(test.from, test.count, test.cursor, TEST_CONTRACT, test.tokenId, 20)
+		if sqlText != test.sqlText {
+			t.Errorf("%s: sql\n%s\nexpect\n%s", test.name, sqlText, test.sqlText)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: args %v, expect %v", test.name, args, test.args)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+//NATIVE_RECONCILE_INTERVAL is the interval to check native holders with chain in native mode, if ReconcileInterval is not set
+const NATIVE_RECONCILE_INTERVAL = 60 //s
+
+//Names of native transfers in native mode, the other native transfers are named "transfer"
+const (
+	NATIVE_TRANSFER_GAS_FEE    = "gasFee"     //ONG gas fee paid to governance contract
+	NATIVE_TRANSFER_CLAIM_ONG  = "claimOng"   //unbound ONG claimed from ONT contract
+	NATIVE_TRANSFER_GOVERNANCE = "governance" //transfers from or to governance contract, such as stake and rewards
+)
+
+//getNativeTransferName returns the name of native transfer in native mode.
+//Gas fee is the last notify of tx, which is an ONG transfer of gasConsumed to governance contract, there is no gas fee
+//if gasConsumed is 0. Unbound ONG is held by ONT contract since genesis, and claimed by an ONG transfer from ONT contract.
+func getNativeTransferName(transfer *TxTransfer, isLastNotify bool, gasConsumed uint64) string {
+	switch {
+	case transfer.Contract == ONG_CONTRACT_ADDRESS && transfer.From == ONT_CONTRACT_ADDRESS:
+		return NATIVE_TRANSFER_CLAIM_ONG
+	case transfer.Contract == ONG_CONTRACT_ADDRESS && transfer.To == GOVERNANCE_CONTRACT_ADDRESS && isLastNotify &&
+		gasConsumed > 0 && transfer.Amount.IsUint64() && transfer.Amount.Uint64() == gasConsumed:
+		return NATIVE_TRANSFER_GAS_FEE
+	case transfer.From == GOVERNANCE_CONTRACT_ADDRESS || transfer.To == GOVERNANCE_CONTRACT_ADDRESS:
+		return NATIVE_TRANSFER_GOVERNANCE
+	}
+	return transfer.Name
+}
+
+//isNativePoolTransfer checks transfer is paid from a pool of native contract in native mode, which is unbound ONG
+//claimed from ONT contract, or stake, fees and rewards paid by governance contract. Balances of pools are complete
+//only if the native contract is synced from genesis, so a pool short of the amount is checked with chain by reconcile.
+func isNativePoolTransfer(transfer *TxTransfer) bool {
+	switch transfer.Name {
+	case NATIVE_TRANSFER_CLAIM_ONG:
+		return true
+	case NATIVE_TRANSFER_GOVERNANCE:
+		return transfer.From == GOVERNANCE_CONTRACT_ADDRESS
+	}
+	return false
+}
+
+//isGasFeeTransfer checks transfer is the ONG gas fee, which is charged even if tx is failed
+func isGasFeeTransfer(transfer *TxTransfer) bool {
+	if DefConfig.NativeMode && IsNativeContract(transfer.Contract) {
+		return transfer.Name == NATIVE_TRANSFER_GAS_FEE
+	}
+	return transfer.Contract == ONG_CONTRACT_ADDRESS && transfer.To == GOVERNANCE_CONTRACT_ADDRESS
+}
+
+//getNativeCheckHolders returns the holders whose balances are checked with chain in native mode, which are changed by
+//the native flows not modeled by balanceOf of a plain transfer: the governance contract receiving gas fee,
+//the ONT contract holding unbound ONG and its claimer, and both sides of governance transfers.
+func getNativeCheckHolders(transfers []*TxTransfer) []*AssetHolder {
+	holders := make([]*AssetHolder, 0)
+	holderKeys := make(map[string]bool)
+	for _, transfer := range transfers {
+		var addresses []string
+		switch transfer.Name {
+		case NATIVE_TRANSFER_GAS_FEE:
+			addresses = []string{transfer.To}
+		case NATIVE_TRANSFER_CLAIM_ONG, NATIVE_TRANSFER_GOVERNANCE:
+			addresses = []string{transfer.From, transfer.To}
+		default:
+			continue
+		}
+		for _, address := range addresses {
+			holder := &AssetHolder{Address: address, Contract: transfer.Contract}
+			if address == ZERO_ADDRESS || holderKeys[holder.Key()] {
+				continue
+			}
+			holderKeys[holder.Key()] = true
+			holders = append(holders, holder)
+		}
+	}
+	return holders
+}
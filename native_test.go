@@ -0,0 +1,241 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	sdkcom "github.com/ontio/ontology-go-sdk/common"
+	"math/big"
+	"testing"
+)
+
+const (
+	TEST_ZERO_BASE58       = "AFmseVrdL9f9oyCzZefL9tG6UbvhPbdYzM"
+	TEST_ONT_BASE58        = "AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV"
+	TEST_GOVERNANCE_BASE58 = "AFmseVrdL9f9oyCzZefL9tG6UbviEH9ugK"
+	TEST_USER_BASE58       = "AJo4qjAkSQEPvejXEKRwKyNoPzyXD8EYhA"
+)
+
+//testNativeIndexer indexes native notifies by OntologyManager in native mode, one tx per block
+type testNativeIndexer struct {
+	t      *testing.T
+	mgr    *OntologyManager
+	height uint32
+}
+
+func newTestNativeIndexer(t *testing.T) *testNativeIndexer {
+	DefConfig.NativeMode = true
+	t.Cleanup(func() {
+		DefConfig.NativeMode = false
+	})
+	mgr := NewOntologyManager(nil, newTestLevelDBHelper(t))
+	mgr.hb = &Heartbeat{NodeId: NodeId}
+	return &testNativeIndexer{t: t, mgr: mgr}
+}
+
+func nativeTransferNotify(contract, from, to string, amount uint64) *sdkcom.NotifyEventInfo {
+	return &sdkcom.NotifyEventInfo{ContractAddress: contract, States: []interface{}{NOTIFY_TRANSFER, from, to, amount}}
+}
+
+//index indexes a tx of notifies in a new block, and returns the names of applied transfers
+func (this *testNativeIndexer) index(state byte, gasConsumed uint64, notifies ...*sdkcom.NotifyEventInfo) []string {
+	this.height++
+	evt := &sdkcom.SmartContactEvent{TxHash: fmt.Sprintf("%064x", this.height), State: state, GasConsumed: gasConsumed, Notify: notifies}
+	contracts := map[string]bool{ONT_CONTRACT_ADDRESS: true, ONG_CONTRACT_ADDRESS: true}
+	transfers, approvals := this.mgr.getTxTransferFromNotify(evt, this.height, this.height, contracts)
+	transfers, approvals = getAppliedTransfers(evt, transfers, approvals)
+	txNotifies := []*TxEventNotify{{TxHash: evt.TxHash, Height: this.height, State: int(state)}}
+	err := this.mgr.onTransfer(txNotifies, transfers, approvals, contracts, this.height)
+	if err != nil {
+		this.t.Fatalf("onTransfer error:%s", err)
+	}
+	names := make([]string, 0, len(transfers))
+	for _, transfer := range transfers {
+		names = append(names, transfer.Name)
+	}
+	return names
+}
+
+func (this *testNativeIndexer) checkBalance(address, contract string, balance int64) {
+	holder := getTestHolder(this.t, this.mgr.store, address, contract)
+	if balance == 0 {
+		if holder != nil {
+			this.t.Errorf("holder:%s of contract:%s balance:%s, expected deleted", address, contract, holder.Balance)
+		}
+		return
+	}
+	if holder == nil || holder.Balance.Int64() != balance {
+		this.t.Errorf("holder:%s of contract:%s is %+v, expected balance:%d", address, contract, holder, balance)
+	}
+}
+
+func TestNativeModeFlows(t *testing.T) {
+	indexer := newTestNativeIndexer(t)
+	user, _ := notifyBase58Address(TEST_USER_BASE58)
+
+	//unbound ONG is held by ONT contract since genesis
+	names := indexer.index(TX_STATE_SUCCESS, 0, nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_ZERO_BASE58, TEST_ONT_BASE58, 1000))
+	checkTransferNames(t, "genesis", names, NOTIFY_TRANSFER)
+	indexer.checkBalance(ONT_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 1000)
+
+	//claim unbound ONG and pay gas fee
+	names = indexer.index(TX_STATE_SUCCESS, 1,
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_ONT_BASE58, TEST_USER_BASE58, 100),
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_USER_BASE58, TEST_GOVERNANCE_BASE58, 1))
+	checkTransferNames(t, "claim", names, NATIVE_TRANSFER_CLAIM_ONG, NATIVE_TRANSFER_GAS_FEE)
+	indexer.checkBalance(ONT_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 900)
+	indexer.checkBalance(user, ONG_CONTRACT_ADDRESS, 99)
+	indexer.checkBalance(GOVERNANCE_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 1)
+
+	//stake to governance contract and reward from it, an ONG transfer to governance contract which isn't the last notify isn't gas fee
+	names = indexer.index(TX_STATE_SUCCESS, 1,
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_USER_BASE58, TEST_GOVERNANCE_BASE58, 5),
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_GOVERNANCE_BASE58, TEST_USER_BASE58, 2),
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_USER_BASE58, TEST_GOVERNANCE_BASE58, 1))
+	checkTransferNames(t, "governance", names, NATIVE_TRANSFER_GOVERNANCE, NATIVE_TRANSFER_GOVERNANCE, NATIVE_TRANSFER_GAS_FEE)
+	indexer.checkBalance(user, ONG_CONTRACT_ADDRESS, 95)
+	indexer.checkBalance(GOVERNANCE_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 5)
+
+	//only gas fee of a failed tx is applied, since the other native transfers are reverted by chain
+	names = indexer.index(TX_STATE_FAILED, 2,
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_ONT_BASE58, TEST_USER_BASE58, 50),
+		nativeTransferNotify(ONT_CONTRACT_ADDRESS, TEST_USER_BASE58, TEST_GOVERNANCE_BASE58, 1),
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_USER_BASE58, TEST_GOVERNANCE_BASE58, 2))
+	checkTransferNames(t, "failed", names, NATIVE_TRANSFER_GAS_FEE)
+	indexer.checkBalance(ONT_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 900)
+	indexer.checkBalance(user, ONG_CONTRACT_ADDRESS, 93)
+	indexer.checkBalance(user, ONT_CONTRACT_ADDRESS, 0)
+	indexer.checkBalance(GOVERNANCE_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 7)
+
+	//there is no gas fee if gas price is 0, so the last ONG transfer to governance contract of a failed tx isn't applied
+	names = indexer.index(TX_STATE_FAILED, 0, nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_USER_BASE58, TEST_GOVERNANCE_BASE58, 3))
+	checkTransferNames(t, "failed without gas fee", names)
+	indexer.checkBalance(user, ONG_CONTRACT_ADDRESS, 93)
+
+	//holders changed by native flows are queued to check with chain
+	expectedKeys := map[string]bool{
+		ONT_CONTRACT_ADDRESS + ONG_CONTRACT_ADDRESS:        true,
+		user + ONG_CONTRACT_ADDRESS:                        true,
+		GOVERNANCE_CONTRACT_ADDRESS + ONG_CONTRACT_ADDRESS: true,
+	}
+	if len(indexer.mgr.checkHolders) != len(expectedKeys) {
+		t.Errorf("native check holders:%v, expected:%v", indexer.mgr.checkHolders, expectedKeys)
+	}
+	for key := range indexer.mgr.checkHolders {
+		if !expectedKeys[key] {
+			t.Errorf("unexpected native check holder:%s", key)
+		}
+	}
+}
+
+func TestNativeModePools(t *testing.T) {
+	indexer := newTestNativeIndexer(t)
+	user, _ := notifyBase58Address(TEST_USER_BASE58)
+
+	//pools of native contracts are not indexed if sync doesn't start from genesis, receivers are still credited
+	names := indexer.index(TX_STATE_SUCCESS, 0,
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_ONT_BASE58, TEST_USER_BASE58, 100),
+		nativeTransferNotify(ONG_CONTRACT_ADDRESS, TEST_GOVERNANCE_BASE58, TEST_USER_BASE58, 5))
+	checkTransferNames(t, "pools", names, NATIVE_TRANSFER_CLAIM_ONG, NATIVE_TRANSFER_GOVERNANCE)
+	indexer.checkBalance(user, ONG_CONTRACT_ADDRESS, 105)
+	indexer.checkBalance(ONT_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 0)
+	indexer.checkBalance(GOVERNANCE_CONTRACT_ADDRESS, ONG_CONTRACT_ADDRESS, 0)
+
+	transfers, err := indexer.mgr.store.GetTransferHistory(0, 10, user, ONG_CONTRACT_ADDRESS, 0, indexer.height)
+	if err != nil {
+		t.Fatalf("GetTransferHistory error:%s", err)
+	}
+	if len(transfers) != 2 || !transfers[0].IsInvalid || !transfers[1].IsInvalid {
+		t.Errorf("transfers from short pools:%+v, expected invalid", transfers)
+	}
+	//short pools are queued to be inserted with chain balances by reconcile
+	for _, address := range []string{ONT_CONTRACT_ADDRESS, GOVERNANCE_CONTRACT_ADDRESS, user} {
+		if indexer.mgr.checkHolders[address+ONG_CONTRACT_ADDRESS] == nil {
+			t.Errorf("holder:%s of pool transfer isn't queued", address)
+		}
+	}
+}
+
+func TestGetNativeTransferName(t *testing.T) {
+	tests := []struct {
+		name         string
+		transfer     *TxTransfer
+		isLastNotify bool
+		gasConsumed  uint64
+		expected     string
+	}{
+		{"gas fee", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS}, true, 10, NATIVE_TRANSFER_GAS_FEE},
+		{"stake as last notify", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS}, true, 9, NATIVE_TRANSFER_GOVERNANCE},
+		{"no gas fee", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS}, true, 0, NATIVE_TRANSFER_GOVERNANCE},
+		{"stake", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS}, false, 10, NATIVE_TRANSFER_GOVERNANCE},
+		{"ont to governance", &TxTransfer{Contract: ONT_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS}, true, 10, NATIVE_TRANSFER_GOVERNANCE},
+		{"reward", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: GOVERNANCE_CONTRACT_ADDRESS, To: TEST_ADDRESS_A}, false, 10, NATIVE_TRANSFER_GOVERNANCE},
+		{"claim", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: ONT_CONTRACT_ADDRESS, To: TEST_ADDRESS_A}, false, 10, NATIVE_TRANSFER_CLAIM_ONG},
+		{"ont of ont contract", &TxTransfer{Contract: ONT_CONTRACT_ADDRESS, From: ONT_CONTRACT_ADDRESS, To: TEST_ADDRESS_A}, false, 10, NOTIFY_TRANSFER},
+		{"transfer", &TxTransfer{Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: TEST_ADDRESS_B}, true, 10, NOTIFY_TRANSFER},
+	}
+	for _, test := range tests {
+		test.transfer.Name = NOTIFY_TRANSFER
+		test.transfer.Amount = big.NewInt(10)
+		name := getNativeTransferName(test.transfer, test.isLastNotify, test.gasConsumed)
+		if name != test.expected {
+			t.Errorf("%s: name:%s, expected:%s", test.name, name, test.expected)
+		}
+	}
+}
+
+func TestGetNativeCheckHolders(t *testing.T) {
+	transfers := []*TxTransfer{
+		{Name: NOTIFY_TRANSFER, Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: TEST_ADDRESS_B},
+		{Name: NATIVE_TRANSFER_GAS_FEE, Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS},
+		{Name: NATIVE_TRANSFER_GAS_FEE, Contract: ONG_CONTRACT_ADDRESS, From: TEST_ADDRESS_B, To: GOVERNANCE_CONTRACT_ADDRESS},
+		{Name: NATIVE_TRANSFER_CLAIM_ONG, Contract: ONG_CONTRACT_ADDRESS, From: ONT_CONTRACT_ADDRESS, To: TEST_ADDRESS_C},
+		{Name: NATIVE_TRANSFER_GOVERNANCE, Contract: ONT_CONTRACT_ADDRESS, From: TEST_ADDRESS_A, To: GOVERNANCE_CONTRACT_ADDRESS},
+		{Name: NATIVE_TRANSFER_GOVERNANCE, Contract: ONG_CONTRACT_ADDRESS, From: ZERO_ADDRESS, To: GOVERNANCE_CONTRACT_ADDRESS},
+	}
+	expected := []string{
+		GOVERNANCE_CONTRACT_ADDRESS + ONG_CONTRACT_ADDRESS,
+		ONT_CONTRACT_ADDRESS + ONG_CONTRACT_ADDRESS,
+		TEST_ADDRESS_C + ONG_CONTRACT_ADDRESS,
+		TEST_ADDRESS_A + ONT_CONTRACT_ADDRESS,
+		GOVERNANCE_CONTRACT_ADDRESS + ONT_CONTRACT_ADDRESS,
+	}
+	holders := getNativeCheckHolders(transfers)
+	if len(holders) != len(expected) {
+		t.Fatalf("holders count:%d, expected:%d", len(holders), len(expected))
+	}
+	for i, holder := range holders {
+		if holder.Key() != expected[i] {
+			t.Errorf("holder %d:%s, expected:%s", i, holder.Key(), expected[i])
+		}
+	}
+}
+
+func checkTransferNames(t *testing.T, flow string, names []string, expected ...string) {
+	if len(names) != len(expected) {
+		t.Errorf("%s: transfer names:%v, expected:%v", flow, names, expected)
+		return
+	}
+	for i, name := range names {
+		if name != expected[i] {
+			t.Errorf("%s: transfer names:%v, expected:%v", flow, names, expected)
+			return
+		}
+	}
+}
@@ -26,6 +26,8 @@ import (
 	ontsdk "github.com/ontio/ontology-go-sdk"
 	sdkcom "github.com/ontio/ontology-go-sdk/common"
 	"github.com/ontio/ontology/common"
+	"math/big"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -39,26 +41,32 @@ const (
 
 type EventNotify struct {
 	BlockHeight   uint32
+	BlockTime     uint32
 	EventNotifies []*sdkcom.SmartContactEvent
 }
 
 type OntologyManager struct {
-	ontSdk                     *ontsdk.OntologySdk
-	mysqlHelper                *MySqlHelper
+	rpcMgr                     *RpcManager
+	store                      HolderStore
 	syncedEvtNotifyBlockHeight uint32
+	committedBlockHeight       uint32
 	syncEvtNotifyChan          chan *EventNotify
 	hb                         *Heartbeat
 	holderCounts               map[string]int
 	exitCh                     chan interface{}
 	lock                       sync.RWMutex
+	holderLock                 sync.Mutex              //serializes read-modify-write of holders between sync and reconcile
+	snapshotLock               sync.Mutex              //serializes building holder snapshots
+	checkHolders               map[string]*AssetHolder //holders to check with chain by the next reconcile
 }
 
-func NewOntologyManager(ontSdk *ontsdk.OntologySdk, mySqlHelper *MySqlHelper) *OntologyManager {
+func NewOntologyManager(rpcMgr *RpcManager, store HolderStore) *OntologyManager {
 	return &OntologyManager{
-		ontSdk:            ontSdk,
-		mysqlHelper:       mySqlHelper,
+		rpcMgr:            rpcMgr,
+		store:             store,
 		syncEvtNotifyChan: make(chan *EventNotify, SYNC_EVTNOTIFY_CHAN_SIZE),
 		exitCh:            make(chan interface{}, 0),
+		checkHolders:      make(map[string]*AssetHolder),
 	}
 }
 
@@ -71,6 +79,10 @@ func (this *OntologyManager) Start() error {
 	if err != nil {
 		return err
 	}
+	err = this.initContracts()
+	if err != nil {
+		return err
+	}
 	go this.startHeartbeat()
 	go this.startUpdateInfo()
 
@@ -84,15 +96,101 @@ func (this *OntologyManager) Start() error {
 	}
 	go this.startSyncEvtNotify()
 	go this.handleEvtNotify()
+	go this.startReconcile()
+	this.startBackfill()
+	return nil
+}
+
+//initContracts registers contracts of config to store, the contracts table is the monitored set after that.
+func (this *OntologyManager) initContracts() error {
+	contracts, err := this.store.GetContracts()
+	if err != nil {
+		return fmt.Errorf("GetContracts error:%s", err)
+	}
+	contractMap := make(map[string]bool, len(contracts))
+	for _, contract := range contracts {
+		contractMap[contract.Contract] = true
+	}
+	//legacyContracts is the contracts synced from BlockHeight, which may be synced by older version
+	legacyContracts := make([]string, 0, len(DefConfig.Contracts))
+	for _, contract := range DefConfig.Contracts {
+		if contractMap[contract] {
+			continue
+		}
+		startHeight, isConfigured, err := this.getConfigStartHeight(contract)
+		if err != nil {
+			return err
+		}
+		if !isConfigured {
+			legacyContracts = append(legacyContracts, contract)
+		}
+		err = this.store.SaveContract(&MonitorContract{
+			Contract:    contract,
+			StartHeight: startHeight,
+			State:       CONTRACT_STATE_ACTIVE,
+		})
+		if err != nil {
+			return fmt.Errorf("SaveContract error:%s", err)
+		}
+		log4.Info("Register contract:%s from config, start height:%d", contract, startHeight)
+	}
+
+	syncStates, err := this.store.GetSyncStates()
+	if err != nil {
+		return fmt.Errorf("GetSyncStates error:%s", err)
+	}
+	if len(syncStates) > 0 {
+		return nil
+	}
+	//No checkpoint saved by older version, infer it from event notify
+	syncedBlockHeight, err := this.store.GetSyncedEventNotifyBlockHeight()
+	if err != nil {
+		return fmt.Errorf("GetSyncedEventNotifyBlockHeight error:%s", err)
+	}
+	if syncedBlockHeight == 0 {
+		return nil
+	}
+	//Insure all of the block transactions has already inserted to db
+	syncedBlockHeight--
+	syncStates = make([]*SyncState, 0, len(legacyContracts))
+	for _, contract := range legacyContracts {
+		syncStates = append(syncStates, &SyncState{
+			Contract: contract,
+			Height:   syncedBlockHeight,
+		})
+	}
+	err = this.store.OnTxEventNotify(nil, nil, nil, nil, nil, syncStates)
+	if err != nil {
+		return fmt.Errorf("OnTxEventNotify error:%s", err)
+	}
+	log4.Info("Init sync checkpoint:%d from event notify", syncedBlockHeight)
 	return nil
 }
 
+//getConfigStartHeight returns the start height of contract in config, which is configured by ContractStartHeights,
+//or is the height of deploy transaction in ContractDeployTxs. Default is the next block of BlockHeight.
+func (this *OntologyManager) getConfigStartHeight(contract string) (uint32, bool, error) {
+	startHeight, ok := DefConfig.ContractStartHeights[contract]
+	if ok {
+		return startHeight, true, nil
+	}
+	deployTx, ok := DefConfig.ContractDeployTxs[contract]
+	if ok {
+		startHeight, err := getDeployHeight(this.GetOntSdk(), deployTx)
+		if err != nil {
+			return 0, false, fmt.Errorf("contract:%s getDeployHeight error:%s", contract, err)
+		}
+		return startHeight, true, nil
+	}
+	return DefConfig.BlockHeight + 1, false, nil
+}
+
 func (this *OntologyManager) initSyncedEvtBlockHeight() error {
 	return this.updateSyncedEvtNotifyBlockHeight()
 }
 
 func (this *OntologyManager) initAssetHolderCounts() error {
-	counts, err := this.mysqlHelper.GetAssetHolderCounts()
+	counts, err := this.store.GetAssetHolderCounts()
 	if err != nil {
 		return fmt.Errorf("GetAssetHolderCounts error:%s", err)
 	}
@@ -107,33 +205,40 @@ func (this *OntologyManager) initGenesisBlock() error {
 	if this.syncedEvtNotifyBlockHeight > 0 {
 		return nil
 	}
-	isGenesisInit, err := this.mysqlHelper.IsGenesisInit()
+	isGenesisInit, err := this.store.IsGenesisInit()
 	if err != nil {
-		return fmt.Errorf("mysqlHelper.IsGenesisInit error:%s")
+		return fmt.Errorf("IsGenesisInit error:%s", err)
 	}
 	if isGenesisInit {
 		return nil
 	}
-	evts, err := this.ontSdk.GetSmartContractEventByBlock(0)
+	evts, err := this.rpcMgr.GetSmartContractEventByBlock(0)
 	if err != nil {
 		return fmt.Errorf("GetSmartContractEventByBlock error:%s", err)
 	}
+	blockTime, err := this.getBlockTime(0)
+	if err != nil {
+		return fmt.Errorf("getBlockTime error:%s", err)
+	}
+	contracts := DefContractMgr.BeginDecode(0)
 	assetHolders := make([]*AssetHolder, 0, 2)
 	txNotifies := make([]*TxEventNotify, 0, 2)
+	txTransfers := make([]*TxTransfer, 0, 2)
 	for _, evt := range evts {
-		transfers := this.getTxTransferFromNotify(evt)
+		transfers, _ := this.getTxTransferFromNotify(evt, 0, blockTime, contracts)
 		if len(transfers) == 0 {
 			continue
 		}
+		txTransfers = append(txTransfers, transfers...)
 		transferEvts := make([][]interface{}, 0, 2)
 		for _, transfer := range transfers {
 			assetHolders = append(assetHolders, &AssetHolder{
 				Contract: transfer.Contract,
 				Address:  transfer.To,
-				Balance:  transfer.Amount,
+				Balance:  new(big.Int).Set(transfer.Amount),
 				Transactions: 1,
 			})
-			transferEvts = append(transferEvts, []interface{}{transfer.Name, transfer.From, transfer.To, transfer.Amount})
+			transferEvts = append(transferEvts, []interface{}{transfer.Name, transfer.From, transfer.To, transfer.Amount.String()})
 		}
 		notifyJson, err := json.Marshal(transferEvts)
 		if err != nil {
@@ -148,11 +253,15 @@ func (this *OntologyManager) initGenesisBlock() error {
 			Notify:      string(notifyJson),
 		})
 	}
-	err = this.mysqlHelper.OnTxEventNotify(txNotifies, assetHolders)
+	return this.saveTxEventNotify(txNotifies, assetHolders, txTransfers, nil, contracts, 0)
+}
+
+func (this *OntologyManager) getBlockTime(height uint32) (uint32, error) {
+	block, err := this.GetOntSdk().GetBlockByHeight(height)
 	if err != nil {
-		return fmt.Errorf("OnTxEventNotify error:%s", err)
+		return 0, err
 	}
-	return nil
+	return block.Header.Timestamp, nil
 }
 
 func (this *OntologyManager) startSyncEvtNotify() {
@@ -169,150 +278,154 @@ func (this *OntologyManager) startSyncEvtNotify() {
 }
 
 func (this *OntologyManager) syncEvtNotify() {
-	currentBlockHeight, err := this.ontSdk.GetCurrentBlockHeight()
+	currentBlockHeight, err := this.GetOntSdk().GetCurrentBlockHeight()
 	if err != nil {
 		log4.Error("GetCurrentBlockHeight error:%s", err)
 		return
 	}
 	syncedBlockHeight := this.GetSyncedEvtNotifyBlockHeight()
-	if currentBlockHeight == syncedBlockHeight {
+	if currentBlockHeight <= syncedBlockHeight {
 		return
 	}
 	log4.Debug("Start to sync block height:%d", syncedBlockHeight+1)
-	for height := syncedBlockHeight + 1; uint32(height) <= currentBlockHeight; height++ {
+	err = this.fetchEvtNotify(syncedBlockHeight+1, currentBlockHeight, func(evtNotify *EventNotify) bool {
 		if this.GetCurrentNodeId() != NodeId {
-			return
-		}
-		evt, err := this.ontSdk.GetSmartContractEventByBlock(uint32(height))
-		if err != nil {
-			log4.Error("GetSmartContractEventByBlock error:%s", err)
-			return
+			return false
 		}
 		select {
-		case this.syncEvtNotifyChan <- &EventNotify{
-			BlockHeight:   uint32(height),
-			EventNotifies: evt,
-		}:
-			this.SetSyncedEvtNotifyBlockHeight(height)
-		default:
-			return
+		case this.syncEvtNotifyChan <- evtNotify:
+			this.SetSyncedEvtNotifyBlockHeight(evtNotify.BlockHeight)
+			return true
+		case <-this.exitCh:
+			return false
+		}
+	})
+	if err != nil {
+		log4.Error("fetchEvtNotify error:%s", err)
+	}
+}
+
+type evtNotifyResult struct {
+	evtNotify *EventNotify
+	err       error
+}
+
+//fetchEvtNotify fetches event notify of blocks from startHeight to endHeight by SyncWorkerSize workers concurrently,
+//and calls onEvtNotify with them in height order. At most SyncWorkerSize blocks are fetched ahead of onEvtNotify,
+//and fetching stops if onEvtNotify returns false.
+func (this *OntologyManager) fetchEvtNotify(startHeight, endHeight uint32, onEvtNotify func(evtNotify *EventNotify) bool) error {
+	workerSize := DefConfig.GetSyncWorkerSize()
+	pending := make([]chan *evtNotifyResult, 0, workerSize)
+	nextHeight := startHeight
+	for {
+		for nextHeight <= endHeight && len(pending) < int(workerSize) {
+			resultCh := make(chan *evtNotifyResult, 1)
+			go func(height uint32) {
+				evtNotify, err := this.getEvtNotify(height)
+				resultCh <- &evtNotifyResult{evtNotify: evtNotify, err: err}
+			}(nextHeight)
+			pending = append(pending, resultCh)
+			nextHeight++
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		result := <-pending[0]
+		pending = pending[1:]
+		if result.err != nil {
+			return result.err
+		}
+		if !onEvtNotify(result.evtNotify) {
+			return nil
+		}
+	}
+}
+
+func (this *OntologyManager) getEvtNotify(height uint32) (*EventNotify, error) {
+	evts, err := this.rpcMgr.GetSmartContractEventByBlock(height)
+	if err != nil {
+		return nil, fmt.Errorf("GetSmartContractEventByBlock height:%d error:%s", height, err)
+	}
+	blockTime := uint32(0)
+	if len(evts) > 0 {
+		blockTime, err = this.getBlockTime(height)
+		if err != nil {
+			return nil, fmt.Errorf("getBlockTime height:%d error:%s", height, err)
 		}
 	}
+	return &EventNotify{
+		BlockHeight:   height,
+		BlockTime:     blockTime,
+		EventNotifies: evts,
+	}, nil
 }
 
-func (this *OntologyManager) getTxTransferFromNotify(txEvt *sdkcom.SmartContactEvent) []*TxTransfer {
+//getTxTransferFromNotify decodes transfers and approvals of contracts from txEvt, by the TransferDecoder of contract.
+//Approvals are decoded only if the decoder is an ApprovalDecoder as well.
+func (this *OntologyManager) getTxTransferFromNotify(txEvt *sdkcom.SmartContactEvent, height, blockTime uint32, contracts map[string]bool) ([]*TxTransfer, []*Allowance) {
 	if len(txEvt.Notify) == 0 {
-		return nil
+		return nil, nil
 	}
 	txTransfers := make([]*TxTransfer, 0, 2)
-	for _, notify := range txEvt.Notify {
-		if !IsMonitorContract(notify.ContractAddress) {
+	approvals := make([]*Allowance, 0)
+	for index, notify := range txEvt.Notify {
+		if !contracts[notify.ContractAddress] {
 			continue
 		}
-		states, ok := notify.States.([]interface{})
-		if !ok {
+		decoder := GetTransferDecoder(notify.ContractAddress)
+		if decoder == nil {
 			continue
 		}
-		if len(states) != 4 {
-			continue
-		}
-		contractType := TypeOfContract(notify.ContractAddress)
-		var transferFrom, transferTo string
-		var transferAmount uint64
-		var name string
-		if contractType == ONT_ADDRESS || contractType == ONG_ADDRESS {
-			if states[0] != NOTIFY_TRANSFER {
-				continue
-			}
-			name = NOTIFY_TRANSFER
-			transferFrom, ok = states[1].(string)
-			if !ok {
-				continue
-			}
-			transferTo, ok = states[2].(string)
+		txTransfer, ok := decoder.Decode(notify)
+		if !ok {
+			approvalDecoder, ok := decoder.(ApprovalDecoder)
 			if !ok {
 				continue
 			}
-			fromHex,_ := common.AddressFromBase58(transferFrom)
-			transferFrom = fromHex.ToHexString()
-			toHex,_ := common.AddressFromBase58(transferTo)
-			transferTo = toHex.ToHexString()
-			transferAmount, ok = states[3].(uint64)
+			approval, ok := approvalDecoder.DecodeApproval(notify)
 			if !ok {
 				continue
 			}
-		} else {
-			if notify.ContractAddress != "6bbc07bae862db0d7867e4e5b1a13c663e2b4bc8" {
-				which, _ := hex.DecodeString(states[0].(string))
-				name = string(which)
-				if name != NOTIFY_TRANSFER {
-					continue
-				}
-				transferFrom, ok = states[1].(string)
-				if !ok {
-					continue
-				}
-				transferTo, ok = states[2].(string)
-				if !ok {
-					continue
-				}
-				amountBytes, _ := hex.DecodeString(states[3].(string))
-				if !ok {
-					continue
-				}
-				transferAmount = common.BigIntFromNeoBytes(amountBytes).Uint64()
-			} else {
-				which, _ := hex.DecodeString(states[0].(string))
-				name = string(which)
-				if name == NOTIFY_TRANSFER {
-					transferFrom, ok = states[1].(string)
-					if !ok {
-						continue
-					}
-					transferTo, ok = states[2].(string)
-					if !ok {
-						continue
-					}
-					amountBytes, _ := hex.DecodeString(states[3].(string))
-					if !ok {
-						continue
-					}
-					transferAmount = common.BigIntFromNeoBytes(amountBytes).Uint64()
-				} else if name == INCREASE_PAX {
-					transferFrom = "0000000000000000000000000000000000000000"
-					transferTo, ok = states[1].(string)
-					if !ok {
-						continue
-					}
-					amountBytes, _ := hex.DecodeString(states[2].(string))
-					if !ok {
-						continue
-					}
-					transferAmount = common.BigIntFromNeoBytes(amountBytes).Uint64()
-				} else if name == DECREASE_PAX {
-					transferFrom, ok = states[1].(string)
-					if !ok {
-						continue
-					}
-					transferTo = "0000000000000000000000000000000000000000"
-					amountBytes, _ := hex.DecodeString(states[3].(string))
-					if !ok {
-						continue
-					}
-					transferAmount = common.BigIntFromNeoBytes(amountBytes).Uint64()
-				}
-			}
+			approval.TxHash = txEvt.TxHash
+			approval.Height = height
+			approvals = append(approvals, approval)
+			continue
 		}
-		txTransfers = append(txTransfers, &TxTransfer{
-			TxHash:   txEvt.TxHash,
-			Name: name,
-			Contract: notify.ContractAddress,
-			From: transferFrom,
-			To: transferTo,
-			Amount:   transferAmount,
-		})
+		txTransfer.TxHash = txEvt.TxHash
+		txTransfer.Index = index
+		txTransfer.Height = height
+		txTransfer.Contract = notify.ContractAddress
+		txTransfer.Timestamp = blockTime
+		if DefConfig.NativeMode && IsNativeContract(txTransfer.Contract) {
+			txTransfer.Name = getNativeTransferName(txTransfer, index == len(txEvt.Notify)-1, txEvt.GasConsumed)
+		}
+		txTransfers = append(txTransfers, txTransfer)
 	}
-	return txTransfers
+	return txTransfers, approvals
+}
+
+//getAppliedTransfers returns the transfers and approvals of txEvt which are applied to holders.
+//The gas fee of a failed tx is always applied. The other transfers of a failed tx are excluded if ExcludeFailedTx is set,
+//and native transfers of it are excluded in native mode, since they are reverted by chain.
+func getAppliedTransfers(txEvt *sdkcom.SmartContactEvent, transfers []*TxTransfer, approvals []*Allowance) ([]*TxTransfer, []*Allowance) {
+	if txEvt.State != TX_STATE_FAILED || (!DefConfig.ExcludeFailedTx && !DefConfig.NativeMode) {
+		return transfers, approvals
+	}
+	if DefConfig.ExcludeFailedTx {
+		approvals = nil
+	}
+	appliedTransfers := make([]*TxTransfer, 0, len(transfers))
+	for _, transfer := range transfers {
+		if !isGasFeeTransfer(transfer) &&
+			(DefConfig.ExcludeFailedTx || (DefConfig.NativeMode && IsNativeContract(transfer.Contract))) {
+			continue
+		}
+		appliedTransfers = append(appliedTransfers, transfer)
+	}
+	if len(appliedTransfers) != len(transfers) {
+		log4.Info("Exclude %d transfers of failed tx:%s", len(transfers)-len(appliedTransfers), txEvt.TxHash)
+	}
+	return appliedTransfers, approvals
 }
 
 func (this *OntologyManager) handleEvtNotify() {
@@ -320,28 +433,44 @@ func (this *OntologyManager) handleEvtNotify() {
 	dbBatchTime := time.Duration(DefConfig.DBBatchTime) * time.Second
 	txEvtNotifies := make([]*TxEventNotify, 0, dbBatchSize)
 	txTransfers := make([]*TxTransfer, 0, dbBatchSize*2)
+	txApprovals := make([]*Allowance, 0)
+	//batchHeight is the last block height in batch, batch is always committed at block boundary
+	batchHeight := uint32(0)
+	//batchContracts is the contracts decoded in batch, sync checkpoint of them is moved when batch committed
+	batchContracts := make(map[string]bool)
+	isBatchDirty := false
 	notifyTimer := time.NewTimer(dbBatchTime)
 	for {
 		select {
 		case evtNotify := <-this.syncEvtNotifyChan:
 			ontEvtNotifies := evtNotify.EventNotifies
 			log4.Debug("current height: %d", evtNotify.BlockHeight)
+			contracts := DefContractMgr.BeginDecode(evtNotify.BlockHeight)
+			for contract := range contracts {
+				batchContracts[contract] = true
+			}
 			for _, ontEvt := range ontEvtNotifies {
-				transfers := this.getTxTransferFromNotify(ontEvt)
-				if len(transfers) == 0 {
+				transfers, approvals := this.getTxTransferFromNotify(ontEvt, evtNotify.BlockHeight, evtNotify.BlockTime, contracts)
+				if len(transfers) == 0 && len(approvals) == 0 {
 					continue
 				}
-				txTransfers = append(txTransfers, transfers...)
 
 				transferEvts := make([][]interface{}, 0, 2)
 				for _, transfer := range transfers {
-					transferEvts = append(transferEvts, []interface{}{transfer.Name, transfer.From, transfer.To, transfer.Amount})
+					transferEvts = append(transferEvts, []interface{}{transfer.Name, transfer.From, transfer.To, transfer.Amount.String()})
+				}
+				for _, approval := range approvals {
+					transferEvts = append(transferEvts, []interface{}{NOTIFY_APPROVAL, approval.Owner, approval.Spender, approval.Amount.String()})
 				}
 				notifyJson, err := json.Marshal(transferEvts)
 				if err != nil {
 					log4.Error("handleEvtNotify json.Marshal notify error:%s", err)
 					continue
 				}
+				//Events of failed tx are kept in notify for audit, even if they are not applied
+				transfers, approvals = getAppliedTransfers(ontEvt, transfers, approvals)
+				txTransfers = append(txTransfers, transfers...)
+				txApprovals = append(txApprovals, approvals...)
 				txEvtNotify := &TxEventNotify{
 					TxHash:      ontEvt.TxHash,
 					Height:      evtNotify.BlockHeight,
@@ -351,19 +480,27 @@ func (this *OntologyManager) handleEvtNotify() {
 				}
 				txEvtNotifies = append(txEvtNotifies, txEvtNotify)
 				log4.Info("EventNotify:%+v", txEvtNotify)
+			}
+			batchHeight = evtNotify.BlockHeight
+			isBatchDirty = true
 
-				if len(txTransfers) >= int(dbBatchSize) {
-					this.retryOnTransfer(txEvtNotifies, txTransfers)
-					txEvtNotifies = make([]*TxEventNotify, 0, dbBatchSize)
-					txTransfers = make([]*TxTransfer, 0, dbBatchSize*2)
-					notifyTimer.Reset(dbBatchTime)
-				}
+			if len(txTransfers)+len(txApprovals) >= int(dbBatchSize) {
+				this.retryOnTransfer(txEvtNotifies, txTransfers, txApprovals, batchContracts, batchHeight)
+				txEvtNotifies = make([]*TxEventNotify, 0, dbBatchSize)
+				txTransfers = make([]*TxTransfer, 0, dbBatchSize*2)
+				txApprovals = make([]*Allowance, 0)
+				batchContracts = make(map[string]bool)
+				isBatchDirty = false
+				notifyTimer.Reset(dbBatchTime)
 			}
 		case <-notifyTimer.C:
-			if len(txEvtNotifies) > 0 {
-				this.retryOnTransfer(txEvtNotifies, txTransfers)
+			if isBatchDirty {
+				this.retryOnTransfer(txEvtNotifies, txTransfers, txApprovals, batchContracts, batchHeight)
 				txEvtNotifies = make([]*TxEventNotify, 0, dbBatchSize)
 				txTransfers = make([]*TxTransfer, 0, dbBatchSize*2)
+				txApprovals = make([]*Allowance, 0)
+				batchContracts = make(map[string]bool)
+				isBatchDirty = false
 			}
 			notifyTimer.Reset(dbBatchTime)
 		case <-this.exitCh:
@@ -372,9 +509,9 @@ func (this *OntologyManager) handleEvtNotify() {
 	}
 }
 
-func (this *OntologyManager) retryOnTransfer(txNotifies []*TxEventNotify, txTransfers []*TxTransfer) {
+func (this *OntologyManager) retryOnTransfer(txNotifies []*TxEventNotify, txTransfers []*TxTransfer, txApprovals []*Allowance, contracts map[string]bool, syncedHeight uint32) {
 	for {
-		err := this.onTransfer(txNotifies, txTransfers)
+		err := this.onTransfer(txNotifies, txTransfers, txApprovals, contracts, syncedHeight)
 		if err == nil {
 			return
 		}
@@ -387,20 +524,24 @@ func (this *OntologyManager) retryOnTransfer(txNotifies []*TxEventNotify, txTran
 	}
 }
 
-func (this *OntologyManager) onTransfer(txNotifies []*TxEventNotify, txTransfers []*TxTransfer) error {
+//onTransfer applies transfers of blocks up to syncedHeight to holders, and approvals to allowances,
+//and moves the sync checkpoint of contracts to syncedHeight.
+func (this *OntologyManager) onTransfer(txNotifies []*TxEventNotify, txTransfers []*TxTransfer, txApprovals []*Allowance, contracts map[string]bool, syncedHeight uint32) error {
 	if this.GetCurrentNodeId() != NodeId {
 		return nil
 	}
+	this.holderLock.Lock()
+	defer this.holderLock.Unlock()
 	txNotifySize := len(txNotifies)
 	if txNotifySize == 0 {
-		return nil
+		return this.saveTxEventNotify(nil, nil, nil, nil, contracts, syncedHeight)
 	}
 	txHashes := make([]string, 0, txNotifySize)
 	for _, txNotify := range txNotifies {
 		txHashes = append(txHashes, txNotify.TxHash)
 	}
 
-	isExists, err := this.mysqlHelper.IsEventNotifyExist(txHashes)
+	isExists, err := this.store.IsEventNotifyExist(txHashes)
 	if err != nil {
 		return fmt.Errorf("IsEventNotifyExist error:%s", err)
 	}
@@ -408,7 +549,7 @@ func (this *OntologyManager) onTransfer(txNotifies []*TxEventNotify, txTransfers
 	isExistSize := len(isExists)
 	if isExistSize == txNotifySize {
 		//All of them has already processed
-		return nil
+		return this.saveTxEventNotify(nil, nil, nil, nil, contracts, syncedHeight)
 	}
 	if isExistSize > 0 {
 		size := txNotifySize - isExistSize
@@ -428,82 +569,116 @@ func (this *OntologyManager) onTransfer(txNotifies []*TxEventNotify, txTransfers
 			}
 			newTxTransfers = append(newTxTransfers, txTransfer)
 		}
+		newTxApprovals := make([]*Allowance, 0, len(txApprovals))
+		for _, txApproval := range txApprovals {
+			_, ok := isExists[txApproval.TxHash]
+			if ok {
+				continue
+			}
+			newTxApprovals = append(newTxApprovals, txApproval)
+		}
 		txNotifies = newTxNotifies
 		txTransfers = newTxTransfers
+		txApprovals = newTxApprovals
+	}
+	assetHolders, err := this.applyTransfers(txTransfers)
+	if err != nil {
+		return err
 	}
-	assetHolderKeyMap := make(map[string]bool, txNotifySize)
-	assetHolders := make([]*AssetHolder, 0, txNotifySize)
+	err = this.saveTxEventNotify(txNotifies, assetHolders, txTransfers, txApprovals, contracts, syncedHeight)
+	if err != nil {
+		return err
+	}
+	this.addCheckHolders(getInvalidTransferSenders(txTransfers))
+	if DefConfig.NativeMode {
+		this.addCheckHolders(getNativeCheckHolders(txTransfers))
+	}
+	return nil
+}
+
+//applyTransfers applies txTransfers to holders saved in store, and returns the changed holders.
+//A transfer whose sender has a lower indexed balance than its amount is marked IsInvalid, only its receiver is credited.
+func (this *OntologyManager) applyTransfers(txTransfers []*TxTransfer) ([]*AssetHolder, error) {
+	assetHolderKeyMap := make(map[string]bool, len(txTransfers))
+	assetHolders := make([]*AssetHolder, 0, len(txTransfers))
 	for _, txTransfer := range txTransfers {
 		var key string
+		tokenId := holderTokenId(txTransfer)
 		if txTransfer.From != "0000000000000000000000000000000000000000" {
-			key = txTransfer.From + txTransfer.Contract
+			key = txTransfer.From + txTransfer.Contract + tokenId
 			_, ok := assetHolderKeyMap[key]
 			if !ok {
 				assetHolderKeyMap[key] = true
 				assetHolders = append(assetHolders, &AssetHolder{
 					Address:  txTransfer.From,
 					Contract: txTransfer.Contract,
+					TokenId:  tokenId,
 				})
 			}
 		}
 		if txTransfer.To != "0000000000000000000000000000000000000000" {
-			key = txTransfer.To + txTransfer.Contract
+			key = txTransfer.To + txTransfer.Contract + tokenId
 			_, ok := assetHolderKeyMap[key]
 			if !ok {
 				assetHolderKeyMap[key] = true
 				assetHolders = append(assetHolders, &AssetHolder{
 					Address:  txTransfer.To,
 					Contract: txTransfer.Contract,
+					TokenId:  tokenId,
 				})
 			}
 		}
 	}
-	assetHolderMap, err := this.mysqlHelper.GetAssetHolderByKey(assetHolders)
+	assetHolderMap, err := this.store.GetAssetHolderByKey(assetHolders)
 	if err != nil {
-		return fmt.Errorf("GetAssetHolderByKey error:%s", err)
+		return nil, fmt.Errorf("GetAssetHolderByKey error:%s", err)
 	}
 
 	txMap := make(map[string]bool, len(txTransfers))
 	for _, txTransfer := range txTransfers {
 		var key string
+		tokenId := holderTokenId(txTransfer)
 		if txTransfer.From != "0000000000000000000000000000000000000000" {
-			key = txTransfer.From + txTransfer.Contract
+			key = txTransfer.From + txTransfer.Contract + tokenId
 			assetHolder, ok := assetHolderMap[key]
-			if !ok || assetHolder.Balance < txTransfer.Amount {
-				err = fmt.Errorf("invalid transfer, Contact:%s TxHash:%s From:%s To:%s Amount:%d", txTransfer.Contract, txTransfer.TxHash, txTransfer.From, txTransfer.To, txTransfer.Amount)
-				log4.Error(err)
-				//time.Sleep(time.Second) //wait to log
-				//panic(err)
+			if !ok || assetHolder.Balance.Cmp(txTransfer.Amount) < 0 {
+				txTransfer.IsInvalid = true
+				if isNativePoolTransfer(txTransfer) {
+					log4.Warn("native pool short of transfer, Contact:%s TxHash:%s From:%s To:%s Amount:%s", txTransfer.Contract, txTransfer.TxHash, txTransfer.From, txTransfer.To, txTransfer.Amount)
+				} else {
+					log4.Error("invalid transfer, Contact:%s TxHash:%s From:%s To:%s Amount:%s", txTransfer.Contract, txTransfer.TxHash, txTransfer.From, txTransfer.To, txTransfer.Amount)
+				}
 			} else {
-				txKey := txTransfer.From + txTransfer.TxHash
+				txKey := key + txTransfer.TxHash
 				_, ok := txMap[txKey]
 				if !ok {
 					txMap[txKey] = true
 					assetHolder.Transactions ++
 				}
-				assetHolder.Balance -= txTransfer.Amount
+				assetHolder.Balance = new(big.Int).Sub(assetHolder.Balance, txTransfer.Amount)
 				assetHolderMap[key] = assetHolder
 			}
 		}
 
 		if txTransfer.To != "0000000000000000000000000000000000000000" {
-			key = txTransfer.To + txTransfer.Contract
+			key = txTransfer.To + txTransfer.Contract + tokenId
 			assetHolder, ok := assetHolderMap[key]
 			if !ok {
 				assetHolder = &AssetHolder{
 					Contract: txTransfer.Contract,
+					TokenId:  tokenId,
 					Address:  txTransfer.To,
-					Balance:  0,
+					Balance:  new(big.Int),
 					Transactions: 0,
 				}
 			}
-			txKey := txTransfer.To + txTransfer.TxHash
+			txKey := key + txTransfer.TxHash
 			_, ok = txMap[txKey]
 			if !ok {
 				txMap[txKey] = true
 				assetHolder.Transactions ++
 			}
-			assetHolder.Balance += txTransfer.Amount
+			assetHolder.Balance = new(big.Int).Add(assetHolder.Balance, txTransfer.Amount)
 			assetHolderMap[key] = assetHolder
 		}
 	}
@@ -513,19 +688,214 @@ func (this *OntologyManager) onTransfer(txNotifies []*TxEventNotify, txTransfers
 		assetHolders = append(assetHolders, assHolder)
 	}
 
-	err = this.mysqlHelper.OnTxEventNotify(txNotifies, assetHolders)
+	return assetHolders, nil
+}
+
+//getTokenOwners returns the last owner of OEP-5 tokens transferred in txTransfers
+func getTokenOwners(txTransfers []*TxTransfer) []*TokenOwner {
+	tokenOwnerMap := make(map[string]*TokenOwner)
+	tokenOwners := make([]*TokenOwner, 0)
+	for _, txTransfer := range txTransfers {
+		if txTransfer.TokenId == "" || !IsNFTContract(txTransfer.Contract) {
+			continue
+		}
+		key := txTransfer.Contract + txTransfer.TokenId
+		tokenOwner, ok := tokenOwnerMap[key]
+		if !ok {
+			tokenOwner = &TokenOwner{
+				Contract: txTransfer.Contract,
+				TokenId:  txTransfer.TokenId,
+			}
+			tokenOwnerMap[key] = tokenOwner
+			tokenOwners = append(tokenOwners, tokenOwner)
+		}
+		tokenOwner.Owner = txTransfer.To
+		tokenOwner.Height = txTransfer.Height
+	}
+	return tokenOwners
+}
+
+//getAllowances returns the last allowance of owner and spender approved in txApprovals
+func getAllowances(txApprovals []*Allowance) []*Allowance {
+	allowanceMap := make(map[string]int)
+	allowances := make([]*Allowance, 0, len(txApprovals))
+	for _, txApproval := range txApprovals {
+		key := txApproval.Contract + txApproval.Owner + txApproval.Spender
+		index, ok := allowanceMap[key]
+		if ok {
+			allowances[index] = txApproval
+			continue
+		}
+		allowanceMap[key] = len(allowances)
+		allowances = append(allowances, txApproval)
+	}
+	return allowances
+}
+
+func (this *OntologyManager) saveTxEventNotify(txNotifies []*TxEventNotify, assetHolders []*AssetHolder, txTransfers []*TxTransfer, txApprovals []*Allowance, contracts map[string]bool, syncedHeight uint32) error {
+	err := this.store.OnTxEventNotify(txNotifies, assetHolders, txTransfers, getTokenOwners(txTransfers), getAllowances(txApprovals), this.newSyncStates(contracts, syncedHeight))
 	if err != nil {
 		return fmt.Errorf("OnTxEventNotify error:%s", err)
 	}
+	this.SetCommittedBlockHeight(syncedHeight)
 	return nil
 }
 
+func (this *OntologyManager) newSyncStates(contracts map[string]bool, height uint32) []*SyncState {
+	syncStates := make([]*SyncState, 0, len(contracts))
+	for contract := range contracts {
+		syncStates = append(syncStates, &SyncState{
+			Contract: contract,
+			Height:   height,
+		})
+	}
+	return syncStates
+}
+
 func (this *OntologyManager) GetOntSdk() *ontsdk.OntologySdk {
-	return this.ontSdk
+	return this.rpcMgr.GetOntSdk()
+}
+
+func (this *OntologyManager) GetAssetHolder(from, count int, cursor *HolderCursor, address, contract, tokenId string) ([]*AssetHolder, error) {
+	return this.store.GetAssetHolder(from, count, cursor, address, contract, tokenId)
+}
+
+func (this *OntologyManager) GetBalances(addresses, contracts []string) ([]*AssetHolder, error) {
+	return this.store.GetBalances(addresses, contracts)
+}
+
+func (this *OntologyManager) GetTransferHistory(from, count int, address, contract string, startHeight, endHeight uint32) ([]*TxTransfer, error) {
+	return this.store.GetTransferHistory(from, count, address, contract, startHeight, endHeight)
+}
+
+//GetTransferHistoryOfAllContracts returns transfers of a hex or base58 address in all contracts. A base58 address is saved
+//in two hex forms by different contracts, so the transfers of both are merged and paged in the order of transfer history.
+func (this *OntologyManager) GetTransferHistoryOfAllContracts(from, count int, address string, startHeight, endHeight uint32) ([]*TxTransfer, error) {
+	hexAddresses, err := ResolveAddressOfAllContracts(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(hexAddresses) == 1 {
+		return this.store.GetTransferHistory(from, count, hexAddresses[0], "", startHeight, endHeight)
+	}
+	transferMap := make(map[string]bool)
+	transfers := make([]*TxTransfer, 0)
+	for _, hexAddress := range hexAddresses {
+		//transfers of another account saved in the same hex form are skipped, so pages are read until from+count match
+		matched := 0
+		for offset := 0; matched < from+count; offset += from + count {
+			items, err := this.store.GetTransferHistory(offset, from+count, hexAddress, "", startHeight, endHeight)
+			if err != nil {
+				return nil, err
+			}
+			for _, transfer := range items {
+				if !IsContractAddressOf(transfer.From, transfer.Contract, address) &&
+					!IsContractAddressOf(transfer.To, transfer.Contract, address) {
+					continue
+				}
+				matched++
+				key := fmt.Sprintf("%s%d", transfer.TxHash, transfer.Index)
+				if !transferMap[key] {
+					transferMap[key] = true
+					transfers = append(transfers, transfer)
+				}
+			}
+			if len(items) < from+count {
+				break
+			}
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return IsTransferBefore(transfers[i], transfers[j])
+	})
+	if from >= len(transfers) {
+		return make([]*TxTransfer, 0), nil
+	}
+	if from+count < len(transfers) {
+		transfers = transfers[:from+count]
+	}
+	return transfers[from:], nil
+}
+
+func (this *OntologyManager) GetTokenOwner(contract, tokenId string) (*TokenOwner, error) {
+	return this.store.GetTokenOwner(contract, tokenId)
 }
 
-func (this *OntologyManager) GetAssetHolder(from, count int, address, contract string) ([]*AssetHolder, error) {
-	return this.mysqlHelper.GetAssetHolder(from, count, address, contract)
+func (this *OntologyManager) GetTokensOfOwner(from, count int, contract, owner string) ([]*TokenOwner, error) {
+	return this.store.GetTokensOfOwner(from, count, contract, owner)
+}
+
+func (this *OntologyManager) GetAllowance(contract, owner, spender string) (*Allowance, error) {
+	return this.store.GetAllowance(contract, owner, spender)
+}
+
+func (this *OntologyManager) GetAllowancesByOwner(from, count int, owner, contract string) ([]*Allowance, error) {
+	return this.store.GetAllowancesByOwner(from, count, owner, contract)
+}
+
+//GetAllowancesByOwnerOfAllContracts returns allowances approved by a hex or base58 owner in all contracts,
+//allowances of both hex forms of a base58 owner are merged and paged by contract and spender.
+func (this *OntologyManager) GetAllowancesByOwnerOfAllContracts(from, count int, owner string) ([]*Allowance, error) {
+	hexOwners, err := ResolveAddressOfAllContracts(owner)
+	if err != nil {
+		return nil, err
+	}
+	if len(hexOwners) == 1 {
+		return this.store.GetAllowancesByOwner(from, count, hexOwners[0], "")
+	}
+	allowances := make([]*Allowance, 0)
+	for _, hexOwner := range hexOwners {
+		matched := 0
+		for offset := 0; matched < from+count; offset += from + count {
+			items, err := this.store.GetAllowancesByOwner(offset, from+count, hexOwner, "")
+			if err != nil {
+				return nil, err
+			}
+			for _, allowance := range items {
+				if IsContractAddressOf(allowance.Owner, allowance.Contract, owner) {
+					matched++
+					allowances = append(allowances, allowance)
+				}
+			}
+			if len(items) < from+count {
+				break
+			}
+		}
+	}
+	sort.Slice(allowances, func(i, j int) bool {
+		if allowances[i].Contract != allowances[j].Contract {
+			return allowances[i].Contract < allowances[j].Contract
+		}
+		return allowances[i].Spender < allowances[j].Spender
+	})
+	if from >= len(allowances) {
+		return make([]*Allowance, 0), nil
+	}
+	if from+count < len(allowances) {
+		allowances = allowances[:from+count]
+	}
+	return allowances[from:], nil
+}
+
+//GetAssetHolderAtHeight pages the holder snapshot of contract at height, which is saved by the first page.
+//Sync is blocked only while the current holders are copied for the snapshot.
+func (this *OntologyManager) GetAssetHolderAtHeight(from, count int, cursor *HolderCursor, contract, tokenId string, height uint32) ([]*AssetHolder, error) {
+	this.snapshotLock.Lock()
+	err := buildHolderSnapshot(this.store, contract, tokenId, height, &this.holderLock)
+	this.snapshotLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return this.store.GetAssetHolderAtHeight(from, count, cursor, contract, tokenId, height)
+}
+
+//GetSnapshotPageHeight returns the height of a new snapshot page of contract synced to height, see getReusableSnapshotHeight
+func (this *OntologyManager) GetSnapshotPageHeight(contract, tokenId string, height uint32) (uint32, error) {
+	return getReusableSnapshotHeight(this.store, contract, tokenId, height)
+}
+
+func (this *OntologyManager) CheckSnapshotHeight(contract string, height uint32) (string, error) {
+	return checkSnapshotHeight(this.store, contract, height)
 }
 
 func (this *OntologyManager) GetSyncedEvtNotifyBlockHeight() uint32 {
@@ -536,8 +906,17 @@ func (this *OntologyManager) SetSyncedEvtNotifyBlockHeight(height uint32) {
 	atomic.StoreUint32(&this.syncedEvtNotifyBlockHeight, height)
 }
 
+//GetCommittedBlockHeight returns the last block height which has been fully committed to store.
+func (this *OntologyManager) GetCommittedBlockHeight() uint32 {
+	return atomic.LoadUint32(&this.committedBlockHeight)
+}
+
+func (this *OntologyManager) SetCommittedBlockHeight(height uint32) {
+	atomic.StoreUint32(&this.committedBlockHeight, height)
+}
+
 func (this *OntologyManager) initHeartbeat() error {
-	heartbeat, err := this.mysqlHelper.GetHeartbeat(HEARTBEAT_MODULE)
+	heartbeat, err := this.store.GetHeartbeat(HEARTBEAT_MODULE)
 	if err != nil {
 		return fmt.Errorf("GetHeartbeat error:%s", err)
 	}
@@ -546,7 +925,7 @@ func (this *OntologyManager) initHeartbeat() error {
 			Module: HEARTBEAT_MODULE,
 			NodeId: NodeId,
 		}
-		err = this.mysqlHelper.InsertHeartbeat(heartbeat)
+		err = this.store.InsertHeartbeat(heartbeat)
 		if err != nil {
 			return fmt.Errorf("InsertHeartbeat error:%s", err)
 		}
@@ -575,7 +954,7 @@ func (this *OntologyManager) startHeartbeat() {
 
 func (this *OntologyManager) heartbeat() error {
 	if this.GetCurrentNodeId() == NodeId {
-		ok, err := this.mysqlHelper.UpdateHeartbeat(HEARTBEAT_MODULE, NodeId)
+		ok, err := this.store.UpdateHeartbeat(HEARTBEAT_MODULE, NodeId)
 		if err != nil {
 			return fmt.Errorf("UpdateHeartbeat error:%s", err)
 		}
@@ -584,7 +963,7 @@ func (this *OntologyManager) heartbeat() error {
 			return nil
 		}
 		//Node was been switched from current node.
-		heartbeat, err := this.mysqlHelper.GetHeartbeat(HEARTBEAT_MODULE)
+		heartbeat, err := this.store.GetHeartbeat(HEARTBEAT_MODULE)
 		if err != nil || heartbeat == nil {
 			return fmt.Errorf("GetHeartbeat error:%s", err)
 		}
@@ -592,7 +971,7 @@ func (this *OntologyManager) heartbeat() error {
 		log4.Info("Current node: %d switch to:%d", NodeId, heartbeat.NodeId)
 		return nil
 	} else {
-		lastNodeId, err := this.mysqlHelper.CheckHeartbeatTimeout(HEARTBEAT_MODULE, DefConfig.GetHeartbeatTimeoutTime())
+		lastNodeId, err := this.store.CheckHeartbeatTimeout(HEARTBEAT_MODULE, DefConfig.GetHeartbeatTimeoutTime())
 		if err != nil {
 			return fmt.Errorf("OntologyManager CheckHeartbeatTimeout error:%s", err)
 		}
@@ -602,7 +981,7 @@ func (this *OntologyManager) heartbeat() error {
 		}
 		log4.Info("Current node:%d heartbeat timeout", lastNodeId)
 		//heartbeat timeout
-		ok, err := this.mysqlHelper.ResetHeartbeat(HEARTBEAT_MODULE, NodeId, lastNodeId)
+		ok, err := this.store.ResetHeartbeat(HEARTBEAT_MODULE, NodeId, lastNodeId)
 		if err != nil {
 			return fmt.Errorf("OntologyManager ResetHeartbeat error:%s", err)
 		}
@@ -630,6 +1009,12 @@ func (this *OntologyManager) startUpdateInfo() {
 				if err != nil {
 					log4.Error("updateSyncedEvtNotifyBlockHeight error:%s", err)
 				}
+			} else {
+				_, err := this.updateContracts(false)
+				if err != nil {
+					log4.Error("updateContracts error:%s", err)
+				}
+				this.startBackfill()
 			}
 			syncedHeightUpdateTimer.Reset(syncedBlockTime)
 		case <-holderCountUpdateTimer.C:
@@ -645,22 +1030,76 @@ func (this *OntologyManager) startUpdateInfo() {
 }
 
 func (this *OntologyManager) updateSyncedEvtNotifyBlockHeight() error {
-	syncedBlockHeight, err := this.mysqlHelper.GetSyncedEventNotifyBlockHeight()
+	syncedBlockHeight, err := this.updateContracts(this.GetCurrentNodeId() != NodeId)
 	if err != nil {
-		return fmt.Errorf("GetSyncedEventNotifyBlockHeight error:%s", err)
-	}
-	if syncedBlockHeight > 0 {
-		//Insure all of the block transactions has already inserted to db
-		syncedBlockHeight--
-	}
-	if DefConfig.BlockHeight > syncedBlockHeight {
-		syncedBlockHeight = DefConfig.BlockHeight
+		return err
 	}
+	DefContractMgr.SetDecodedHeight(syncedBlockHeight)
 	this.SetSyncedEvtNotifyBlockHeight(syncedBlockHeight)
+	this.SetCommittedBlockHeight(syncedBlockHeight)
 	log4.Info("CurrentSyncedBlockHeight:%d", syncedBlockHeight)
 	return nil
 }
 
+//updateContracts reloads monitored contracts from store, and returns the checkpoint of live sync,
+//which is the highest sync checkpoint of monitored contracts.
+func (this *OntologyManager) updateContracts(isRefresh bool) (uint32, error) {
+	contracts, err := this.store.GetContracts()
+	if err != nil {
+		return 0, fmt.Errorf("GetContracts error:%s", err)
+	}
+	syncStates, err := this.store.GetSyncStates()
+	if err != nil {
+		return 0, fmt.Errorf("GetSyncStates error:%s", err)
+	}
+	syncStateMap := make(map[string]uint32, len(syncStates))
+	for _, syncState := range syncStates {
+		syncStateMap[syncState.Contract] = syncState.Height
+	}
+	liveHeight := DefConfig.BlockHeight
+	for _, contract := range contracts {
+		if contract.State != CONTRACT_STATE_ACTIVE {
+			continue
+		}
+		height, ok := syncStateMap[contract.Contract]
+		if ok && height > liveHeight {
+			liveHeight = height
+		}
+	}
+	DefContractMgr.Update(contracts, syncStateMap, liveHeight, isRefresh)
+	return liveHeight, nil
+}
+
+//AddContract registers contract to monitor, and starts backfill of it if current node is the master.
+func (this *OntologyManager) AddContract(contract string, startHeight uint32) error {
+	err := addMonitorContract(this.store, contract, startHeight)
+	if err != nil {
+		return err
+	}
+	return this.reloadContracts()
+}
+
+func (this *OntologyManager) RemoveContract(contract string) error {
+	err := removeMonitorContract(this.store, contract)
+	if err != nil {
+		return err
+	}
+	return this.reloadContracts()
+}
+
+func (this *OntologyManager) reloadContracts() error {
+	if this.GetCurrentNodeId() != NodeId {
+		//Standby node reloads contracts with synced height
+		return nil
+	}
+	_, err := this.updateContracts(false)
+	if err != nil {
+		return err
+	}
+	this.startBackfill()
+	return nil
+}
+
 func (this *OntologyManager) GetCurrentNodeId() uint32 {
 	this.lock.RLock()
 	defer this.lock.RUnlock()
@@ -674,7 +1113,7 @@ func (this *OntologyManager) SetCurrentNodeId(nodeId uint32) {
 }
 
 func (this *OntologyManager) updateAssetHolderCounts() error {
-	counts, err := this.mysqlHelper.GetAssetHolderCounts()
+	counts, err := this.store.GetAssetHolderCounts()
 	if err != nil {
 		return fmt.Errorf("GetAssetHolderCounts error:%s", err)
 	}
@@ -692,13 +1131,23 @@ func (this *OntologyManager) setAssetHolderCount(counts map[string]int) {
 	this.holderCounts = counts
 }
 
-func (this *OntologyManager) GetAssetHolderCount(contract string) int {
+func (this *OntologyManager) GetAssetHolderCount(contract, tokenId string) int {
 	this.lock.RLock()
 	defer this.lock.RUnlock()
 	if this.holderCounts == nil {
 		return 0
 	}
-	return this.holderCounts[contract]
+	return this.holderCounts[contract+tokenId]
+}
+
+func (this *OntologyManager) getTotalHolderCount() int {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	total := 0
+	for _, count := range this.holderCounts {
+		total += count
+	}
+	return total
 }
 
 func (this *OntologyManager) Close() {
@@ -706,13 +1155,15 @@ func (this *OntologyManager) Close() {
 }
 
 func (this *OntologyManager) OEP4Name(contract string) (string, error) {
+	if IsWasmContract(contract) {
+		return this.wasmOEP4String(contract, "name")
+	}
 	contractAddr, err := common.AddressFromHexString(contract)
 	if err != nil {
-		fmt.Printf("error is %+v\n", err)
 		return "", err
 	}
 
-	preResult, err := this.ontSdk.NeoVM.PreExecInvokeNeoVMContract(contractAddr,
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr,
 		[]interface{}{"name", []interface{}{}})
 	if err != nil {
 		return "", err
@@ -722,13 +1173,15 @@ func (this *OntologyManager) OEP4Name(contract string) (string, error) {
 }
 
 func (this *OntologyManager) OEP4Symbol(contract string) (string, error) {
+	if IsWasmContract(contract) {
+		return this.wasmOEP4String(contract, "symbol")
+	}
 	contractAddr, err := common.AddressFromHexString(contract)
 	if err != nil {
-		fmt.Printf("error is %+v\n", err)
 		return "", err
 	}
 
-	preResult, err := this.ontSdk.NeoVM.PreExecInvokeNeoVMContract(contractAddr,
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr,
 		[]interface{}{"symbol", []interface{}{}})
 	if err != nil {
 		return "", err
@@ -738,35 +1191,82 @@ func (this *OntologyManager) OEP4Symbol(contract string) (string, error) {
 }
 
 func (this *OntologyManager) OEP4Decimals(contract string) (byte, error){
+	if IsWasmContract(contract) {
+		decimals, err := this.wasmOEP4Integer(contract, "decimals")
+		if err != nil {
+			return 0, err
+		}
+		return byte(decimals.Uint64()), nil
+	}
 	contractAddr, err := common.AddressFromHexString(contract)
 	if err != nil {
-		fmt.Printf("error is %+v\n", err)
 		return 0, err
 	}
 
-	preResult, err := this.ontSdk.NeoVM.PreExecInvokeNeoVMContract(contractAddr,
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr,
 		[]interface{}{"decimals", []interface{}{}})
 	if err != nil {
-		fmt.Printf("error is %+v\n", err)
 		return 0, err
 	}
 	decimal, _ := preResult.Result.ToInteger()
 	return byte(decimal.Uint64()), nil
 }
 
-func (this *OntologyManager) OEP4Supply(contract string) (uint64, error){
+//OEP8Supply returns total supply of tokenId of OEP-8 contract, tokenId is hex encoded
+func (this *OntologyManager) OEP8Supply(contract, tokenId string) (*big.Int, error) {
 	contractAddr, err := common.AddressFromHexString(contract)
 	if err != nil {
-		fmt.Printf("error is %+v\n", err)
-		return 0, err
+		return nil, err
+	}
+	tokenIdBytes, err := hex.DecodeString(tokenId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token id:%s", tokenId)
 	}
 
-	preResult, err := this.ontSdk.NeoVM.PreExecInvokeNeoVMContract(contractAddr,
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr,
+		[]interface{}{"totalSupply", []interface{}{tokenIdBytes}})
+	if err != nil {
+		return nil, err
+	}
+	return preResult.Result.ToInteger()
+}
+
+//OEP8Symbol returns symbol of tokenId of OEP-8 contract, tokenId is hex encoded
+func (this *OntologyManager) OEP8Symbol(contract, tokenId string) (string, error) {
+	contractAddr, err := common.AddressFromHexString(contract)
+	if err != nil {
+		return "", err
+	}
+	tokenIdBytes, err := hex.DecodeString(tokenId)
+	if err != nil {
+		return "", fmt.Errorf("invalid token id:%s", tokenId)
+	}
+
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr,
+		[]interface{}{"symbol", []interface{}{tokenIdBytes}})
+	if err != nil {
+		return "", err
+	}
+	return preResult.Result.ToString()
+}
+
+func (this *OntologyManager) OEP4Supply(contract string) (*big.Int, error){
+	if IsWasmContract(contract) {
+		return this.wasmOEP4Integer(contract, "totalSupply")
+	}
+	contractAddr, err := common.AddressFromHexString(contract)
+	if err != nil {
+		return nil, err
+	}
+
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr,
 		[]interface{}{"totalSupply", []interface{}{}})
 	if err != nil {
-		fmt.Printf("error is %+v\n", err)
-		return 0, err
+		return nil, err
+	}
+	supply, err := preResult.Result.ToInteger()
+	if err != nil {
+		return nil, err
 	}
-	supply, _ := preResult.Result.ToInteger()
-	return supply.Uint64(), nil
+	return supply, nil
 }
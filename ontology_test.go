@@ -0,0 +1,357 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	sdkcom "github.com/ontio/ontology-go-sdk/common"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+//testTokenIndexer indexes notifies of a contract by OntologyManager, one tx per block
+type testTokenIndexer struct {
+	t        *testing.T
+	mgr      *OntologyManager
+	contract string
+	height   uint32
+}
+
+func newTestTokenIndexer(t *testing.T, contract, decoder string) *testTokenIndexer {
+	contractDecoders := DefConfig.ContractDecoders
+	t.Cleanup(func() {
+		DefConfig.ContractDecoders = contractDecoders
+	})
+	DefConfig.ContractDecoders = map[string]string{contract: decoder}
+	store := newTestLevelDBHelper(t)
+	err := store.SaveContract(&MonitorContract{Contract: contract, State: CONTRACT_STATE_ACTIVE})
+	if err != nil {
+		t.Fatalf("SaveContract error:%s", err)
+	}
+	mgr := NewOntologyManager(nil, store)
+	mgr.hb = &Heartbeat{NodeId: NodeId}
+	return &testTokenIndexer{t: t, mgr: mgr, contract: contract}
+}
+
+//index indexes a tx of notifies with states in a new block, and returns its height
+func (this *testTokenIndexer) index(states ...[]interface{}) uint32 {
+	this.height++
+	evt := &sdkcom.SmartContactEvent{TxHash: fmt.Sprintf("%064x", this.height), State: TX_STATE_SUCCESS}
+	for _, state := range states {
+		evt.Notify = append(evt.Notify, &sdkcom.NotifyEventInfo{ContractAddress: this.contract, States: state})
+	}
+	contracts := map[string]bool{this.contract: true}
+	transfers, approvals := this.mgr.getTxTransferFromNotify(evt, this.height, this.height, contracts)
+	txNotifies := []*TxEventNotify{{TxHash: evt.TxHash, Height: this.height, State: TX_STATE_SUCCESS}}
+	err := this.mgr.onTransfer(txNotifies, transfers, approvals, contracts, this.height)
+	if err != nil {
+		this.t.Fatalf("onTransfer error:%s", err)
+	}
+	return this.height
+}
+
+func (this *testTokenIndexer) checkBalance(name, address, tokenId string, balance int64) {
+	holders, err := this.mgr.store.GetAssetHolder(0, 0, nil, address, this.contract, tokenId)
+	if err != nil {
+		this.t.Fatalf("GetAssetHolder error:%s", err)
+	}
+	actual := int64(0)
+	if len(holders) > 0 {
+		actual = holders[0].Balance.Int64()
+	}
+	if actual != balance {
+		this.t.Errorf("%s: balance of %s token_id:%s is %d, expect %d", name, address, tokenId, actual, balance)
+	}
+}
+
+func TestIndexOep5TokenOwners(t *testing.T) {
+	indexer := newTestTokenIndexer(t, TEST_CONTRACT, DECODER_OEP5)
+	mintHeight := indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, "", TEST_ADDRESS_A, "01"},
+		[]interface{}{TEST_NOTIFY_TRANSFER, "", TEST_ADDRESS_A, "02"})
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "01"})
+
+	store := indexer.mgr.store
+	checkOwners := func(name string, owners map[string]string) {
+		for tokenId, owner := range owners {
+			tokenOwner, err := store.GetTokenOwner(TEST_CONTRACT, tokenId)
+			if err != nil {
+				t.Fatalf("GetTokenOwner error:%s", err)
+			}
+			if tokenOwner == nil || tokenOwner.Owner != owner {
+				t.Errorf("%s: owner of token %s is %+v, expect %s", name, tokenId, tokenOwner, owner)
+			}
+		}
+	}
+	checkOwners("transferred", map[string]string{"01": TEST_ADDRESS_B, "02": TEST_ADDRESS_A})
+	//Balance of OEP-5 holder is the count of tokens, holders are not indexed by token id
+	indexer.checkBalance("transferred", TEST_ADDRESS_A, "", 1)
+	indexer.checkBalance("transferred", TEST_ADDRESS_B, "", 1)
+
+	tests := []struct {
+		owner    string
+		tokenIds []string
+	}{
+		{TEST_ADDRESS_A, []string{"02"}},
+		{TEST_ADDRESS_B, []string{"01"}},
+		{TEST_ADDRESS_C, []string{}},
+	}
+	for _, test := range tests {
+		tokenOwners, err := store.GetTokensOfOwner(0, 10, TEST_CONTRACT, test.owner)
+		if err != nil {
+			t.Fatalf("GetTokensOfOwner error:%s", err)
+		}
+		tokenIds := make([]string, 0, len(tokenOwners))
+		for _, tokenOwner := range tokenOwners {
+			tokenIds = append(tokenIds, tokenOwner.TokenId)
+		}
+		if fmt.Sprint(tokenIds) != fmt.Sprint(test.tokenIds) {
+			t.Errorf("tokens of %s:%v, expect %v", test.owner, tokenIds, test.tokenIds)
+		}
+	}
+
+	//Rollback restores the owner at height
+	err := rollbackToHeight(store, mintHeight)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	checkOwners("rollback", map[string]string{"01": TEST_ADDRESS_A, "02": TEST_ADDRESS_A})
+	indexer.checkBalance("rollback", TEST_ADDRESS_A, "", 2)
+	indexer.checkBalance("rollback", TEST_ADDRESS_B, "", 0)
+}
+
+func TestIndexOep8TokenIds(t *testing.T) {
+	indexer := newTestTokenIndexer(t, TEST_CONTRACT, DECODER_OEP8)
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, "", TEST_ADDRESS_A, "01", "64"},
+		[]interface{}{TEST_NOTIFY_TRANSFER, "", TEST_ADDRESS_A, "02", "0a"})
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "01", "1e"})
+
+	tests := []struct {
+		address string
+		tokenId string
+		balance int64
+	}{
+		{TEST_ADDRESS_A, "01", 70},
+		{TEST_ADDRESS_A, "02", 10},
+		{TEST_ADDRESS_B, "01", 30},
+		{TEST_ADDRESS_B, "02", 0},
+	}
+	for _, test := range tests {
+		indexer.checkBalance("indexed", test.address, test.tokenId, test.balance)
+	}
+
+	//Holders of a token id, and balances of all token ids of an address
+	holders, err := indexer.mgr.store.GetAssetHolder(0, 0, nil, "", TEST_CONTRACT, "02")
+	if err != nil {
+		t.Fatalf("GetAssetHolder error:%s", err)
+	}
+	if len(holders) != 1 || holders[0].Address != TEST_ADDRESS_A || holders[0].TokenId != "02" {
+		t.Errorf("holders of token 02:%+v", holders)
+	}
+	holders, err = indexer.mgr.store.GetAssetHolder(0, 0, nil, TEST_ADDRESS_A, TEST_CONTRACT, "")
+	if err != nil {
+		t.Fatalf("GetAssetHolder error:%s", err)
+	}
+	if len(holders) != 2 {
+		t.Errorf("balances of all token ids of A:%d, expect 2", len(holders))
+	}
+	counts, err := indexer.mgr.store.GetAssetHolderCounts()
+	if err != nil {
+		t.Fatalf("GetAssetHolderCounts error:%s", err)
+	}
+	if counts[TEST_CONTRACT+"01"] != 2 || counts[TEST_CONTRACT+"02"] != 1 {
+		t.Errorf("holder counts:%v", counts)
+	}
+}
+
+func TestIndexAllowances(t *testing.T) {
+	const otherContract = "1000000000000000000000000000000000000002"
+	indexer := newTestTokenIndexer(t, TEST_CONTRACT, DECODER_OEP4)
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, ZERO_ADDRESS, TEST_ADDRESS_A, "e803"})
+	approveHeight := indexer.index([]interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "64"},
+		[]interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_C, "0a"})
+	//The last approval of a tx is kept, and transferFrom doesn't change the allowance until it's notified
+	reapproveHeight := indexer.index([]interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "32"},
+		[]interface{}{TEST_NOTIFY_APPROVAL, TEST_ADDRESS_A, TEST_ADDRESS_B, "28"},
+		[]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_C, "0a"})
+	store := indexer.mgr.store
+	err := store.OnTxEventNotify(nil, nil, nil, nil, []*Allowance{{Owner: TEST_ADDRESS_A, Contract: otherContract,
+		Spender: TEST_ADDRESS_B, Amount: big.NewInt(1), TxHash: "00", Height: 1}}, nil)
+	if err != nil {
+		t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+
+	tests := []struct {
+		spender string
+		amount  int64
+		height  uint32
+	}{
+		{TEST_ADDRESS_B, 40, reapproveHeight},
+		{TEST_ADDRESS_C, 10, approveHeight},
+	}
+	for _, test := range tests {
+		allowance, err := store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_A, test.spender)
+		if err != nil {
+			t.Fatalf("GetAllowance error:%s", err)
+		}
+		if allowance == nil || allowance.Amount.Int64() != test.amount || allowance.Height != test.height {
+			t.Errorf("allowance of %s:%+v, expect %d at height %d", test.spender, allowance, test.amount, test.height)
+		}
+	}
+	allowance, err := store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_B, TEST_ADDRESS_A)
+	if err != nil || allowance != nil {
+		t.Errorf("allowance never approved:%+v error:%v", allowance, err)
+	}
+	indexer.checkBalance("transferred", TEST_ADDRESS_A, "", 990)
+
+	byOwner := []struct {
+		contract string
+		count    int
+	}{
+		{"", 3},
+		{TEST_CONTRACT, 2},
+		{otherContract, 1},
+	}
+	for _, test := range byOwner {
+		allowances, err := store.GetAllowancesByOwner(0, 10, TEST_ADDRESS_A, test.contract)
+		if err != nil {
+			t.Fatalf("GetAllowancesByOwner error:%s", err)
+		}
+		if len(allowances) != test.count {
+			t.Errorf("allowances of contract %q:%d, expect %d", test.contract, len(allowances), test.count)
+		}
+	}
+
+	//Rollback restores the allowances approved above height to the last approval at height
+	err = rollbackToHeight(store, approveHeight)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	allowance, err = store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B)
+	if err != nil || allowance == nil || allowance.Amount.Int64() != 100 || allowance.Height != approveHeight {
+		t.Errorf("allowance approved above rollback height:%+v error:%v", allowance, err)
+	}
+	allowance, err = store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_C)
+	if err != nil || allowance == nil || allowance.Amount.Int64() != 10 {
+		t.Errorf("allowance at rollback height:%+v error:%v", allowance, err)
+	}
+}
+
+func TestIndexInvalidTransfer(t *testing.T) {
+	reconcileInterval := DefConfig.ReconcileInterval
+	t.Cleanup(func() {
+		DefConfig.ReconcileInterval = reconcileInterval
+	})
+	DefConfig.ReconcileInterval = 60
+	indexer := newTestTokenIndexer(t, TEST_CONTRACT, DECODER_OEP4)
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, ZERO_ADDRESS, TEST_ADDRESS_A, "0a"})
+	//Overdraft of A is saved as invalid, only B is credited
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "14"},
+		[]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_C, "04"})
+	indexer.checkBalance("overdraft", TEST_ADDRESS_A, "", 6)
+	indexer.checkBalance("overdraft", TEST_ADDRESS_B, "", 20)
+	indexer.checkBalance("overdraft", TEST_ADDRESS_C, "", 4)
+
+	transfers, err := indexer.mgr.store.GetTransferHistory(0, 10, "", TEST_CONTRACT, 0, indexer.height)
+	if err != nil {
+		t.Fatalf("GetTransferHistory error:%s", err)
+	}
+	invalidTransfers := make(map[string]bool)
+	for _, transfer := range transfers {
+		invalidTransfers[transfer.To] = transfer.IsInvalid
+	}
+	expected := map[string]bool{TEST_ADDRESS_A: false, TEST_ADDRESS_B: true, TEST_ADDRESS_C: false}
+	if !reflect.DeepEqual(invalidTransfers, expected) {
+		t.Errorf("invalid transfers by receiver:%v, expect %v", invalidTransfers, expected)
+	}
+	//The sender of invalid transfer is checked by the next reconcile
+	if len(indexer.mgr.checkHolders) != 1 || indexer.mgr.checkHolders[TEST_ADDRESS_A+TEST_CONTRACT] == nil {
+		t.Errorf("check holders:%v, expect sender of invalid transfer", indexer.mgr.checkHolders)
+	}
+}
+
+func TestHistoryOfAllContracts(t *testing.T) {
+	contractDecoders := DefConfig.ContractDecoders
+	t.Cleanup(func() {
+		DefConfig.ContractDecoders = contractDecoders
+	})
+	DefConfig.ContractDecoders = map[string]string{TEST_CONTRACT: DECODER_OEP4}
+	store := newTestLevelDBHelper(t)
+	mgr := NewOntologyManager(nil, store)
+
+	//The base58 address is saved as TEST_ADDRESS_HEX by the NeoVM contract, and as TEST_ADDRESS_REVERSE_HEX by ONG,
+	//TEST_ADDRESS_REVERSE_HEX of the NeoVM contract is another account
+	transfer := func(height uint32, contract, from, to string) *TxTransfer {
+		return &TxTransfer{TxHash: fmt.Sprintf("%064x", height), Height: height, Contract: contract, From: from, To: to, Amount: big.NewInt(1)}
+	}
+	transfers := []*TxTransfer{
+		transfer(1, TEST_CONTRACT, ZERO_ADDRESS, TEST_ADDRESS_HEX),
+		transfer(2, ONG_CONTRACT_ADDRESS, ZERO_ADDRESS, TEST_ADDRESS_REVERSE_HEX),
+		transfer(3, TEST_CONTRACT, ZERO_ADDRESS, TEST_ADDRESS_REVERSE_HEX),
+		transfer(4, ONG_CONTRACT_ADDRESS, TEST_ADDRESS_REVERSE_HEX, TEST_ADDRESS_A),
+	}
+	allowances := []*Allowance{
+		{Contract: TEST_CONTRACT, Owner: TEST_ADDRESS_HEX, Spender: TEST_ADDRESS_B, Amount: big.NewInt(1)},
+		{Contract: ONG_CONTRACT_ADDRESS, Owner: TEST_ADDRESS_REVERSE_HEX, Spender: TEST_ADDRESS_A, Amount: big.NewInt(1)},
+		{Contract: TEST_CONTRACT, Owner: TEST_ADDRESS_REVERSE_HEX, Spender: TEST_ADDRESS_C, Amount: big.NewInt(1)},
+	}
+	err := store.OnTxEventNotify(nil, nil, transfers, nil, allowances, nil)
+	if err != nil {
+		t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+
+	transferTests := []struct {
+		name    string
+		address string
+		from    int
+		count   int
+		heights []uint32
+	}{
+		{"base58", TEST_ADDRESS_BASE58, 0, 10, []uint32{4, 2, 1}},
+		{"base58 page", TEST_ADDRESS_BASE58, 1, 1, []uint32{2}},
+		{"base58 beyond", TEST_ADDRESS_BASE58, 3, 1, []uint32{}},
+		{"hex", TEST_ADDRESS_REVERSE_HEX, 0, 10, []uint32{4, 3, 2}},
+	}
+	for _, test := range transferTests {
+		history, err := mgr.GetTransferHistoryOfAllContracts(test.from, test.count, test.address, 0, math.MaxUint32)
+		if err != nil {
+			t.Fatalf("GetTransferHistoryOfAllContracts error:%s", err)
+		}
+		heights := make([]uint32, 0, len(history))
+		for _, transfer := range history {
+			heights = append(heights, transfer.Height)
+		}
+		if !reflect.DeepEqual(heights, test.heights) {
+			t.Errorf("%s: transfers at heights %v, expect %v", test.name, heights, test.heights)
+		}
+	}
+
+	approved, err := mgr.GetAllowancesByOwnerOfAllContracts(0, 10, TEST_ADDRESS_BASE58)
+	if err != nil {
+		t.Fatalf("GetAllowancesByOwnerOfAllContracts error:%s", err)
+	}
+	if len(approved) != 2 || approved[0].Contract != ONG_CONTRACT_ADDRESS || approved[1].Contract != TEST_CONTRACT {
+		t.Errorf("allowances of base58 owner:%+v", approved)
+	}
+	approved, err = mgr.GetAllowancesByOwnerOfAllContracts(1, 10, TEST_ADDRESS_BASE58)
+	if err != nil || len(approved) != 1 || approved[0].Spender != TEST_ADDRESS_B {
+		t.Errorf("second page of allowances:%+v error:%v", approved, err)
+	}
+}
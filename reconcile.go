@@ -0,0 +1,351 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	log4 "github.com/alecthomas/log4go"
+	"github.com/ontio/ontology/common"
+	"math/big"
+	"math/rand"
+	"time"
+)
+
+const (
+	RECONCILE_PAGE_SIZE        = 100
+	RECONCILE_COMMIT_WAIT_TIME = 60 //s
+)
+
+//BalanceMismatch is a holder whose indexed balance differs from balanceOf of contract on chain.
+type BalanceMismatch struct {
+	Address        string
+	Contract       string
+	TokenId        string
+	IndexedBalance *big.Int
+	ChainBalance   *big.Int
+	Height         uint32 //committed height when mismatch is found
+	IsCorrected    bool
+	Timestamp      uint32
+}
+
+//getReconcileInterval returns the interval of reconcile, 0 if it's disabled.
+//In native mode, holders changed by native flows are checked every NATIVE_RECONCILE_INTERVAL seconds if ReconcileInterval is 0.
+func getReconcileInterval() uint32 {
+	if DefConfig.ReconcileInterval == 0 && DefConfig.NativeMode {
+		return NATIVE_RECONCILE_INTERVAL
+	}
+	return DefConfig.ReconcileInterval
+}
+
+//startReconcile compares holders with balanceOf on chain every getReconcileInterval seconds
+func (this *OntologyManager) startReconcile() {
+	interval := getReconcileInterval()
+	if interval == 0 {
+		return
+	}
+	reconcileTime := time.Duration(interval) * time.Second
+	reconcileTimer := time.NewTimer(reconcileTime)
+	for {
+		select {
+		case <-reconcileTimer.C:
+			if this.GetCurrentNodeId() == NodeId {
+				err := this.reconcile()
+				if err != nil {
+					log4.Error("reconcile error:%s", err)
+				}
+			}
+			reconcileTimer.Reset(reconcileTime)
+		case <-this.exitCh:
+			return
+		}
+	}
+}
+
+//reconcile checks a random page of ReconcileSampleSize holders, or all holders if ReconcileSampleSize is 0.
+//The queued holders, such as senders of invalid transfers and holders changed by native flows, are checked first.
+func (this *OntologyManager) reconcile() error {
+	err := this.reconcileCheckHolders()
+	if err != nil {
+		return err
+	}
+	if DefConfig.ReconcileInterval == 0 {
+		return nil
+	}
+	sampleSize := int(DefConfig.ReconcileSampleSize)
+	if sampleSize > 0 {
+		from := 0
+		total := this.getTotalHolderCount()
+		if total > sampleSize {
+			from = rand.Intn(total - sampleSize + 1)
+		}
+		holders, err := this.store.GetAllAssetHolders(from, sampleSize)
+		if err != nil {
+			return fmt.Errorf("GetAllAssetHolders error:%s", err)
+		}
+		return this.reconcileHolders(holders)
+	}
+	for from := 0; ; from += RECONCILE_PAGE_SIZE {
+		holders, err := this.store.GetAllAssetHolders(from, RECONCILE_PAGE_SIZE)
+		if err != nil {
+			return fmt.Errorf("GetAllAssetHolders error:%s", err)
+		}
+		err = this.reconcileHolders(holders)
+		if err != nil {
+			return err
+		}
+		if len(holders) < RECONCILE_PAGE_SIZE {
+			return nil
+		}
+	}
+}
+
+//getInvalidTransferSenders returns the senders of invalid transfers, whose indexed balances are lower than chain
+func getInvalidTransferSenders(transfers []*TxTransfer) []*AssetHolder {
+	holders := make([]*AssetHolder, 0)
+	for _, transfer := range transfers {
+		if !transfer.IsInvalid {
+			continue
+		}
+		holders = append(holders, &AssetHolder{Address: transfer.From, Contract: transfer.Contract, TokenId: holderTokenId(transfer)})
+	}
+	return holders
+}
+
+//addCheckHolders queues holders to check with chain by the next reconcile, nothing is queued if reconcile is disabled
+func (this *OntologyManager) addCheckHolders(holders []*AssetHolder) {
+	if len(holders) == 0 || getReconcileInterval() == 0 {
+		return
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, holder := range holders {
+		this.checkHolders[holder.Key()] = holder
+	}
+}
+
+//reconcileCheckHolders checks the queued holders with balanceOf on chain.
+//A holder which isn't saved has zero balance, since it has never received the asset.
+func (this *OntologyManager) reconcileCheckHolders() error {
+	this.lock.Lock()
+	holders := make([]*AssetHolder, 0, len(this.checkHolders))
+	for _, holder := range this.checkHolders {
+		holders = append(holders, holder)
+	}
+	this.checkHolders = make(map[string]*AssetHolder)
+	this.lock.Unlock()
+	if len(holders) == 0 {
+		return nil
+	}
+	holderMap, err := this.store.GetAssetHolderByKey(holders)
+	if err != nil {
+		return fmt.Errorf("GetAssetHolderByKey error:%s", err)
+	}
+	for i, holder := range holders {
+		savedHolder, ok := holderMap[holder.Key()]
+		if ok {
+			holders[i] = savedHolder
+			continue
+		}
+		holders[i] = &AssetHolder{Address: holder.Address, Contract: holder.Contract, TokenId: holder.TokenId, Balance: new(big.Int)}
+	}
+	return this.reconcileHolders(holders)
+}
+
+//reconcileHolders compares holders with balanceOf on chain. Since balanceOf returns the balance at the latest block,
+//a holder is checked again after the sync has committed that block, and it's a mismatch only if neither of
+//the balances has changed in between.
+func (this *OntologyManager) reconcileHolders(holders []*AssetHolder) error {
+	candidates := make([]*AssetHolder, 0)
+	chainBalances := make(map[string]*big.Int)
+	for _, holder := range holders {
+		if !DefContractMgr.IsMonitor(holder.Contract) || DefContractMgr.IsBackfill(holder.Contract) {
+			continue
+		}
+		balance, err := this.BalanceOf(holder.Contract, holder.TokenId, holder.Address)
+		if err != nil {
+			log4.Error("reconcile BalanceOf contract:%s address:%s error:%s", holder.Contract, holder.Address, err)
+			continue
+		}
+		if balance.Cmp(holder.Balance) == 0 {
+			continue
+		}
+		candidates = append(candidates, holder)
+		chainBalances[holder.Key()] = balance
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	chainHeight, err := this.GetOntSdk().GetCurrentBlockHeight()
+	if err != nil {
+		return fmt.Errorf("GetCurrentBlockHeight error:%s", err)
+	}
+	err = this.waitCommitted(chainHeight)
+	if err != nil {
+		return err
+	}
+	holderMap, err := this.store.GetAssetHolderByKey(candidates)
+	if err != nil {
+		return fmt.Errorf("GetAssetHolderByKey error:%s", err)
+	}
+	mismatches := make([]*BalanceMismatch, 0, len(candidates))
+	for _, candidate := range candidates {
+		key := candidate.Key()
+		holder, ok := holderMap[key]
+		if !ok {
+			//A holder which isn't saved has zero balance, such as the receiver of a native flow synced after genesis
+			holder = &AssetHolder{Address: candidate.Address, Contract: candidate.Contract, TokenId: candidate.TokenId, Balance: new(big.Int)}
+		}
+		if holder.Balance.Cmp(candidate.Balance) != 0 {
+			continue
+		}
+		balance, err := this.BalanceOf(holder.Contract, holder.TokenId, holder.Address)
+		if err != nil {
+			log4.Error("reconcile BalanceOf contract:%s address:%s error:%s", holder.Contract, holder.Address, err)
+			continue
+		}
+		if balance.Cmp(chainBalances[key]) != 0 {
+			continue
+		}
+		mismatch := &BalanceMismatch{
+			Address:        holder.Address,
+			Contract:       holder.Contract,
+			TokenId:        holder.TokenId,
+			IndexedBalance: holder.Balance,
+			ChainBalance:   balance,
+			Height:         this.GetCommittedBlockHeight(),
+			Timestamp:      uint32(time.Now().Unix()),
+		}
+		log4.Warn("Balance mismatch contract:%s token_id:%s address:%s indexed:%s chain:%s", mismatch.Contract,
+			mismatch.TokenId, mismatch.Address, mismatch.IndexedBalance, mismatch.ChainBalance)
+		if DefConfig.ReconcileAutoCorrect {
+			mismatch.IsCorrected, err = this.correctHolder(mismatch)
+			if err != nil {
+				log4.Error("reconcile correctHolder contract:%s address:%s error:%s", holder.Contract, holder.Address, err)
+			}
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	err = this.store.SaveBalanceMismatches(mismatches)
+	if err != nil {
+		return fmt.Errorf("SaveBalanceMismatches error:%s", err)
+	}
+	return nil
+}
+
+//waitCommitted waits until blocks up to height are committed by sync
+func (this *OntologyManager) waitCommitted(height uint32) error {
+	timeout := time.After(RECONCILE_COMMIT_WAIT_TIME * time.Second)
+	for this.GetCommittedBlockHeight() < height {
+		select {
+		case <-this.exitCh:
+			return fmt.Errorf("exit")
+		case <-timeout:
+			return fmt.Errorf("wait committed height:%d timeout", height)
+		case <-time.After(time.Second):
+		}
+	}
+	return nil
+}
+
+//correctHolder sets balance of holder to the chain balance, if it hasn't changed since mismatch found.
+//A holder which isn't saved is inserted, if its indexed balance of mismatch is zero.
+func (this *OntologyManager) correctHolder(mismatch *BalanceMismatch) (bool, error) {
+	this.holderLock.Lock()
+	defer this.holderLock.Unlock()
+	holderMap, err := this.store.GetAssetHolderByKey([]*AssetHolder{{
+		Address:  mismatch.Address,
+		Contract: mismatch.Contract,
+		TokenId:  mismatch.TokenId,
+	}})
+	if err != nil {
+		return false, fmt.Errorf("GetAssetHolderByKey error:%s", err)
+	}
+	holder, ok := holderMap[mismatch.Address+mismatch.Contract+mismatch.TokenId]
+	if !ok {
+		holder = &AssetHolder{Address: mismatch.Address, Contract: mismatch.Contract, TokenId: mismatch.TokenId, Balance: new(big.Int)}
+	}
+	if holder.Balance.Cmp(mismatch.IndexedBalance) != 0 {
+		return false, nil
+	}
+	holder.Balance = new(big.Int).Set(mismatch.ChainBalance)
+	err = this.store.OnTxEventNotify(nil, []*AssetHolder{holder}, nil, nil, nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("OnTxEventNotify error:%s", err)
+	}
+	log4.Info("Correct balance contract:%s token_id:%s address:%s to:%s", holder.Contract, holder.TokenId, holder.Address, holder.Balance)
+	return true, nil
+}
+
+//BalanceOf returns the balance of address at the latest block, by balanceOf of contract
+func (this *OntologyManager) BalanceOf(contract, tokenId, address string) (*big.Int, error) {
+	addr, err := HexToAddress(address, contract)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address:%s", address)
+	}
+	switch contract {
+	case ONT_CONTRACT_ADDRESS:
+		balance, err := this.GetOntSdk().Native.Ont.BalanceOf(addr)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetUint64(balance), nil
+	case ONG_CONTRACT_ADDRESS:
+		balance, err := this.GetOntSdk().Native.Ong.BalanceOf(addr)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetUint64(balance), nil
+	}
+	contractAddr, err := common.AddressFromHexString(contract)
+	if err != nil {
+		return nil, err
+	}
+	if IsWasmContract(contract) {
+		preResult, err := this.GetOntSdk().WasmVM.PreExecInvokeWasmVMContract(contractAddr, "balanceOf", []interface{}{addr})
+		if err != nil {
+			return nil, err
+		}
+		data, err := preResult.Result.ToByteArray()
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 16 {
+			return nil, fmt.Errorf("invalid balance result length:%d", len(data))
+		}
+		return parseWasmU128(data), nil
+	}
+	params := []interface{}{addr}
+	if IsMultiTokenContract(contract) {
+		tokenIdBytes, err := hex.DecodeString(tokenId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token id:%s", tokenId)
+		}
+		params = append(params, tokenIdBytes)
+	}
+	preResult, err := this.GetOntSdk().NeoVM.PreExecInvokeNeoVMContract(contractAddr, []interface{}{"balanceOf", params})
+	if err != nil {
+		return nil, err
+	}
+	return preResult.Result.ToInteger()
+}
+
+func (this *OntologyManager) GetBalanceMismatches(from, count int, contract string) ([]*BalanceMismatch, error) {
+	return this.store.GetBalanceMismatches(from, count, contract)
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCorrectHolder(t *testing.T) {
+	tests := []struct {
+		name           string
+		address        string
+		indexedBalance int64
+		isCorrected    bool
+		isExist        bool
+		balance        int64
+	}{
+		{"holder unchanged", TEST_ADDRESS_A, 100, true, true, 80},
+		{"holder changed since mismatch found", TEST_ADDRESS_A, 90, false, true, 100},
+		{"missing holder is inserted", TEST_ADDRESS_B, 0, true, true, 80},
+		{"missing holder saved since mismatch found", TEST_ADDRESS_B, 10, false, false, 0},
+	}
+	for _, test := range tests {
+		store := newTestLevelDBHelper(t)
+		indexer := newTestIndexer(t, store, TEST_CONTRACT)
+		indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+		mgr := NewOntologyManager(nil, store)
+		isCorrected, err := mgr.correctHolder(&BalanceMismatch{
+			Address:        test.address,
+			Contract:       TEST_CONTRACT,
+			IndexedBalance: big.NewInt(test.indexedBalance),
+			ChainBalance:   big.NewInt(80),
+		})
+		if err != nil {
+			t.Fatalf("%s: correctHolder error:%s", test.name, err)
+		}
+		if isCorrected != test.isCorrected {
+			t.Errorf("%s: isCorrected:%v, expect %v", test.name, isCorrected, test.isCorrected)
+		}
+		holder := getTestHolder(t, store, test.address, TEST_CONTRACT)
+		if (holder != nil) != test.isExist || (holder != nil && holder.Balance.Int64() != test.balance) {
+			t.Errorf("%s: holder:%+v, expect exist:%v balance %d", test.name, holder, test.isExist, test.balance)
+		}
+	}
+}
+
+func TestReconcileHoldersSkipContracts(t *testing.T) {
+	const backfillContract = "1000000000000000000000000000000000000003"
+	contractMgr := DefContractMgr
+	t.Cleanup(func() {
+		DefContractMgr = contractMgr
+	})
+	DefContractMgr = NewContractManager()
+	DefContractMgr.Update([]*MonitorContract{{Contract: backfillContract, State: CONTRACT_STATE_ACTIVE}},
+		map[string]uint32{backfillContract: 0}, 10, true)
+	if !DefContractMgr.IsBackfill(backfillContract) {
+		t.Fatalf("contract:%s is not in backfill", backfillContract)
+	}
+
+	//Holders of unmonitored or backfill contracts are not compared with chain, so no sdk is needed
+	store := newTestLevelDBHelper(t)
+	mgr := NewOntologyManager(nil, store)
+	err := mgr.reconcileHolders([]*AssetHolder{
+		{Address: TEST_ADDRESS_A, Contract: TEST_CONTRACT, Balance: big.NewInt(1)},
+		{Address: TEST_ADDRESS_A, Contract: backfillContract, Balance: big.NewInt(1)},
+	})
+	if err != nil {
+		t.Fatalf("reconcileHolders error:%s", err)
+	}
+	mismatches, err := store.GetBalanceMismatches(0, 10, "")
+	if err != nil {
+		t.Fatalf("GetBalanceMismatches error:%s", err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("mismatches of skipped contracts:%d", len(mismatches))
+	}
+}
+
+func TestBalanceMismatches(t *testing.T) {
+	const otherContract = "1000000000000000000000000000000000000002"
+	store := newTestLevelDBHelper(t)
+	mismatch := func(address, contract string, chainBalance int64) *BalanceMismatch {
+		return &BalanceMismatch{Address: address, Contract: contract, IndexedBalance: big.NewInt(1),
+			ChainBalance: big.NewInt(chainBalance), Height: 10}
+	}
+	err := store.SaveBalanceMismatches([]*BalanceMismatch{
+		mismatch(TEST_ADDRESS_A, TEST_CONTRACT, 2),
+		mismatch(TEST_ADDRESS_B, TEST_CONTRACT, 3),
+		mismatch(TEST_ADDRESS_A, otherContract, 4),
+	})
+	if err != nil {
+		t.Fatalf("SaveBalanceMismatches error:%s", err)
+	}
+	//A mismatch found again overwrites the earlier one
+	err = store.SaveBalanceMismatches([]*BalanceMismatch{mismatch(TEST_ADDRESS_B, TEST_CONTRACT, 5)})
+	if err != nil {
+		t.Fatalf("SaveBalanceMismatches error:%s", err)
+	}
+
+	tests := []struct {
+		name     string
+		from     int
+		count    int
+		contract string
+		expect   int
+	}{
+		{"all contracts", 0, 10, "", 3},
+		{"by contract", 0, 10, TEST_CONTRACT, 2},
+		{"other contract", 0, 10, otherContract, 1},
+		{"from", 1, 10, TEST_CONTRACT, 1},
+		{"count", 0, 1, "", 1},
+		{"from beyond", 3, 10, "", 0},
+	}
+	for _, test := range tests {
+		mismatches, err := store.GetBalanceMismatches(test.from, test.count, test.contract)
+		if err != nil {
+			t.Fatalf("%s: GetBalanceMismatches error:%s", test.name, err)
+		}
+		if len(mismatches) != test.expect {
+			t.Errorf("%s: mismatches:%d, expect %d", test.name, len(mismatches), test.expect)
+		}
+	}
+	mismatches, err := store.GetBalanceMismatches(0, 10, TEST_CONTRACT)
+	if err != nil {
+		t.Fatalf("GetBalanceMismatches error:%s", err)
+	}
+	for _, item := range mismatches {
+		if item.Address == TEST_ADDRESS_B && item.ChainBalance.Int64() != 5 {
+			t.Errorf("overwritten mismatch chain balance:%s, expect 5", item.ChainBalance)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	log4 "github.com/alecthomas/log4go"
+	"net/http"
+	"strings"
+)
+
+const REST_PATH_PREFIX = "/v1/"
+
+//RestRoute maps a http method and path pattern to a registered handler,
+//segments of pattern in braces are path params, which are passed to the handler with the same name
+type RestRoute struct {
+	HttpMethod string
+	Pattern    string
+	Method     string
+	segments   []string
+}
+
+type RestResponse struct {
+	ErrorCode uint32      `json:"error_code,omitempty"`
+	ErrorInfo string      `json:"error_info,omitempty"`
+	Result    interface{} `json:"result"`
+}
+
+var restRoutes = []*RestRoute{
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts", Method: "getContracts"},
+	{HttpMethod: http.MethodPost, Pattern: "/v1/contracts", Method: "addContract"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}", Method: "getAssetInfo"},
+	{HttpMethod: http.MethodDelete, Pattern: "/v1/contracts/{contract}", Method: "removeContract"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/holders", Method: "getAssetHolder"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/holders/count", Method: "getAssetHolderCount"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/heights/{height}/holders", Method: "getAssetHolderAtHeight"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/transfers", Method: "getTransferHistory"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/tokens/{token_id}/owner", Method: "getOwnerOf"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/allowances/{owner}/{spender}", Method: "getAllowance"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/contracts/{contract}/mismatches", Method: "getBalanceMismatches"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/addresses/{address}/balances", Method: "getBalance"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/addresses/{address}/transfers", Method: "getTransferHistory"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/addresses/{address}/tokens", Method: "getTokensOfOwner"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/addresses/{owner}/allowances", Method: "getAllowancesByOwner"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/balances", Method: "getBalances"},
+	{HttpMethod: http.MethodPost, Pattern: "/v1/balances", Method: "getBalances"},
+	{HttpMethod: http.MethodGet, Pattern: "/v1/mismatches", Method: "getBalanceMismatches"},
+}
+
+func init() {
+	for _, route := range restRoutes {
+		route.segments = splitRestPath(route.Pattern)
+	}
+}
+
+func splitRestPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+//match returns the path params if path matches the pattern of route
+func (this *RestRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(this.segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range this.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+//getRestStatusCode returns the http status code of handler error code
+func getRestStatusCode(errCode uint32) int {
+	switch errCode {
+	case ERR_SUCCESS:
+		return http.StatusOK
+	case ERR_INVALID_PARAMS:
+		return http.StatusBadRequest
+	case ERR_INVALID_METHOD, ERR_NOT_FOUND:
+		return http.StatusNotFound
+	case ERR_FORBIDDEN:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+//RestHandler serves the /v1 api, query params and form params of POST are passed to the handler with path params,
+//path params take precedence over the others with the same name.
+func (this *HttpServer) RestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("content-type", "application/json;charset=utf-8")
+
+	segments := splitRestPath(r.URL.Path)
+	var route *RestRoute
+	var pathParams map[string]string
+	allowMethods := make([]string, 0)
+	for _, restRoute := range restRoutes {
+		params, ok := restRoute.match(segments)
+		if !ok {
+			continue
+		}
+		if restRoute.HttpMethod != r.Method {
+			allowMethods = append(allowMethods, restRoute.HttpMethod)
+			continue
+		}
+		route = restRoute
+		pathParams = params
+		break
+	}
+	if route == nil {
+		if len(allowMethods) > 0 {
+			w.Header().Set("Allow", strings.Join(allowMethods, ", "))
+			this.writeRestResponse(w, http.StatusMethodNotAllowed, &RestResponse{ErrorCode: ERR_INVALID_METHOD, ErrorInfo: "method not allowed"})
+			return
+		}
+		this.writeRestResponse(w, http.StatusNotFound, &RestResponse{ErrorCode: ERR_INVALID_METHOD, ErrorInfo: "not found"})
+		return
+	}
+
+	params := make(map[string]string)
+	err := r.ParseForm()
+	if err != nil {
+		this.writeRestResponse(w, http.StatusBadRequest, &RestResponse{ErrorCode: ERR_INVALID_PARAMS, ErrorInfo: "invalid form"})
+		return
+	}
+	for k, vs := range r.Form {
+		params[strings.ToLower(k)] = vs[0]
+	}
+	for k, v := range pathParams {
+		params[k] = v
+	}
+	req := &HttpServerRequest{
+		Method:        route.Method,
+		HttpMethod:    r.Method,
+		Authorization: r.Header.Get("Authorization"),
+		Params:        params,
+	}
+	resp := &HttpServerResponse{
+		Method:    route.Method,
+		ErrorCode: ERR_SUCCESS,
+	}
+	this.callHandler(req, resp)
+
+	if resp.ErrorCode != ERR_SUCCESS {
+		if resp.ErrorInfo == "" {
+			resp.ErrorInfo = GetHttpServerErrorDesc(resp.ErrorCode)
+		}
+		this.writeRestResponse(w, getRestStatusCode(resp.ErrorCode), &RestResponse{ErrorCode: resp.ErrorCode, ErrorInfo: resp.ErrorInfo})
+		return
+	}
+	this.writeRestResponse(w, http.StatusOK, &RestResponse{Result: resp.Result})
+}
+
+func (this *HttpServer) writeRestResponse(w http.ResponseWriter, statusCode int, resp *RestResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log4.Error("RestHandler json.Marshal RestResponse:%+v error:%s", resp, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(statusCode)
+	_, err = w.Write(data)
+	if err != nil {
+		log4.Error("RestHandler Write error:%s", err)
+	}
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//setTestMonitorContract monitors contract with decoder, and indexes it to a new store
+func setTestMonitorContract(t *testing.T, contract, decoder string) {
+	contractMgr, ontologyMgr, contractDecoders := DefContractMgr, DefOntologyMgr, DefConfig.ContractDecoders
+	t.Cleanup(func() {
+		DefContractMgr, DefOntologyMgr, DefConfig.ContractDecoders = contractMgr, ontologyMgr, contractDecoders
+	})
+	DefContractMgr = NewContractManager()
+	DefContractMgr.Update([]*MonitorContract{{Contract: contract, State: CONTRACT_STATE_ACTIVE}}, nil, 0, true)
+	DefConfig.ContractDecoders = map[string]string{contract: decoder}
+	DefOntologyMgr = NewOntologyManager(nil, newTestLevelDBHelper(t))
+}
+
+func serveTestRest(httpSvr *HttpServer, httpMethod, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	httpSvr.RestHandler(recorder, httptest.NewRequest(httpMethod, path, nil))
+	return recorder
+}
+
+func TestRestNotFound(t *testing.T) {
+	setTestMonitorContract(t, TEST_CONTRACT, DECODER_OEP5)
+	httpSvr := NewHttpServer()
+	httpSvr.RegHandler("getAssetInfo", httpSvr.GetAssetInfo)
+	httpSvr.RegHandler("getOwnerOf", httpSvr.GetOwnerOf)
+	httpSvr.RegHandler("getAllowance", httpSvr.GetAllowance)
+
+	const unknownContract = "0000000000000000000000000000000000000001"
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+		body       string
+	}{
+		{
+			"unknown contract", "/v1/contracts/" + unknownContract, http.StatusNotFound,
+			`{"error_code":1004,"error_info":"contract:` + unknownContract + ` is not monitored","result":null}`,
+		},
+		{
+			"allowance of unknown contract", "/v1/contracts/" + unknownContract + "/allowances/" + TEST_ADDRESS_A + "/" + TEST_ADDRESS_B,
+			http.StatusNotFound, `{"error_code":1004,"error_info":"contract:` + unknownContract + ` is not monitored","result":null}`,
+		},
+		{
+			"unknown token", "/v1/contracts/" + TEST_CONTRACT + "/tokens/01/owner", http.StatusNotFound,
+			`{"error_code":1004,"error_info":"token does not exist","result":null}`,
+		},
+		{
+			"unknown path", "/v1/tokens", http.StatusNotFound,
+			`{"error_code":1002,"error_info":"not found","result":null}`,
+		},
+	}
+	for _, test := range tests {
+		recorder := serveTestRest(httpSvr, http.MethodGet, test.path)
+		if recorder.Code != test.statusCode {
+			t.Errorf("%s: status code %d, expect %d", test.name, recorder.Code, test.statusCode)
+		}
+		if recorder.Body.String() != test.body {
+			t.Errorf("%s: body %s, expect %s", test.name, recorder.Body.String(), test.body)
+		}
+	}
+}
+
+func TestRestAddressFormat(t *testing.T) {
+	setTestMonitorContract(t, TEST_CONTRACT, DECODER_OEP5)
+	httpSvr := NewHttpServer()
+	httpSvr.RegHandler("getTransferHistory", httpSvr.GetTransferHistory)
+	httpSvr.RegHandler("getOwnerOf", httpSvr.GetOwnerOf)
+	httpSvr.RegHandler("getAllowancesByOwner", httpSvr.GetAllowancesByOwner)
+	maxQueryPageSize := DefConfig.MaxQueryPageSize
+	t.Cleanup(func() {
+		DefConfig.MaxQueryPageSize = maxQueryPageSize
+	})
+	DefConfig.MaxQueryPageSize = 10
+
+	transfers := []*TxTransfer{
+		{TxHash: "01", Height: 1, Contract: TEST_CONTRACT, TokenId: "01", From: ZERO_ADDRESS, To: TEST_ADDRESS_HEX, Amount: big.NewInt(1)},
+	}
+	tokenOwners := []*TokenOwner{{Contract: TEST_CONTRACT, TokenId: "01", Owner: TEST_ADDRESS_HEX, Height: 1}}
+	allowances := []*Allowance{{Contract: TEST_CONTRACT, Owner: TEST_ADDRESS_HEX, Spender: TEST_ADDRESS_B, Amount: big.NewInt(1)}}
+	err := DefOntologyMgr.store.OnTxEventNotify(nil, nil, transfers, tokenOwners, allowances, nil)
+	if err != nil {
+		t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{
+			"base58 transfer history", "/v1/addresses/" + TEST_ADDRESS_BASE58 + "/transfers?from=0&count=10&address_format=base58",
+			`"from":"` + TEST_ZERO_BASE58 + `","to":"` + TEST_ADDRESS_BASE58 + `"`,
+		},
+		{
+			"hex transfer history", "/v1/addresses/" + TEST_ADDRESS_BASE58 + "/transfers?from=0&count=10",
+			`"from":"` + ZERO_ADDRESS + `","to":"` + TEST_ADDRESS_HEX + `"`,
+		},
+		{
+			"base58 owner", "/v1/contracts/" + TEST_CONTRACT + "/tokens/01/owner?address_format=base58",
+			`"owner":"` + TEST_ADDRESS_BASE58 + `"`,
+		},
+		{
+			"base58 allowance owner", "/v1/addresses/" + TEST_ADDRESS_BASE58 + "/allowances?from=0&count=10",
+			`"spender":"` + TEST_ADDRESS_B + `"`,
+		},
+	}
+	for _, test := range tests {
+		recorder := serveTestRest(httpSvr, http.MethodGet, test.path)
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), test.body) {
+			t.Errorf("%s: status code %d body %s, expect %s", test.name, recorder.Code, recorder.Body.String(), test.body)
+		}
+	}
+}
+
+func TestRestResult(t *testing.T) {
+	httpSvr := NewHttpServer()
+	tests := []struct {
+		name   string
+		result interface{}
+		body   string
+	}{
+		{"empty list", []string{}, `{"result":[]}`},
+		{"zero", 0, `{"result":0}`},
+		{"false", false, `{"result":false}`},
+		{"nil", nil, `{"result":null}`},
+	}
+	for _, test := range tests {
+		result := test.result
+		httpSvr.RegHandler("getContracts", func(req *HttpServerRequest, resp *HttpServerResponse) {
+			resp.Result = result
+		})
+		recorder := serveTestRest(httpSvr, http.MethodGet, "/v1/contracts")
+		if recorder.Code != http.StatusOK || recorder.Body.String() != test.body {
+			t.Errorf("%s: status code %d body %s, expect %s", test.name, recorder.Code, recorder.Body.String(), test.body)
+		}
+	}
+}
+
+func TestGetRestStatusCode(t *testing.T) {
+	tests := []struct {
+		errCode    uint32
+		statusCode int
+	}{
+		{ERR_SUCCESS, http.StatusOK},
+		{ERR_INVALID_PARAMS, http.StatusBadRequest},
+		{ERR_INVALID_METHOD, http.StatusNotFound},
+		{ERR_FORBIDDEN, http.StatusForbidden},
+		{ERR_NOT_FOUND, http.StatusNotFound},
+		{ERR_INTERNAL, http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		statusCode := getRestStatusCode(test.errCode)
+		if statusCode != test.statusCode {
+			t.Errorf("error code %d: status code %d, expect %d", test.errCode, statusCode, test.statusCode)
+		}
+	}
+}
+
+func TestRestRouteMatch(t *testing.T) {
+	route := &RestRoute{Pattern: "/v1/contracts/{contract}/allowances/{owner}/{spender}"}
+	route.segments = splitRestPath(route.Pattern)
+	tests := []struct {
+		name   string
+		path   string
+		params map[string]string
+		ok     bool
+	}{
+		{
+			name:   "path params",
+			path:   "/v1/contracts/c/allowances/a/b",
+			params: map[string]string{"contract": "c", "owner": "a", "spender": "b"},
+			ok:     true,
+		},
+		{
+			name:   "trailing slash",
+			path:   "/v1/contracts/c/allowances/a/b/",
+			params: map[string]string{"contract": "c", "owner": "a", "spender": "b"},
+			ok:     true,
+		},
+		{name: "less segments", path: "/v1/contracts/c/allowances/a"},
+		{name: "more segments", path: "/v1/contracts/c/allowances/a/b/c"},
+		{name: "empty param", path: "/v1/contracts//allowances/a/b"},
+		{name: "static segment", path: "/v1/contracts/c/allowance/a/b"},
+	}
+	for _, test := range tests {
+		params, ok := route.match(splitRestPath(test.path))
+		if ok != test.ok {
+			t.Errorf("%s: match:%v, expect %v", test.name, ok, test.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: params:%v, expect %v", test.name, params, test.params)
+		}
+	}
+}
+
+func TestRestHandlerRouting(t *testing.T) {
+	httpSvr := NewHttpServer()
+	//Every handler returns its method and params, to check which route is served
+	for _, route := range restRoutes {
+		method := route.Method
+		httpSvr.RegHandler(method, func(req *HttpServerRequest, resp *HttpServerResponse) {
+			resp.Result = map[string]interface{}{"method": method, "params": req.Params}
+		})
+	}
+
+	tests := []struct {
+		name       string
+		httpMethod string
+		path       string
+		statusCode int
+		allow      string
+		body       string
+	}{
+		{
+			name:       "static route",
+			httpMethod: http.MethodGet,
+			path:       "/v1/contracts",
+			statusCode: http.StatusOK,
+			body:       `{"result":{"method":"getContracts","params":{}}}`,
+		},
+		{
+			name:       "route by http method",
+			httpMethod: http.MethodPost,
+			path:       "/v1/contracts",
+			statusCode: http.StatusOK,
+			body:       `{"result":{"method":"addContract","params":{}}}`,
+		},
+		{
+			name:       "static segment after param",
+			httpMethod: http.MethodGet,
+			path:       "/v1/contracts/c/holders/count",
+			statusCode: http.StatusOK,
+			body:       `{"result":{"method":"getAssetHolderCount","params":{"contract":"c"}}}`,
+		},
+		{
+			name:       "path params",
+			httpMethod: http.MethodGet,
+			path:       "/v1/contracts/c/heights/10/holders?from=0&Count=5",
+			statusCode: http.StatusOK,
+			body:       `{"result":{"method":"getAssetHolderAtHeight","params":{"contract":"c","count":"5","from":"0","height":"10"}}}`,
+		},
+		{
+			name:       "path param over query",
+			httpMethod: http.MethodGet,
+			path:       "/v1/addresses/a/tokens?address=b",
+			statusCode: http.StatusOK,
+			body:       `{"result":{"method":"getTokensOfOwner","params":{"address":"a"}}}`,
+		},
+		{
+			name:       "method not allowed",
+			httpMethod: http.MethodPut,
+			path:       "/v1/contracts/c",
+			statusCode: http.StatusMethodNotAllowed,
+			allow:      "GET, DELETE",
+			body:       `{"error_code":1002,"error_info":"method not allowed","result":null}`,
+		},
+		{
+			name:       "unknown path",
+			httpMethod: http.MethodGet,
+			path:       "/v1/contracts/c/unknown",
+			statusCode: http.StatusNotFound,
+			body:       `{"error_code":1002,"error_info":"not found","result":null}`,
+		},
+	}
+	for _, test := range tests {
+		recorder := serveTestRest(httpSvr, test.httpMethod, test.path)
+		if recorder.Code != test.statusCode {
+			t.Errorf("%s: status code:%d, expect %d", test.name, recorder.Code, test.statusCode)
+		}
+		if allow := recorder.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s: allow:%s, expect %s", test.name, allow, test.allow)
+		}
+		if body := strings.TrimSpace(recorder.Body.String()); body != test.body {
+			t.Errorf("%s: body:%s, expect %s", test.name, body, test.body)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	log4 "github.com/alecthomas/log4go"
+	"math"
+	"math/big"
+)
+
+const (
+	ROLLBACK_PAGE_SIZE = 1000
+)
+
+//runRollback reverts indexed data to height, the indexer resumes from height+1 when it's started again.
+//All nodes must be stopped before rollback.
+//Usage: ontology-holder rollback -height <height>
+func runRollback(args []string) error {
+	flagSet := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	height := flagSet.Int64("height", -1, "block height to rollback to, data above it is reverted")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *height < 0 || *height >= math.MaxUint32 {
+		return fmt.Errorf("invalid height:%d", *height)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	return rollbackToHeight(store, uint32(*height))
+}
+
+//rollbackToHeight reverts holders and token owners with the transfers above height,
+//then deletes data above height and resets sync checkpoints to height.
+//Like holder snapshots, it fails if height is below the first saved transfer of a contract with transfers above height.
+func rollbackToHeight(store HolderStore, height uint32) error {
+	contracts, err := store.GetContracts()
+	if err != nil {
+		return fmt.Errorf("GetContracts error:%s", err)
+	}
+	transfers := make([]*TxTransfer, 0)
+	for _, contract := range contracts {
+		count := len(transfers)
+		for from := 0; ; from += ROLLBACK_PAGE_SIZE {
+			items, err := store.GetTransferHistory(from, ROLLBACK_PAGE_SIZE, "", contract.Contract, height+1, math.MaxUint32)
+			if err != nil {
+				return fmt.Errorf("GetTransferHistory error:%s", err)
+			}
+			transfers = append(transfers, items...)
+			if len(items) < ROLLBACK_PAGE_SIZE {
+				break
+			}
+		}
+		if len(transfers) == count {
+			continue
+		}
+		invalidInfo, err := checkSnapshotHeight(store, contract.Contract, height)
+		if err != nil {
+			return err
+		}
+		if invalidInfo != "" {
+			return fmt.Errorf("can't rollback contract:%s to height:%d, %s", contract.Contract, height, invalidInfo)
+		}
+	}
+	log4.Info("Rollback %d transfers above height:%d", len(transfers), height)
+
+	assetHolders, err := revertTransfers(store, transfers)
+	if err != nil {
+		return err
+	}
+	tokenOwners, err := getTokenOwnersAtHeight(store, transfers, height)
+	if err != nil {
+		return err
+	}
+	err = store.Rollback(height, assetHolders, tokenOwners)
+	if err != nil {
+		return fmt.Errorf("Rollback error:%s", err)
+	}
+	log4.Info("Rollback to height:%d, %d holders reverted", height, len(assetHolders))
+	return nil
+}
+
+//revertTransfers reverts txTransfers from holders saved in store, and returns the changed holders.
+//A holder whose balance goes back to 0 is deleted by store.Rollback.
+func revertTransfers(store HolderStore, txTransfers []*TxTransfer) ([]*AssetHolder, error) {
+	keys := make([]*AssetHolder, 0)
+	keyMap := make(map[string]bool)
+	for _, txTransfer := range txTransfers {
+		tokenId := holderTokenId(txTransfer)
+		for _, address := range []string{txTransfer.From, txTransfer.To} {
+			holder := &AssetHolder{Address: address, Contract: txTransfer.Contract, TokenId: tokenId}
+			if address == ZERO_ADDRESS || keyMap[holder.Key()] {
+				continue
+			}
+			keyMap[holder.Key()] = true
+			keys = append(keys, holder)
+		}
+	}
+	assetHolderMap := make(map[string]*AssetHolder, len(keys))
+	for start := 0; start < len(keys); start += ROLLBACK_PAGE_SIZE {
+		end := start + ROLLBACK_PAGE_SIZE
+		if end > len(keys) {
+			end = len(keys)
+		}
+		holderMap, err := store.GetAssetHolderByKey(keys[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("GetAssetHolderByKey error:%s", err)
+		}
+		for key, holder := range holderMap {
+			assetHolderMap[key] = holder
+		}
+	}
+
+	revertHolderTransfers(assetHolderMap, txTransfers, false)
+
+	assetHolders := make([]*AssetHolder, 0, len(assetHolderMap))
+	for _, assetHolder := range assetHolderMap {
+		if assetHolder.Balance.Sign() < 0 {
+			log4.Warn("Rollback holder balance negative, Contract:%s Address:%s Balance:%s", assetHolder.Contract, assetHolder.Address, assetHolder.Balance)
+		}
+		assetHolders = append(assetHolders, assetHolder)
+	}
+	return assetHolders, nil
+}
+
+//revertHolderTransfers reverts txTransfers from holders of assetHolderMap, which is keyed by AssetHolder.Key().
+//A holder not in assetHolderMap is added with zero balance if isAddMissing is set, otherwise it's skipped.
+//The sender of an invalid transfer isn't reverted, since it wasn't debited by applyTransfers.
+func revertHolderTransfers(assetHolderMap map[string]*AssetHolder, txTransfers []*TxTransfer, isAddMissing bool) {
+	txMap := make(map[string]bool, len(txTransfers))
+	for _, txTransfer := range txTransfers {
+		tokenId := holderTokenId(txTransfer)
+		for _, address := range []string{txTransfer.From, txTransfer.To} {
+			if address == ZERO_ADDRESS || (txTransfer.IsInvalid && address != txTransfer.To) {
+				continue
+			}
+			key := address + txTransfer.Contract + tokenId
+			assetHolder, ok := assetHolderMap[key]
+			if !ok && isAddMissing {
+				assetHolder = &AssetHolder{Address: address, Contract: txTransfer.Contract, TokenId: tokenId, Balance: new(big.Int)}
+				assetHolderMap[key] = assetHolder
+			} else if !ok {
+				log4.Warn("Rollback holder not found, Contract:%s TxHash:%s Address:%s", txTransfer.Contract, txTransfer.TxHash, address)
+				continue
+			}
+			if address == txTransfer.From {
+				assetHolder.Balance = new(big.Int).Add(assetHolder.Balance, txTransfer.Amount)
+			}
+			if address == txTransfer.To {
+				assetHolder.Balance = new(big.Int).Sub(assetHolder.Balance, txTransfer.Amount)
+			}
+			txKey := key + txTransfer.TxHash
+			if !txMap[txKey] && assetHolder.Transactions > 0 {
+				txMap[txKey] = true
+				assetHolder.Transactions--
+			}
+		}
+	}
+}
+
+//getTokenOwnersAtHeight returns the owner at height of OEP-5 tokens transferred in txTransfers,
+//the owner is ZERO_ADDRESS if the token didn't exist at height.
+func getTokenOwnersAtHeight(store HolderStore, txTransfers []*TxTransfer, height uint32) ([]*TokenOwner, error) {
+	tokenOwnerMap := make(map[string]bool)
+	tokenOwners := make([]*TokenOwner, 0)
+	for _, txTransfer := range txTransfers {
+		key := txTransfer.Contract + txTransfer.TokenId
+		if txTransfer.TokenId == "" || !IsNFTContract(txTransfer.Contract) || tokenOwnerMap[key] {
+			continue
+		}
+		tokenOwnerMap[key] = true
+		tokenOwner := &TokenOwner{
+			Contract: txTransfer.Contract,
+			TokenId:  txTransfer.TokenId,
+			Owner:    ZERO_ADDRESS,
+		}
+		lastTransfer, err := store.GetLastTokenTransfer(txTransfer.Contract, txTransfer.TokenId, height)
+		if err != nil {
+			return nil, fmt.Errorf("GetLastTokenTransfer error:%s", err)
+		}
+		if lastTransfer != nil {
+			tokenOwner.Owner = lastTransfer.To
+			tokenOwner.Height = lastTransfer.Height
+		}
+		tokenOwners = append(tokenOwners, tokenOwner)
+	}
+	return tokenOwners, nil
+}
@@ -0,0 +1,295 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+const (
+	TEST_CONTRACT  = "b71fc841b203bcf08e81311131671885db689faf"
+	TEST_ADDRESS_A = "98067c0ae9fd8f109956e06f5519a9bc0963f699"
+	TEST_ADDRESS_B = "a9ac9d3e8be9f3b5e8e8e7ba1a1d9c9a5d8e3a4b"
+	TEST_ADDRESS_C = "0d8a2b4c6e8f0a1b2c3d4e5f60718293a4b5c6d7"
+)
+
+//testIndexer applies transfers to a store the way OntologyManager does, one tx per block
+type testIndexer struct {
+	t       *testing.T
+	store   HolderStore
+	holders map[string]*AssetHolder
+	height  uint32
+}
+
+func newTestLevelDBHelper(t *testing.T) *LevelDBHelper {
+	store := NewLevelDBHelper(t.TempDir())
+	err := store.Open()
+	if err != nil {
+		t.Fatalf("Open error:%s", err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+	})
+	return store
+}
+
+func newTestIndexer(t *testing.T, store HolderStore, contract string) *testIndexer {
+	err := store.SaveContract(&MonitorContract{Contract: contract})
+	if err != nil {
+		t.Fatalf("SaveContract error:%s", err)
+	}
+	return &testIndexer{
+		t:       t,
+		store:   store,
+		holders: make(map[string]*AssetHolder),
+	}
+}
+
+func (this *testIndexer) getHolder(address, contract, tokenId string) *AssetHolder {
+	key := address + contract + tokenId
+	holder, ok := this.holders[key]
+	if !ok {
+		holder = &AssetHolder{Address: address, Contract: contract, TokenId: tokenId, Balance: new(big.Int)}
+		this.holders[key] = holder
+	}
+	return holder
+}
+
+//transfer indexes a transfer in a new block, and returns its height
+func (this *testIndexer) transfer(contract, tokenId, from, to string, amount int64) uint32 {
+	this.height++
+	txTransfer := &TxTransfer{
+		TxHash:   fmt.Sprintf("%064x", this.height),
+		Height:   this.height,
+		Name:     NOTIFY_TRANSFER,
+		Contract: contract,
+		TokenId:  tokenId,
+		From:     from,
+		To:       to,
+		Amount:   big.NewInt(amount),
+	}
+	changed := make([]*AssetHolder, 0, 2)
+	for _, address := range []string{from, to} {
+		if address == ZERO_ADDRESS {
+			continue
+		}
+		holder := this.getHolder(address, contract, tokenId)
+		if address == from {
+			holder.Balance = new(big.Int).Sub(holder.Balance, txTransfer.Amount)
+		} else {
+			holder.Balance = new(big.Int).Add(holder.Balance, txTransfer.Amount)
+		}
+		holder.Transactions++
+		changed = append(changed, &AssetHolder{
+			Address:      holder.Address,
+			Contract:     holder.Contract,
+			TokenId:      holder.TokenId,
+			Balance:      new(big.Int).Set(holder.Balance),
+			Transactions: holder.Transactions,
+		})
+	}
+	evtNotify := []*TxEventNotify{{TxHash: txTransfer.TxHash, Height: this.height, State: TX_STATE_SUCCESS}}
+	syncStates := []*SyncState{{Contract: contract, Height: this.height}}
+	err := this.store.OnTxEventNotify(evtNotify, changed, []*TxTransfer{txTransfer}, nil, nil, syncStates)
+	if err != nil {
+		this.t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+	return this.height
+}
+
+//approve indexes an approval in a new block, and returns its height
+func (this *testIndexer) approve(contract, owner, spender string, amount int64) uint32 {
+	this.height++
+	allowance := &Allowance{
+		Contract: contract,
+		Owner:    owner,
+		Spender:  spender,
+		Amount:   big.NewInt(amount),
+		TxHash:   fmt.Sprintf("%064x", this.height),
+		Height:   this.height,
+	}
+	evtNotify := []*TxEventNotify{{TxHash: allowance.TxHash, Height: this.height, State: TX_STATE_SUCCESS}}
+	syncStates := []*SyncState{{Contract: contract, Height: this.height}}
+	err := this.store.OnTxEventNotify(evtNotify, nil, nil, nil, []*Allowance{allowance}, syncStates)
+	if err != nil {
+		this.t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+	return this.height
+}
+
+func getTestHolder(t *testing.T, store HolderStore, address, contract string) *AssetHolder {
+	holders, err := store.GetAssetHolder(0, 0, nil, address, contract, "")
+	if err != nil {
+		t.Fatalf("GetAssetHolder error:%s", err)
+	}
+	if len(holders) == 0 {
+		return nil
+	}
+	return holders[0]
+}
+
+func TestRollbackToHeight(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	for i := 0; i < 3; i++ {
+		indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_B, 10)
+	}
+	height := indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_C, 5)
+
+	holderA := getTestHolder(t, store, TEST_ADDRESS_A, TEST_CONTRACT)
+	if holderA == nil || holderA.Balance.Int64() != 65 || holderA.Transactions != 5 {
+		t.Fatalf("holder A before rollback:%+v", holderA)
+	}
+
+	//Rolls back the transfer to C, and C is deleted
+	err := rollbackToHeight(store, height-1)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	tests := []struct {
+		address      string
+		balance      int64
+		transactions int
+	}{
+		{TEST_ADDRESS_A, 70, 4},
+		{TEST_ADDRESS_B, 30, 3},
+	}
+	for _, test := range tests {
+		holder := getTestHolder(t, store, test.address, TEST_CONTRACT)
+		if holder == nil {
+			t.Fatalf("holder %s is deleted", test.address)
+		}
+		if holder.Balance.Int64() != test.balance || holder.Transactions != test.transactions {
+			t.Errorf("holder %s balance:%s transactions:%d, expect %d %d",
+				test.address, holder.Balance, holder.Transactions, test.balance, test.transactions)
+		}
+	}
+	if holder := getTestHolder(t, store, TEST_ADDRESS_C, TEST_CONTRACT); holder != nil {
+		t.Errorf("holder C is not deleted:%+v", holder)
+	}
+	transfers, err := store.GetTransferHistory(0, 100, "", TEST_CONTRACT, 0, height)
+	if err != nil {
+		t.Fatalf("GetTransferHistory error:%s", err)
+	}
+	if len(transfers) != 4 {
+		t.Errorf("transfers after rollback:%d, expect 4", len(transfers))
+	}
+}
+
+func TestRollbackKeepsHolderWithBalance(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	height := indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_B, 1)
+	indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_B, 10)
+
+	//B has 1 transaction left, and A still holds its balance
+	err := rollbackToHeight(store, height)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	holderA := getTestHolder(t, store, TEST_ADDRESS_A, TEST_CONTRACT)
+	if holderA == nil || holderA.Balance.Int64() != 100 || holderA.Transactions != 1 {
+		t.Errorf("holder A after rollback:%+v", holderA)
+	}
+	holderB := getTestHolder(t, store, TEST_ADDRESS_B, TEST_CONTRACT)
+	if holderB == nil || holderB.Balance.Int64() != 1 || holderB.Transactions != 1 {
+		t.Errorf("holder B after rollback:%+v", holderB)
+	}
+}
+
+func TestRollbackAllowances(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	approveHeight := indexer.approve(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B, 100)
+	indexer.approve(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B, 50)
+	indexer.approve(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_C, 10)
+
+	//The allowance of B is restored to the approval at approveHeight, and C was never approved at the height
+	err := rollbackToHeight(store, approveHeight)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	allowance, err := store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B)
+	if err != nil || allowance == nil || allowance.Amount.Int64() != 100 || allowance.Height != approveHeight {
+		t.Errorf("allowance of B after rollback:%+v error:%v", allowance, err)
+	}
+	allowance, err = store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_C)
+	if err != nil || allowance != nil {
+		t.Errorf("allowance of C after rollback:%+v error:%v", allowance, err)
+	}
+
+	//Approvals above the height are deleted, so the allowance of B is deleted by rolling back before approveHeight
+	err = rollbackToHeight(store, approveHeight-1)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	allowances, err := store.GetAllowancesByOwner(0, 10, TEST_ADDRESS_A, "")
+	if err != nil || len(allowances) != 0 {
+		t.Errorf("allowances after rollback:%+v error:%v", allowances, err)
+	}
+}
+
+func TestRollbackBelowFirstTransfer(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	//Transfers below the first saved transfer are unknown, such as transfers indexed by an older version
+	height := indexer.approve(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B, 100)
+	indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+
+	err := rollbackToHeight(store, height)
+	if err == nil {
+		t.Errorf("rollbackToHeight below the first transfer succeeded")
+	}
+	holder := getTestHolder(t, store, TEST_ADDRESS_A, TEST_CONTRACT)
+	if holder == nil || holder.Balance.Int64() != 100 {
+		t.Errorf("holder A after rejected rollback:%+v", holder)
+	}
+	allowance, err := store.GetAllowance(TEST_CONTRACT, TEST_ADDRESS_A, TEST_ADDRESS_B)
+	if err != nil || allowance == nil {
+		t.Errorf("allowance after rejected rollback:%+v error:%v", allowance, err)
+	}
+}
+
+func TestRollbackInvalidTransfer(t *testing.T) {
+	indexer := newTestTokenIndexer(t, TEST_CONTRACT, DECODER_OEP4)
+	height := indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, ZERO_ADDRESS, TEST_ADDRESS_A, "0a"})
+	//The overdraft to B doesn't debit A, so rollback doesn't credit A with it
+	indexer.index([]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_B, "14"},
+		[]interface{}{TEST_NOTIFY_TRANSFER, TEST_ADDRESS_A, TEST_ADDRESS_C, "04"})
+
+	store := indexer.mgr.store
+	err := rollbackToHeight(store, height)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	holder := getTestHolder(t, store, TEST_ADDRESS_A, TEST_CONTRACT)
+	if holder == nil || holder.Balance.Int64() != 10 || holder.Transactions != 1 {
+		t.Errorf("holder A after rollback:%+v", holder)
+	}
+	for _, address := range []string{TEST_ADDRESS_B, TEST_ADDRESS_C} {
+		if holder := getTestHolder(t, store, address, TEST_CONTRACT); holder != nil {
+			t.Errorf("holder %s is not deleted:%+v", address, holder)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	log4 "github.com/alecthomas/log4go"
+	ontsdk "github.com/ontio/ontology-go-sdk"
+	sdkcom "github.com/ontio/ontology-go-sdk/common"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ENDPOINT_TYPE_RPC  = "rpc"
+	ENDPOINT_TYPE_REST = "rest"
+
+	RPC_REQUEST_TIMEOUT = 30 //s
+)
+
+var rpcHttpClient = &http.Client{Timeout: RPC_REQUEST_TIMEOUT * time.Second}
+
+//RpcEndpoint is the probe status of an ontology node endpoint.
+type RpcEndpoint struct {
+	Address   string `json:"address"`
+	Type      string `json:"type"`
+	Height    uint32 `json:"height"`
+	Latency   int64  `json:"latency"` //ms
+	IsHealthy bool   `json:"is_healthy"`
+	IsCurrent bool   `json:"is_current"`
+	Error     string `json:"error"`
+}
+
+type rpcEndpoint struct {
+	info   *RpcEndpoint
+	ontSdk *ontsdk.OntologySdk
+}
+
+//RpcManager probes ontology nodes periodically, and selects the healthiest one to serve requests.
+//An endpoint is healthy if it responds, and its height lags behind the highest endpoint no more than RpcMaxLagBlocks.
+type RpcManager struct {
+	endpoints []*rpcEndpoint
+	current   *rpcEndpoint
+	exitCh    chan interface{}
+	lock      sync.RWMutex
+}
+
+func NewRpcManager(cfg *Config) (*RpcManager, error) {
+	endpoints := make([]*rpcEndpoint, 0)
+	for _, address := range cfg.GetOntologyRpcAddresses() {
+		ontSdk := ontsdk.NewOntologySdk()
+		ontSdk.SetDefaultClient(ontSdk.NewRpcClient().SetAddress(address))
+		endpoints = append(endpoints, &rpcEndpoint{
+			info:   &RpcEndpoint{Address: address, Type: ENDPOINT_TYPE_RPC},
+			ontSdk: ontSdk,
+		})
+	}
+	for _, address := range cfg.OntologyRestAddresses {
+		ontSdk := ontsdk.NewOntologySdk()
+		ontSdk.SetDefaultClient(ontSdk.NewRestClient().SetAddress(address))
+		endpoints = append(endpoints, &rpcEndpoint{
+			info:   &RpcEndpoint{Address: address, Type: ENDPOINT_TYPE_REST},
+			ontSdk: ontSdk,
+		})
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no ontology endpoint in config")
+	}
+	return &RpcManager{
+		endpoints: endpoints,
+		current:   endpoints[0],
+		exitCh:    make(chan interface{}, 0),
+	}, nil
+}
+
+//Start probes endpoints once to select the current one, and keeps probing in background.
+func (this *RpcManager) Start() {
+	this.probe()
+	go this.startProbe()
+}
+
+func (this *RpcManager) startProbe() {
+	probeTime := time.Duration(DefConfig.GetRpcProbeInterval()) * time.Second
+	probeTimer := time.NewTimer(probeTime)
+	for {
+		select {
+		case <-probeTimer.C:
+			this.probe()
+			probeTimer.Reset(probeTime)
+		case <-this.exitCh:
+			return
+		}
+	}
+}
+
+func (this *RpcManager) probe() {
+	type probeResult struct {
+		height  uint32
+		latency time.Duration
+		err     error
+	}
+	results := make([]*probeResult, len(this.endpoints))
+	wg := &sync.WaitGroup{}
+	for i, endpoint := range this.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *rpcEndpoint) {
+			defer wg.Done()
+			startTime := time.Now()
+			height, err := endpoint.ontSdk.GetCurrentBlockHeight()
+			results[i] = &probeResult{height: height, latency: time.Since(startTime), err: err}
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	maxHeight := uint32(0)
+	for _, result := range results {
+		if result.err == nil && result.height > maxHeight {
+			maxHeight = result.height
+		}
+	}
+	maxLagBlocks := DefConfig.GetRpcMaxLagBlocks()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	var best *rpcEndpoint
+	for i, endpoint := range this.endpoints {
+		result := results[i]
+		info := endpoint.info
+		info.Height = result.height
+		info.Latency = int64(result.latency / time.Millisecond)
+		info.Error = ""
+		if result.err != nil {
+			info.Error = result.err.Error()
+		}
+		info.IsHealthy = result.err == nil && result.height+maxLagBlocks >= maxHeight
+		if !info.IsHealthy {
+			continue
+		}
+		if best == nil || info.Latency < best.info.Latency {
+			best = endpoint
+		}
+	}
+	if best == nil {
+		log4.Error("RpcManager no healthy endpoint, keep using:%s", this.current.info.Address)
+		return
+	}
+	//Keep current endpoint while it's healthy, to avoid switching between endpoints with similar latency
+	if this.current.info.IsHealthy || this.current == best {
+		return
+	}
+	log4.Info("RpcManager switch endpoint from:%s to:%s height:%d", this.current.info.Address, best.info.Address, best.info.Height)
+	this.current = best
+}
+
+//GetOntSdk returns the sdk of current endpoint.
+func (this *RpcManager) GetOntSdk() *ontsdk.OntologySdk {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.current.ontSdk
+}
+
+//GetSmartContractEventByBlock returns events of block at height from the current endpoint.
+//Events are decoded with json.Number instead of sdk, which decodes numbers of notify states to float64,
+//and amounts above 2^53 lose precision.
+func (this *RpcManager) GetSmartContractEventByBlock(height uint32) ([]*sdkcom.SmartContactEvent, error) {
+	this.lock.RLock()
+	info := *this.current.info
+	this.lock.RUnlock()
+
+	var httpResp *http.Response
+	var err error
+	address := strings.TrimRight(info.Address, "/")
+	if info.Type == ENDPOINT_TYPE_REST {
+		httpResp, err = rpcHttpClient.Get(fmt.Sprintf("%s/api/v1/smartcode/event/transactions/%d", address, height))
+	} else {
+		reqData, _ := json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"method":  "getsmartcodeevent",
+			"params":  []interface{}{height},
+		})
+		httpResp, err = rpcHttpClient.Post(address, "application/json", bytes.NewReader(reqData))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("http request error:%s", err)
+	}
+	defer httpResp.Body.Close()
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response error:%s", err)
+	}
+	return parseSmartContractEvents(data)
+}
+
+//parseSmartContractEvents parses the events response of rpc or rest api, whose fields differ only in case
+func parseSmartContractEvents(data []byte) ([]*sdkcom.SmartContactEvent, error) {
+	resp := &struct {
+		Error  int64
+		Desc   string
+		Result json.RawMessage
+	}{}
+	err := json.Unmarshal(data, resp)
+	if err != nil {
+		return nil, fmt.Errorf("json.Unmarshal response error:%s", err)
+	}
+	if resp.Error != 0 {
+		return nil, fmt.Errorf("error:%d desc:%s", resp.Error, resp.Desc)
+	}
+	evts := make([]*sdkcom.SmartContactEvent, 0)
+	result := bytes.TrimSpace(resp.Result)
+	//Result is null or empty string if there is no event in block
+	if len(result) == 0 || string(result) == "null" || string(result) == `""` {
+		return evts, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(result))
+	decoder.UseNumber()
+	err = decoder.Decode(&evts)
+	if err != nil {
+		return nil, fmt.Errorf("json decode events error:%s", err)
+	}
+	return evts, nil
+}
+
+func (this *RpcManager) GetEndpoints() []*RpcEndpoint {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	endpoints := make([]*RpcEndpoint, 0, len(this.endpoints))
+	for _, endpoint := range this.endpoints {
+		info := *endpoint.info
+		info.IsCurrent = endpoint == this.current
+		endpoints = append(endpoints, &info)
+	}
+	return endpoints
+}
+
+func (this *RpcManager) Close() {
+	close(this.exitCh)
+}
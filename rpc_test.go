@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseSmartContractEvents(t *testing.T) {
+	rpcResp := `{"desc":"SUCCESS","error":0,"id":1,"jsonrpc":"2.0","result":[{"TxHash":"7e8c19fdd4f9ba67f95659833e336eac37116f74ea8bf7be4541ada05b13503e","State":1,"GasConsumed":10000000,` +
+		`"Notify":[{"ContractAddress":"0200000000000000000000000000000000000000","States":["transfer","AFmseVrdL9f9oyCzZefL9tG6UbvhUMqNMV","AUgfA7zrbHKqpYS6WkUkwvBUZdZsfHkP2d",1000000000000000001]}]}]}`
+	evts, err := parseSmartContractEvents([]byte(rpcResp))
+	if err != nil {
+		t.Fatalf("parseSmartContractEvents error:%s", err)
+	}
+	if len(evts) != 1 || len(evts[0].Notify) != 1 || evts[0].State != 1 || evts[0].GasConsumed != 10000000 {
+		t.Fatalf("events:%+v", evts)
+	}
+	states, ok := evts[0].Notify[0].States.([]interface{})
+	if !ok || len(states) != 4 {
+		t.Fatalf("states:%#v", evts[0].Notify[0].States)
+	}
+	amount, ok := BigIntFromNotifyValue(states[3])
+	if !ok || amount.String() != "1000000000000000001" {
+		t.Errorf("amount:%v ok:%v, expect 1000000000000000001", amount, ok)
+	}
+
+	//Rest api has capitalized fields, and empty result if there is no event
+	tests := []struct {
+		resp  string
+		count int
+		isErr bool
+	}{
+		{`{"Action":"getsmartcodeeventbyheight","Desc":"SUCCESS","Error":0,"Result":"","Version":"1.0.0"}`, 0, false},
+		{`{"desc":"SUCCESS","error":0,"id":1,"jsonrpc":"2.0","result":null}`, 0, false},
+		{`{"Action":"getsmartcodeeventbyheight","Desc":"SUCCESS","Error":0,"Result":[{"TxHash":"00","State":0,"GasConsumed":0,"Notify":[]}],"Version":"1.0.0"}`, 1, false},
+		{`{"desc":"INVALID PARAMS","error":42002,"id":1,"jsonrpc":"2.0","result":""}`, 0, true},
+		{`not json`, 0, true},
+	}
+	for _, test := range tests {
+		evts, err := parseSmartContractEvents([]byte(test.resp))
+		if (err != nil) != test.isErr {
+			t.Errorf("parseSmartContractEvents %s error:%v", test.resp, err)
+			continue
+		}
+		if err == nil && len(evts) != test.count {
+			t.Errorf("parseSmartContractEvents %s events:%d, expect %d", test.resp, len(evts), test.count)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+	log4 "github.com/alecthomas/log4go"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	HOLDER_SNAPSHOT_MAX_COUNT    = 16  //snapshots kept by store, the earliest created are deleted
+	HOLDER_SNAPSHOT_REUSE_BLOCKS = 100 //a new snapshot page reuses the latest snapshot within these blocks below the synced height
+	SNAPSHOT_PAGE_SIZE           = 1000
+)
+
+//HolderSnapshot is the holders of contract at height saved by SaveHolderSnapshot, which are paged by GetAssetHolderAtHeight.
+type HolderSnapshot struct {
+	Contract   string
+	TokenId    string
+	Height     uint32
+	Holders    int //count of holders
+	CreateTime uint32
+}
+
+//checkSnapshotHeight returns why holders of contract at height can't be rebuilt from the saved transfers, empty if they can.
+//Transfers indexed by older version are not saved, so height must not be below the first saved transfer.
+func checkSnapshotHeight(store HolderStore, contract string, height uint32) (string, error) {
+	firstHeight, ok, err := store.GetFirstTransferHeight(contract)
+	if err != nil {
+		return "", fmt.Errorf("GetFirstTransferHeight error:%s", err)
+	}
+	if !ok {
+		return "no transfer is saved, holders at height are unavailable", nil
+	}
+	if height < firstHeight {
+		return fmt.Sprintf("height below the first saved transfer at height %d", firstHeight), nil
+	}
+	return "", nil
+}
+
+//getReusableSnapshotHeight returns the height of the latest snapshot of contract and tokenId saved within
+//HOLDER_SNAPSHOT_REUSE_BLOCKS below height, so that new snapshot pages don't build a snapshot for every block.
+//It returns height if there is none.
+func getReusableSnapshotHeight(store HolderStore, contract, tokenId string, height uint32) (uint32, error) {
+	snapshots, err := store.GetHolderSnapshots()
+	if err != nil {
+		return 0, fmt.Errorf("GetHolderSnapshots error:%s", err)
+	}
+	reuseHeight, ok := uint32(0), false
+	for _, snapshot := range snapshots {
+		if snapshot.Contract != contract || snapshot.TokenId != tokenId || snapshot.Height > height ||
+			height-snapshot.Height > HOLDER_SNAPSHOT_REUSE_BLOCKS {
+			continue
+		}
+		if !ok || snapshot.Height > reuseHeight {
+			reuseHeight, ok = snapshot.Height, true
+		}
+	}
+	if !ok {
+		return height, nil
+	}
+	return reuseHeight, nil
+}
+
+//buildHolderSnapshot saves holders of contract at height if they are not saved. Holders at height are the current holders
+//reverting the transfers above height. If lock is not nil, it's the lock of sync, which is held only while the current holders
+//are copied, so that sync isn't blocked by reverting transfers. Snapshots must not be built concurrently.
+func buildHolderSnapshot(store HolderStore, contract, tokenId string, height uint32, lock sync.Locker) error {
+	snapshots, err := store.GetHolderSnapshots()
+	if err != nil {
+		return fmt.Errorf("GetHolderSnapshots error:%s", err)
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Contract == contract && snapshot.TokenId == tokenId && snapshot.Height == height {
+			return nil
+		}
+	}
+	invalidInfo, err := checkSnapshotHeight(store, contract, height)
+	if err != nil {
+		return err
+	}
+	if invalidInfo != "" {
+		return fmt.Errorf("contract:%s height:%d %s", contract, height, invalidInfo)
+	}
+
+	holderMap, syncedHeight, err := copySnapshotHolders(store, contract, tokenId, lock)
+	if err != nil {
+		return err
+	}
+	//Transfers synced after holders are copied are not applied to them
+	transfers := make([]*TxTransfer, 0)
+	for from := 0; height < syncedHeight; from += SNAPSHOT_PAGE_SIZE {
+		items, err := store.GetTransferHistory(from, SNAPSHOT_PAGE_SIZE, "", contract, height+1, syncedHeight)
+		if err != nil {
+			return fmt.Errorf("GetTransferHistory error:%s", err)
+		}
+		for _, transfer := range items {
+			if holderTokenId(transfer) == tokenId {
+				transfers = append(transfers, transfer)
+			}
+		}
+		if len(items) < SNAPSHOT_PAGE_SIZE {
+			break
+		}
+	}
+	revertHolderTransfers(holderMap, transfers, true)
+
+	holders := make([]*AssetHolder, 0, len(holderMap))
+	for _, holder := range holderMap {
+		if holder.Address == ZERO_ADDRESS || holder.Balance.Sign() <= 0 {
+			continue
+		}
+		holders = append(holders, holder)
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		return IsHolderBefore(holders[i].Balance, holders[i].Address, holders[j], true)
+	})
+	snapshot := &HolderSnapshot{
+		Contract:   contract,
+		TokenId:    tokenId,
+		Height:     height,
+		Holders:    len(holders),
+		CreateTime: uint32(time.Now().Unix()),
+	}
+	err = store.SaveHolderSnapshot(snapshot, holders)
+	if err != nil {
+		return fmt.Errorf("SaveHolderSnapshot error:%s", err)
+	}
+	log4.Info("Save holder snapshot contract:%s token_id:%s height:%d, %d holders, %d transfers reverted",
+		contract, tokenId, height, len(holders), len(transfers))
+
+	//snapshots are ordered by create time
+	for i := 0; i <= len(snapshots)-HOLDER_SNAPSHOT_MAX_COUNT; i++ {
+		err = store.DeleteHolderSnapshot(snapshots[i])
+		if err != nil {
+			return fmt.Errorf("DeleteHolderSnapshot error:%s", err)
+		}
+	}
+	return nil
+}
+
+//copySnapshotHolders returns the current holders of contract and tokenId keyed by AssetHolder.Key(), and the sync checkpoint
+//of contract they are synced to, which is math.MaxUint32 without lock. lock is held while reading them.
+func copySnapshotHolders(store HolderStore, contract, tokenId string, lock sync.Locker) (map[string]*AssetHolder, uint32, error) {
+	if lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	holders, err := store.GetAssetHolder(0, 0, nil, "", contract, tokenId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetAssetHolder error:%s", err)
+	}
+	holderMap := make(map[string]*AssetHolder, len(holders))
+	for _, holder := range holders {
+		holderMap[holder.Key()] = holder
+	}
+	syncedHeight := uint32(math.MaxUint32)
+	if lock == nil {
+		return holderMap, syncedHeight, nil
+	}
+	syncStates, err := store.GetSyncStates()
+	if err != nil {
+		return nil, 0, fmt.Errorf("GetSyncStates error:%s", err)
+	}
+	for _, syncState := range syncStates {
+		if syncState.Contract == contract {
+			syncedHeight = syncState.Height
+		}
+	}
+	return holderMap, syncedHeight, nil
+}
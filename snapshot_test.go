@@ -0,0 +1,276 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"math/big"
+	"testing"
+)
+
+type testSnapshotHolder struct {
+	address      string
+	balance      int64
+	transactions int
+}
+
+func checkSnapshotHolders(t *testing.T, name string, holders []*AssetHolder, expect []testSnapshotHolder) {
+	if len(holders) != len(expect) {
+		t.Fatalf("%s: %d holders, expect %d", name, len(holders), len(expect))
+	}
+	for i, holder := range holders {
+		if holder.Address != expect[i].address || holder.Balance.Int64() != expect[i].balance || holder.Transactions != expect[i].transactions {
+			t.Errorf("%s: holder %d %s balance:%s transactions:%d, expect %s %d %d", name, i, holder.Address, holder.Balance,
+				holder.Transactions, expect[i].address, expect[i].balance, expect[i].transactions)
+		}
+	}
+}
+
+func getTestSnapshot(t *testing.T, store HolderStore, contract string, height uint32) []*AssetHolder {
+	err := buildHolderSnapshot(store, contract, "", height, nil)
+	if err != nil {
+		t.Fatalf("buildHolderSnapshot height:%d error:%s", height, err)
+	}
+	holders, err := store.GetAssetHolderAtHeight(0, 0, nil, contract, "", height)
+	if err != nil {
+		t.Fatalf("GetAssetHolderAtHeight height:%d error:%s", height, err)
+	}
+	return holders
+}
+
+func TestHolderSnapshot(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	mint := indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	toB := indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_B, 30)
+	toC := indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_B, TEST_ADDRESS_C, 30)
+	indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_B, 70)
+
+	tests := []struct {
+		name   string
+		height uint32
+		expect []testSnapshotHolder
+	}{
+		{"mint", mint, []testSnapshotHolder{{TEST_ADDRESS_A, 100, 1}}},
+		{"to B", toB, []testSnapshotHolder{{TEST_ADDRESS_A, 70, 2}, {TEST_ADDRESS_B, 30, 1}}},
+		//B has no balance but is kept by the current holders, it's not in the snapshot
+		{"to C", toC, []testSnapshotHolder{{TEST_ADDRESS_A, 70, 2}, {TEST_ADDRESS_C, 30, 1}}},
+		{"current", indexer.height, []testSnapshotHolder{{TEST_ADDRESS_B, 70, 3}, {TEST_ADDRESS_C, 30, 1}}},
+	}
+	for _, test := range tests {
+		checkSnapshotHolders(t, test.name, getTestSnapshot(t, store, TEST_CONTRACT, test.height), test.expect)
+	}
+
+	//Pages are read from the saved snapshot, by from or by cursor
+	holders, err := store.GetAssetHolderAtHeight(1, 1, nil, TEST_CONTRACT, "", toB)
+	if err != nil {
+		t.Fatalf("GetAssetHolderAtHeight error:%s", err)
+	}
+	checkSnapshotHolders(t, "page by from", holders, []testSnapshotHolder{{TEST_ADDRESS_B, 30, 1}})
+	cursor := &HolderCursor{Balance: big.NewInt(70), Address: TEST_ADDRESS_A}
+	holders, err = store.GetAssetHolderAtHeight(0, 1, cursor, TEST_CONTRACT, "", toB)
+	if err != nil {
+		t.Fatalf("GetAssetHolderAtHeight error:%s", err)
+	}
+	checkSnapshotHolders(t, "page by cursor", holders, []testSnapshotHolder{{TEST_ADDRESS_B, 30, 1}})
+	holders, err = store.GetAssetHolderAtHeight(0, 10, nil, TEST_CONTRACT, "", toB+100)
+	if err != nil {
+		t.Fatalf("GetAssetHolderAtHeight error:%s", err)
+	}
+	checkSnapshotHolders(t, "not saved", holders, nil)
+}
+
+func TestHolderSnapshotReuse(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	height := indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	getTestSnapshot(t, store, TEST_CONTRACT, height)
+
+	//A saved snapshot is paged as is, not rebuilt by the following requests
+	err := store.SaveHolderSnapshot(&HolderSnapshot{Contract: TEST_CONTRACT, Height: height, Holders: 1},
+		[]*AssetHolder{{Address: TEST_ADDRESS_B, Contract: TEST_CONTRACT, Balance: big.NewInt(1), Transactions: 1}})
+	if err != nil {
+		t.Fatalf("SaveHolderSnapshot error:%s", err)
+	}
+	indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_C, 10)
+	checkSnapshotHolders(t, "reuse", getTestSnapshot(t, store, TEST_CONTRACT, height), []testSnapshotHolder{{TEST_ADDRESS_B, 1, 1}})
+	snapshots, err := store.GetHolderSnapshots()
+	if err != nil {
+		t.Fatalf("GetHolderSnapshots error:%s", err)
+	}
+	if len(snapshots) != 1 {
+		t.Errorf("%d snapshots, expect 1", len(snapshots))
+	}
+}
+
+//testSyncLocker syncs a transfer when it's unlocked for the first time, as if sync was waiting for the lock
+type testSyncLocker struct {
+	sync func()
+}
+
+func (this *testSyncLocker) Lock() {}
+
+func (this *testSyncLocker) Unlock() {
+	if this.sync != nil {
+		sync := this.sync
+		this.sync = nil
+		sync()
+	}
+}
+
+func TestHolderSnapshotWhileSyncing(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	height := indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_B, 30)
+
+	//The transfer synced after holders are copied isn't reverted from them
+	lock := &testSyncLocker{sync: func() {
+		indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_C, 10)
+	}}
+	err := buildHolderSnapshot(store, TEST_CONTRACT, "", height, lock)
+	if err != nil {
+		t.Fatalf("buildHolderSnapshot error:%s", err)
+	}
+	holders, err := store.GetAssetHolderAtHeight(0, 0, nil, TEST_CONTRACT, "", height)
+	if err != nil {
+		t.Fatalf("GetAssetHolderAtHeight error:%s", err)
+	}
+	checkSnapshotHolders(t, "while syncing", holders, []testSnapshotHolder{{TEST_ADDRESS_A, 100, 1}})
+	if holder := getTestHolder(t, store, TEST_ADDRESS_C, TEST_CONTRACT); holder == nil {
+		t.Errorf("transfer to C is not synced")
+	}
+}
+
+func TestReusableSnapshotHeight(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	height := indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 100)
+	getTestSnapshot(t, store, TEST_CONTRACT, height)
+
+	tests := []struct {
+		name        string
+		tokenId     string
+		height      uint32
+		reuseHeight uint32
+	}{
+		{"same height", "", height, height},
+		{"within blocks", "", height + HOLDER_SNAPSHOT_REUSE_BLOCKS, height},
+		{"beyond blocks", "", height + HOLDER_SNAPSHOT_REUSE_BLOCKS + 1, height + HOLDER_SNAPSHOT_REUSE_BLOCKS + 1},
+		{"below snapshot", "", height - 1, height - 1},
+		{"other token id", "1", height + 1, height + 1},
+	}
+	for _, test := range tests {
+		reuseHeight, err := getReusableSnapshotHeight(store, TEST_CONTRACT, test.tokenId, test.height)
+		if err != nil {
+			t.Fatalf("getReusableSnapshotHeight error:%s", err)
+		}
+		if reuseHeight != test.reuseHeight {
+			t.Errorf("%s: snapshot height:%d, expect %d", test.name, reuseHeight, test.reuseHeight)
+		}
+	}
+}
+
+func TestHolderSnapshotHeight(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	//Holders indexed by an older version have no transfers saved
+	legacyHolder := &AssetHolder{Address: TEST_ADDRESS_A, Contract: TEST_CONTRACT, Balance: big.NewInt(100), Transactions: 3}
+	err := store.OnTxEventNotify(nil, []*AssetHolder{legacyHolder}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("OnTxEventNotify error:%s", err)
+	}
+	err = buildHolderSnapshot(store, TEST_CONTRACT, "", 10, nil)
+	if err == nil {
+		t.Errorf("snapshot without saved transfers is built")
+	}
+
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	indexer.holders[legacyHolder.Key()] = legacyHolder
+	indexer.height = 9
+	first := indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_B, 40)
+	indexer.transfer(TEST_CONTRACT, "", TEST_ADDRESS_A, TEST_ADDRESS_C, 10)
+
+	tests := []struct {
+		height uint32
+		isOk   bool
+	}{
+		{0, false},
+		{first - 1, false},
+		{first, true},
+		{first + 1, true},
+	}
+	for _, test := range tests {
+		invalidInfo, err := checkSnapshotHeight(store, TEST_CONTRACT, test.height)
+		if err != nil {
+			t.Fatalf("checkSnapshotHeight error:%s", err)
+		}
+		if (invalidInfo == "") != test.isOk {
+			t.Errorf("checkSnapshotHeight height:%d invalid:%s, expect ok:%v", test.height, invalidInfo, test.isOk)
+		}
+		err = buildHolderSnapshot(store, TEST_CONTRACT, "", test.height, nil)
+		if (err == nil) != test.isOk {
+			t.Errorf("buildHolderSnapshot height:%d error:%v, expect ok:%v", test.height, err, test.isOk)
+		}
+	}
+	checkSnapshotHolders(t, "first transfer", getTestSnapshot(t, store, TEST_CONTRACT, first),
+		[]testSnapshotHolder{{TEST_ADDRESS_A, 60, 4}, {TEST_ADDRESS_B, 40, 1}})
+}
+
+func TestHolderSnapshotEviction(t *testing.T) {
+	store := newTestLevelDBHelper(t)
+	indexer := newTestIndexer(t, store, TEST_CONTRACT)
+	for i := 0; i <= HOLDER_SNAPSHOT_MAX_COUNT; i++ {
+		indexer.transfer(TEST_CONTRACT, "", ZERO_ADDRESS, TEST_ADDRESS_A, 1)
+	}
+	for height := uint32(1); height <= indexer.height; height++ {
+		getTestSnapshot(t, store, TEST_CONTRACT, height)
+	}
+	snapshots, err := store.GetHolderSnapshots()
+	if err != nil {
+		t.Fatalf("GetHolderSnapshots error:%s", err)
+	}
+	if len(snapshots) != HOLDER_SNAPSHOT_MAX_COUNT {
+		t.Fatalf("%d snapshots, expect %d", len(snapshots), HOLDER_SNAPSHOT_MAX_COUNT)
+	}
+	//Snapshots created in the same second are ordered by key, the lowest height is the earliest
+	holders, err := store.GetAssetHolderAtHeight(0, 0, nil, TEST_CONTRACT, "", 1)
+	if err != nil {
+		t.Fatalf("GetAssetHolderAtHeight error:%s", err)
+	}
+	if len(holders) != 0 {
+		t.Errorf("snapshot at height 1 is not deleted")
+	}
+
+	//Rollback deletes snapshots above height
+	err = rollbackToHeight(store, 10)
+	if err != nil {
+		t.Fatalf("rollbackToHeight error:%s", err)
+	}
+	snapshots, err = store.GetHolderSnapshots()
+	if err != nil {
+		t.Fatalf("GetHolderSnapshots error:%s", err)
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Height > 10 {
+			t.Errorf("snapshot at height %d is not deleted by rollback", snapshot.Height)
+		}
+	}
+	if len(snapshots) != 9 {
+		t.Errorf("%d snapshots after rollback, expect 9", len(snapshots))
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"fmt"
+)
+
+//HolderStore is the storage backend of ontology-holder.
+//MySqlHelper and LevelDBHelper are the implementations, selected by Config.StoreType.
+type HolderStore interface {
+	Close() error
+	OnTxEventNotify(evtNotify []*TxEventNotify, assetHolder []*AssetHolder, transfers []*TxTransfer, tokenOwners []*TokenOwner, allowances []*Allowance, syncStates []*SyncState) error
+	//GetAssetHolder returns holders of tokenId of contract. If address is given and tokenId is empty,
+	//holders of all token ids of contract are returned. from is ignored if cursor is given, and holders after cursor are returned.
+	GetAssetHolder(from, count int, cursor *HolderCursor, address, contract, tokenId string, isDescOrder ...bool) ([]*AssetHolder, error)
+	GetTransferHistory(from, count int, address, contract string, startHeight, endHeight uint32) ([]*TxTransfer, error)
+	//GetAssetHolderAtHeight pages holders of the snapshot of tokenId of contract at height saved by SaveHolderSnapshot,
+	//ordered by balance desc. No holder is returned if the snapshot is not saved.
+	GetAssetHolderAtHeight(from, count int, cursor *HolderCursor, contract, tokenId string, height uint32) ([]*AssetHolder, error)
+	//GetFirstTransferHeight returns the height of the first saved transfer of contract, false if there is none
+	GetFirstTransferHeight(contract string) (uint32, bool, error)
+	//GetHolderSnapshots returns the saved holder snapshots, ordered by create time
+	GetHolderSnapshots() ([]*HolderSnapshot, error)
+	//SaveHolderSnapshot replaces holders of snapshot with holders
+	SaveHolderSnapshot(snapshot *HolderSnapshot, holders []*AssetHolder) error
+	DeleteHolderSnapshot(snapshot *HolderSnapshot) error
+	GetTokenOwner(contract, tokenId string) (*TokenOwner, error)
+	//GetLastTokenTransfer returns the last transfer of tokenId at or below height, nil if there is none
+	GetLastTokenTransfer(contract, tokenId string, height uint32) (*TxTransfer, error)
+	GetTokensOfOwner(from, count int, contract, owner string) ([]*TokenOwner, error)
+	GetAllowance(contract, owner, spender string) (*Allowance, error)
+	//GetAllowancesByOwner returns allowances approved by owner, of all contracts if contract is empty
+	GetAllowancesByOwner(from, count int, owner, contract string) ([]*Allowance, error)
+	//GetBalances returns holders of addresses, of all contracts if contracts is empty
+	GetBalances(addresses, contracts []string) ([]*AssetHolder, error)
+	//GetAllAssetHolders returns holders of all contracts, ordered by primary key
+	GetAllAssetHolders(from, count int) ([]*AssetHolder, error)
+	SaveBalanceMismatches(mismatches []*BalanceMismatch) error
+	//GetBalanceMismatches returns mismatches found by reconcile, of all contracts if contract is empty
+	GetBalanceMismatches(from, count int, contract string) ([]*BalanceMismatch, error)
+	GetAssetHolderByKey(holders []*AssetHolder) (map[string]*AssetHolder, error)
+	GetAssetHolderCount(contract, tokenId string) (int, error)
+	//GetAssetHolderCounts returns holder counts, keyed by contract + token id
+	GetAssetHolderCounts() (map[string]int, error)
+	//Rollback saves reverted holders and token owners, restores allowances approved above height to the last approval
+	//at or below height, deletes event notifies, transfers, approvals and holder snapshots above height,
+	//and resets sync checkpoints above height to height. Holders with zero balance are deleted.
+	Rollback(height uint32, assetHolders []*AssetHolder, tokenOwners []*TokenOwner) error
+	//ResetContract deletes holders, transfers, token owners, allowances, approvals, balance mismatches, holder snapshots
+	//and sync checkpoint of contract
+	ResetContract(contract string) error
+	//Migrate returns the migrations applied when the store is opened, which upgrades tables or keys written by an older version
+	Migrate() ([]string, error)
+	GetSyncStates() ([]*SyncState, error)
+	GetContracts() ([]*MonitorContract, error)
+	SaveContract(contract *MonitorContract) error
+	GetSyncedEventNotifyBlockHeight() (uint32, error)
+	IsGenesisInit() (bool, error)
+	IsEventNotifyExist(txHashes []string) (map[string]bool, error)
+	GetHeartbeat(module string) (*Heartbeat, error)
+	InsertHeartbeat(heartbeat *Heartbeat) error
+	UpdateHeartbeat(module string, nodeId uint32) (bool, error)
+	CheckHeartbeatTimeout(module string, timeout uint32) (uint32, error)
+	ResetHeartbeat(module string, nodeId, lastNodeId uint32) (bool, error)
+}
+
+//NewHolderStore opens and initializes the store configured by cfg.
+func NewHolderStore(cfg *Config, installFile string) (HolderStore, error) {
+	switch cfg.GetStoreType() {
+	case STORE_TYPE_MYSQL:
+		mySqlHelper := NewMySqlHelper(
+			cfg.MySqlAddress,
+			cfg.MySqlUserName,
+			cfg.MySqlPassword,
+			cfg.MySqlDBName,
+			cfg.MySqlMaxIdleConnSize,
+			cfg.MySqlMaxOpenConnSize,
+			cfg.MySqlConnMaxLifetime)
+		err := mySqlHelper.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open mysql error:%s", err)
+		}
+		err = mySqlHelper.InitDB(installFile)
+		if err != nil {
+			mySqlHelper.Close()
+			return nil, fmt.Errorf("InitDB error:%s", err)
+		}
+		return mySqlHelper, nil
+	case STORE_TYPE_LEVELDB:
+		levelDBHelper := NewLevelDBHelper(cfg.GetLevelDBPath())
+		err := levelDBHelper.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open leveldb error:%s", err)
+		}
+		return levelDBHelper, nil
+	default:
+		return nil, fmt.Errorf("unknown store type:%s", cfg.StoreType)
+	}
+}
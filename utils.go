@@ -19,9 +19,12 @@
 package main
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"os"
 	"sync"
 )
@@ -30,9 +33,14 @@ const (
 	ONT_ADDRESS = iota
 	ONG_ADDRESS
 	OEP4_ADDRESS
+	OEP5_ADDRESS
+	OEP8_ADDRESS
 	UNKNOW_ADDRESS
 )
 
+//MAX_EXACT_FLOAT_INT is 2^53, integers above it can't be represented exactly by float64
+const MAX_EXACT_FLOAT_INT = 1 << 53
+
 type OneThreadExecLock struct {
 	isWorking bool
 	lock      sync.Mutex
@@ -84,22 +92,32 @@ func SaveJsonObject(filePath string, jsonObject interface{}) error {
 }
 
 func IsMonitorContract(contract string) bool {
-	for _, item := range DefConfig.Contracts {
-		if contract == item {
-			return true
-		}
+	return DefContractMgr.IsMonitor(contract)
+}
+
+//IsContractAddress checks contract is a hex contract address, such as the contract address of notify
+func IsContractAddress(contract string) bool {
+	if len(contract) != 40 {
+		return false
 	}
-	return false
+	_, err := hex.DecodeString(contract)
+	return err == nil
 }
 
 func TypeOfContract(contract string) uint32 {
-	if contract == "0100000000000000000000000000000000000000" {
+	if contract == ONT_CONTRACT_ADDRESS {
 		return ONT_ADDRESS
-	} else if contract == "0200000000000000000000000000000000000000" {
+	} else if contract == ONG_CONTRACT_ADDRESS {
 		return ONG_ADDRESS
 	}
 
 	if IsMonitorContract(contract) {
+		if IsNFTContract(contract) {
+			return OEP5_ADDRESS
+		}
+		if IsMultiTokenContract(contract) {
+			return OEP8_ADDRESS
+		}
 		return OEP4_ADDRESS
 	} else {
 		return UNKNOW_ADDRESS
@@ -110,3 +128,49 @@ func IsFileExisted(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil || os.IsExist(err)
 }
+
+//ParseBigInt parses a decimal integer string, such as a DECIMAL(65,0) balance.
+func ParseBigInt(val string) (*big.Int, error) {
+	bigInt, ok := new(big.Int).SetString(val, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid big int:%s", val)
+	}
+	return bigInt, nil
+}
+
+//BigIntFromNotifyValue converts a native contract notify amount to big.Int.
+//Events are decoded with json.Number, a float64 amount is accepted only if it's exact, that is an integer not above 2^53.
+func BigIntFromNotifyValue(val interface{}) (*big.Int, bool) {
+	switch v := val.(type) {
+	case uint64:
+		return new(big.Int).SetUint64(v), true
+	case float64:
+		if v < 0 || v > MAX_EXACT_FLOAT_INT || v != math.Trunc(v) {
+			return nil, false
+		}
+		return new(big.Int).SetUint64(uint64(v)), true
+	case json.Number:
+		return parseNotifyAmount(v.String())
+	case string:
+		return parseNotifyAmount(v)
+	}
+	return nil, false
+}
+
+//parseNotifyAmount parses a non negative decimal integer, exponent and fraction are invalid
+func parseNotifyAmount(val string) (*big.Int, bool) {
+	bigInt, err := ParseBigInt(val)
+	if err != nil || bigInt.Sign() < 0 {
+		return nil, false
+	}
+	return bigInt, true
+}
+
+//BigIntRatio returns a/b as float64, 0 if b is zero.
+func BigIntRatio(a, b *big.Int) float64 {
+	if b == nil || b.Sign() == 0 || a == nil {
+		return 0
+	}
+	ratio, _ := new(big.Rat).SetFrac(a, b).Float64()
+	return ratio
+}
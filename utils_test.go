@@ -0,0 +1,60 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBigIntFromNotifyValue(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		amount string
+		ok     bool
+	}{
+		{uint64(18446744073709551615), "18446744073709551615", true},
+		{json.Number("1000000000000000001"), "1000000000000000001", true},
+		{json.Number("123456789012345678901234567890"), "123456789012345678901234567890", true},
+		{json.Number("1e18"), "", false},
+		{json.Number("1.5"), "", false},
+		{json.Number("-1"), "", false},
+		{"1000000000000000001", "1000000000000000001", true},
+		{"abc", "", false},
+		{float64(100), "100", true},
+		{float64(1 << 53), "9007199254740992", true},
+		//Above 2^53 float64 may have been rounded
+		{float64(1<<53 + 2), "", false},
+		{float64(1e18), "", false},
+		{float64(1.5), "", false},
+		{float64(-1), "", false},
+		{true, "", false},
+		{nil, "", false},
+	}
+	for _, test := range tests {
+		amount, ok := BigIntFromNotifyValue(test.value)
+		if ok != test.ok {
+			t.Errorf("BigIntFromNotifyValue %#v ok:%v, expect %v", test.value, ok, test.ok)
+			continue
+		}
+		if ok && amount.String() != test.amount {
+			t.Errorf("BigIntFromNotifyValue %#v amount:%s, expect %s", test.value, amount, test.amount)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (C) 2018 The ontology Authors
+ * This file is part of The ontology library.
+ *
+ * The ontology is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The ontology is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The ontology.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/ontio/ontology/common"
+	"math/big"
+)
+
+//Value types of wasm notify, which is serialized by EventBuilder of wasm contract
+const (
+	WASM_NOTIFY_BYTEARRAY = 0x00
+	WASM_NOTIFY_STRING    = 0x01
+	WASM_NOTIFY_ADDRESS   = 0x02
+	WASM_NOTIFY_BOOL      = 0x03
+	WASM_NOTIFY_INT       = 0x04
+	WASM_NOTIFY_H256      = 0x05
+	WASM_NOTIFY_LIST      = 0x10
+)
+
+//WASM_NOTIFY_PREFIX is the prefix of notify serialized by EventBuilder
+const WASM_NOTIFY_PREFIX = "evt\x00"
+
+//wasmPreExec invokes method of wasm contract without params, and returns the serialized result.
+func (this *OntologyManager) wasmPreExec(contract, method string) ([]byte, error) {
+	contractAddr, err := common.AddressFromHexString(contract)
+	if err != nil {
+		return nil, err
+	}
+	preResult, err := this.GetOntSdk().WasmVM.PreExecInvokeWasmVMContract(contractAddr, method, []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return preResult.Result.ToByteArray()
+}
+
+func (this *OntologyManager) wasmOEP4String(contract, method string) (string, error) {
+	data, err := this.wasmPreExec(contract, method)
+	if err != nil {
+		return "", err
+	}
+	return parseWasmString(data)
+}
+
+//wasmOEP4Integer returns integer result of wasm OEP-4 method, which is u8 or u128.
+func (this *OntologyManager) wasmOEP4Integer(contract, method string) (*big.Int, error) {
+	data, err := this.wasmPreExec(contract, method)
+	if err != nil {
+		return nil, err
+	}
+	switch len(data) {
+	case 1:
+		return new(big.Int).SetUint64(uint64(data[0])), nil
+	case 16:
+		return parseWasmU128(data), nil
+	}
+	return nil, fmt.Errorf("invalid integer result length:%d", len(data))
+}
+
+//parseWasmString decodes a string serialized by wasm contract, which is var bytes.
+func parseWasmString(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty string result")
+	}
+	size := uint64(data[0])
+	offset := 1
+	switch data[0] {
+	case 0xfd:
+		offset = 3
+	case 0xfe:
+		offset = 5
+	case 0xff:
+		offset = 9
+	}
+	if len(data) < offset {
+		return "", fmt.Errorf("invalid string result length:%d", len(data))
+	}
+	switch offset {
+	case 3:
+		size = uint64(binary.LittleEndian.Uint16(data[1:offset]))
+	case 5:
+		size = uint64(binary.LittleEndian.Uint32(data[1:offset]))
+	case 9:
+		size = binary.LittleEndian.Uint64(data[1:offset])
+	}
+	if uint64(len(data)-offset) < size {
+		return "", fmt.Errorf("invalid string result length:%d", len(data))
+	}
+	return string(data[offset : uint64(offset)+size]), nil
+}
+
+//parseWasmU128 decodes a little endian u128
+func parseWasmU128(data []byte) *big.Int {
+	bigEndian := make([]byte, len(data))
+	for i, b := range data {
+		bigEndian[len(data)-1-i] = b
+	}
+	return new(big.Int).SetBytes(bigEndian)
+}
+
+//wasmNotifyStates returns states of wasm notify. Node returns the decoded list if it can decode the notify,
+//otherwise returns the hex of serialized notify, which is decoded here.
+//Values of the list are in the form of node: address is base58, integer is decimal string, bytes and h256 are hex.
+func wasmNotifyStates(states interface{}) ([]interface{}, bool) {
+	switch v := states.(type) {
+	case []interface{}:
+		return v, true
+	case string:
+		data, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, false
+		}
+		list, err := parseWasmNotify(data)
+		if err != nil {
+			return nil, false
+		}
+		return list, true
+	}
+	return nil, false
+}
+
+//parseWasmNotify decodes notify serialized by EventBuilder of wasm contract,
+//which is "evt\0" + list type(1 byte) + list size(u32) + values.
+func parseWasmNotify(data []byte) ([]interface{}, error) {
+	data = bytes.TrimPrefix(data, []byte(WASM_NOTIFY_PREFIX))
+	value, offset, err := parseWasmNotifyValue(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if offset != len(data) {
+		return nil, fmt.Errorf("invalid notify length:%d", len(data))
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("notify is not list")
+	}
+	return list, nil
+}
+
+//parseWasmNotifyValue decodes a value at offset of data, and returns the value and offset of next value
+func parseWasmNotifyValue(data []byte, offset int) (interface{}, int, error) {
+	if offset >= len(data) {
+		return nil, 0, fmt.Errorf("unexpected end of notify")
+	}
+	valueType := data[offset]
+	offset++
+	switch valueType {
+	case WASM_NOTIFY_BYTEARRAY, WASM_NOTIFY_STRING:
+		size, offset, err := parseWasmNotifyUint32(data, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		if uint64(len(data)-offset) < uint64(size) {
+			return nil, 0, fmt.Errorf("unexpected end of notify")
+		}
+		value := data[offset : offset+int(size)]
+		if valueType == WASM_NOTIFY_STRING {
+			return string(value), offset + int(size), nil
+		}
+		return hex.EncodeToString(value), offset + int(size), nil
+	case WASM_NOTIFY_ADDRESS:
+		if len(data)-offset < common.ADDR_LEN {
+			return nil, 0, fmt.Errorf("unexpected end of notify")
+		}
+		address, err := common.AddressParseFromBytes(data[offset : offset+common.ADDR_LEN])
+		if err != nil {
+			return nil, 0, err
+		}
+		return address.ToBase58(), offset + common.ADDR_LEN, nil
+	case WASM_NOTIFY_BOOL:
+		if offset >= len(data) || data[offset] > 1 {
+			return nil, 0, fmt.Errorf("invalid bool of notify")
+		}
+		return data[offset] == 1, offset + 1, nil
+	case WASM_NOTIFY_INT:
+		if len(data)-offset < 16 {
+			return nil, 0, fmt.Errorf("unexpected end of notify")
+		}
+		return parseWasmI128(data[offset : offset+16]).String(), offset + 16, nil
+	case WASM_NOTIFY_H256:
+		if len(data)-offset < common.UINT256_SIZE {
+			return nil, 0, fmt.Errorf("unexpected end of notify")
+		}
+		return hex.EncodeToString(data[offset : offset+common.UINT256_SIZE]), offset + common.UINT256_SIZE, nil
+	case WASM_NOTIFY_LIST:
+		size, offset, err := parseWasmNotifyUint32(data, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		list := make([]interface{}, 0)
+		for i := uint32(0); i < size; i++ {
+			var value interface{}
+			value, offset, err = parseWasmNotifyValue(data, offset)
+			if err != nil {
+				return nil, 0, err
+			}
+			list = append(list, value)
+		}
+		return list, offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown notify type:%d", valueType)
+}
+
+func parseWasmNotifyUint32(data []byte, offset int) (uint32, int, error) {
+	if len(data)-offset < 4 {
+		return 0, 0, fmt.Errorf("unexpected end of notify")
+	}
+	return binary.LittleEndian.Uint32(data[offset : offset+4]), offset + 4, nil
+}
+
+//parseWasmI128 decodes a little endian two's complement i128
+func parseWasmI128(data []byte) *big.Int {
+	value := parseWasmU128(data)
+	if data[len(data)-1]&0x80 != 0 {
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), uint(len(data)*8)))
+	}
+	return value
+}